@@ -0,0 +1,110 @@
+// Package enclaveidentity decodes the identities of the Quoting Enclave and Quote Verification Enclave
+// returned by the Intel PCS and PCCS (see the Intel SGX and TDX PCS API Specification, version 4).
+package enclaveidentity
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	pckcert "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/pck_cert"
+)
+
+// IssuerChainHeader holds the TCB Signing and root CA certificates of an enclave identity response
+const IssuerChainHeader = "SGX-Enclave-Identity-Issuer-Chain"
+
+// Identifiers of the enclave identities
+const (
+	IDQE  = "QE"
+	IDQVE = "QVE"
+)
+
+const (
+	// enclave identity of the version 4 API
+	enclaveIdentityVersion = 2
+
+	// the signature is the concatenation of the 32 bytes r and s ECDSA P-256 values
+	signatureSize = 64
+)
+
+var (
+	// ErrInvalidResponse is returned when an enclave identity response cannot be decoded
+	ErrInvalidResponse = errors.New("invalid enclave identity response")
+	// ErrVerificationFailed is returned when an enclave identity is not signed by a certificate issued by the
+	// root CA
+	ErrVerificationFailed = errors.New("enclave identity verification failed")
+)
+
+// EnclaveIdentity is the identity of the Quoting Enclave or Quote Verification Enclave. Only the fields
+// identifying and dating it are decoded.
+type EnclaveIdentity struct {
+	ID                      string    `json:"id"`
+	Version                 int       `json:"version"`
+	IssueDate               time.Time `json:"issueDate"`
+	NextUpdate              time.Time `json:"nextUpdate"`
+	TCBEvaluationDataNumber int       `json:"tcbEvaluationDataNumber"`
+}
+
+// Response is an enclave identity and the signature returned along with it
+type Response struct {
+	EnclaveIdentity EnclaveIdentity
+	// Signature is the ECDSA signature of the JSON encoded enclave identity, as returned in the response body
+	Signature []byte
+	// IssuerChain holds the TCB Signing and root CA certificates
+	IssuerChain []*x509.Certificate
+
+	rawEnclaveIdentity []byte
+}
+
+// ParseResponse decodes the body and headers of a successful enclave identity response
+func ParseResponse(body []byte, header http.Header) (*Response, error) {
+	var envelope struct {
+		EnclaveIdentity json.RawMessage `json:"enclaveIdentity"`
+		Signature       string          `json:"signature"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	if len(envelope.EnclaveIdentity) == 0 {
+		return nil, fmt.Errorf("%w: missing enclaveIdentity", ErrInvalidResponse)
+	}
+
+	signature, err := hex.DecodeString(envelope.Signature)
+	if err != nil || len(signature) != signatureSize {
+		return nil, fmt.Errorf("%w: signature must be %d hex encoded bytes", ErrInvalidResponse, signatureSize)
+	}
+
+	issuerChain, err := pckcert.ParseIssuerChain(header.Get(IssuerChainHeader))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+
+	var identity EnclaveIdentity
+	if err := json.Unmarshal(envelope.EnclaveIdentity, &identity); err != nil {
+		return nil, fmt.Errorf("%w: enclaveIdentity: %w", ErrInvalidResponse, err)
+	}
+	if identity.ID != IDQE && identity.ID != IDQVE || identity.Version != enclaveIdentityVersion {
+		return nil, fmt.Errorf("%w: unsupported enclave identity %s version %d",
+			ErrInvalidResponse, identity.ID, identity.Version)
+	}
+
+	return &Response{
+		EnclaveIdentity:    identity,
+		Signature:          signature,
+		IssuerChain:        issuerChain,
+		rawEnclaveIdentity: envelope.EnclaveIdentity,
+	}, nil
+}
+
+// Verify checks that the enclave identity is signed by the first certificate of the issuer chain, which must
+// chain up to root at the given time
+func (r *Response) Verify(root *x509.Certificate, now time.Time) error {
+	if err := pckcert.VerifySignature(r.IssuerChain, root, r.rawEnclaveIdentity, r.Signature, now); err != nil {
+		return fmt.Errorf("%w: %w", ErrVerificationFailed, err)
+	}
+	return nil
+}
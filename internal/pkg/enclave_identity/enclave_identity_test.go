@@ -0,0 +1,137 @@
+package enclaveidentity_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	enclaveidentity "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/enclave_identity"
+	fakepcs "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/fake_pcs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseResponse(t *testing.T) {
+	ca, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	identity := fakepcs.EnclaveIdentity(enclaveidentity.IDQE)
+	recorder := httptest.NewRecorder()
+	ca.WriteEnclaveIdentityResponse(recorder, identity)
+
+	response, err := enclaveidentity.ParseResponse(recorder.Body.Bytes(), recorder.Header())
+	assert.NoError(t, err)
+	assert.Equal(t, identity, response.EnclaveIdentity)
+	assert.Len(t, response.IssuerChain, 2)
+	assert.Equal(t, ca.TCBSigning.Raw, response.IssuerChain[0].Raw)
+	assert.NoError(t, response.Verify(ca.Root, time.Now()))
+}
+
+func TestParseResponseInvalid(t *testing.T) {
+	ca, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	valid := httptest.NewRecorder()
+	ca.WriteEnclaveIdentityResponse(valid, fakepcs.EnclaveIdentity(enclaveidentity.IDQVE))
+
+	unsupported := fakepcs.EnclaveIdentity(enclaveidentity.IDQE)
+	unsupported.Version = 1
+	unsupportedResponse := httptest.NewRecorder()
+	ca.WriteEnclaveIdentityResponse(unsupportedResponse, unsupported)
+
+	unknown := fakepcs.EnclaveIdentity("TD_QE")
+	unknownResponse := httptest.NewRecorder()
+	ca.WriteEnclaveIdentityResponse(unknownResponse, unknown)
+
+	cases := []struct {
+		msg    string
+		body   []byte
+		header string
+	}{
+		{
+			msg:    "body is not JSON",
+			body:   []byte("not json"),
+			header: valid.Header().Get(enclaveidentity.IssuerChainHeader),
+		},
+		{
+			msg:    "signature is truncated",
+			body:   bytes.Replace(valid.Body.Bytes(), []byte(`"signature":"`), []byte(`"signature":"00`), 1),
+			header: valid.Header().Get(enclaveidentity.IssuerChainHeader),
+		},
+		{
+			msg:  "issuer chain is missing",
+			body: valid.Body.Bytes(),
+		},
+		{
+			msg:    "enclave identity version is not supported",
+			body:   unsupportedResponse.Body.Bytes(),
+			header: unsupportedResponse.Header().Get(enclaveidentity.IssuerChainHeader),
+		},
+		{
+			msg:    "enclave identity of an unknown enclave",
+			body:   unknownResponse.Body.Bytes(),
+			header: unknownResponse.Header().Get(enclaveidentity.IssuerChainHeader),
+		},
+	}
+
+	for _, c := range cases {
+		header := valid.Header().Clone()
+		header.Set(enclaveidentity.IssuerChainHeader, c.header)
+		_, err := enclaveidentity.ParseResponse(c.body, header)
+		assert.ErrorIs(t, err, enclaveidentity.ErrInvalidResponse, c.msg)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ca, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	untrustedCA, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	ca.WriteEnclaveIdentityResponse(recorder, fakepcs.EnclaveIdentity(enclaveidentity.IDQE))
+
+	cases := []struct {
+		msg     string
+		body    []byte
+		root    *fakepcs.CA
+		now     time.Time
+		wantErr bool
+	}{
+		{
+			msg:  "signed enclave identity is accepted",
+			body: recorder.Body.Bytes(),
+			root: ca,
+			now:  time.Now(),
+		},
+		{
+			msg:     "enclave identity of an untrusted root is rejected",
+			body:    recorder.Body.Bytes(),
+			root:    untrustedCA,
+			now:     time.Now(),
+			wantErr: true,
+		},
+		{
+			msg:     "enclave identity with an expired signing certificate is rejected",
+			body:    recorder.Body.Bytes(),
+			root:    ca,
+			now:     time.Now().Add(20 * 365 * 24 * time.Hour),
+			wantErr: true,
+		},
+		{
+			msg:     "modified enclave identity is rejected",
+			body:    bytes.Replace(recorder.Body.Bytes(), []byte(`"tcbEvaluationDataNumber":17`), []byte(`"tcbEvaluationDataNumber":18`), 1),
+			root:    ca,
+			now:     time.Now(),
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		response, err := enclaveidentity.ParseResponse(c.body, recorder.Header())
+		assert.NoError(t, err, c.msg)
+		err = response.Verify(c.root.Root, c.now)
+		if c.wantErr {
+			assert.ErrorIs(t, err, enclaveidentity.ErrVerificationFailed, c.msg)
+		} else {
+			assert.NoError(t, err, c.msg)
+		}
+	}
+}
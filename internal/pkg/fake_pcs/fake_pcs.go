@@ -0,0 +1,488 @@
+// Package fakepcs issues PCK certificates and signs TCB Info and enclave identities from an in-memory
+// certificate hierarchy shaped like the Intel SGX Root CA, PCK Processor CA and TCB Signing certificate,
+// so the PCS and PCCS replies can be faked in tests.
+package fakepcs
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	enclaveidentity "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/enclave_identity"
+	pckcert "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/pck_cert"
+	tcbinfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/tcb_info"
+)
+
+// CA is a root CA and an intermediate PCK CA issuing PCK certificates
+type CA struct {
+	Root            *x509.Certificate
+	RootPEM         []byte
+	Intermediate    *x509.Certificate
+	IntermediatePEM []byte
+	TCBSigning      *x509.Certificate
+	TCBSigningPEM   []byte
+
+	rootKey         *ecdsa.PrivateKey
+	intermediateKey *ecdsa.PrivateKey
+	tcbSigningKey   *ecdsa.PrivateKey
+
+	mu      sync.Mutex
+	serial  int64
+	revoked []*big.Int
+	crlNum  int64
+}
+
+// PCK describes the platform and TCB level of an issued PCK certificate
+type PCK struct {
+	FMSPC    string // hex encoded, 6 bytes
+	CPUSVN   string // hex encoded, 16 bytes
+	PCESVN   string // hex encoded little-endian, 2 bytes
+	PCEID    string // hex encoded, 2 bytes
+	CAType   string // processor (default) or platform
+	NotAfter time.Time
+	// Unavailable lists the TCB level without certificate in the /pckcerts responses
+	Unavailable bool
+	// Issuer issues the certificate of the TCB level in the /pckcerts responses instead of the CA
+	Issuer *CA
+}
+
+// NewCA creates a root CA, its intermediate PCK CA and its TCB Signing certificate
+func NewCA() (*CA, error) {
+	ca := &CA{serial: 1}
+
+	var err error
+	if ca.rootKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		return nil, err
+	}
+	rootTemplate := ca.caTemplate("Intel SGX Root CA")
+	if ca.Root, ca.RootPEM, err = ca.sign(rootTemplate, rootTemplate, &ca.rootKey.PublicKey, ca.rootKey); err != nil {
+		return nil, err
+	}
+
+	if ca.intermediateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		return nil, err
+	}
+	ca.Intermediate, ca.IntermediatePEM, err = ca.sign(ca.caTemplate("Intel SGX PCK Processor CA"), ca.Root,
+		&ca.intermediateKey.PublicKey, ca.rootKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if ca.tcbSigningKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		return nil, err
+	}
+	tcbSigningTemplate := ca.caTemplate("Intel SGX TCB Signing")
+	tcbSigningTemplate.IsCA = false
+	tcbSigningTemplate.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment
+	ca.TCBSigning, ca.TCBSigningPEM, err = ca.sign(tcbSigningTemplate, ca.Root, &ca.tcbSigningKey.PublicKey, ca.rootKey)
+	if err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+// IssuerChain returns the URL-encoded issuer chain header value of the issued PCK certificates
+func (ca *CA) IssuerChain() string {
+	return url.QueryEscape(string(ca.IntermediatePEM) + string(ca.RootPEM))
+}
+
+// IssuePCK issues a PEM encoded PCK certificate
+func (ca *CA) IssuePCK(p PCK) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	notAfter := p.NotAfter
+	if notAfter.IsZero() {
+		notAfter = time.Now().Add(365 * 24 * time.Hour)
+	}
+	sgxExtensions, err := marshalSGXExtensions(p)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:    ca.nextSerial(),
+		Subject:         pkix.Name{CommonName: "Intel SGX PCK Certificate", Organization: []string{"Intel Corporation"}},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        notAfter,
+		KeyUsage:        x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+		ExtraExtensions: []pkix.Extension{{Id: pckcert.OIDSGXExtensions, Value: sgxExtensions}},
+	}
+	_, leafPEM, err := ca.sign(template, ca.Intermediate, &key.PublicKey, ca.intermediateKey)
+	return leafPEM, err
+}
+
+// WritePCKResponse writes a successful PCK certificate response, as returned by the PCS and PCCS
+func (ca *CA) WritePCKResponse(w http.ResponseWriter, p PCK) {
+	leafPEM, err := ca.IssuePCK(p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	caType := p.CAType
+	if caType == "" {
+		caType = pckcert.CATypeProcessor
+	}
+	w.Header().Set(pckcert.IssuerChainHeader, ca.IssuerChain())
+	w.Header().Set(pckcert.TCBmHeader, strings.ToUpper(p.CPUSVN+p.PCESVN))
+	w.Header().Set(pckcert.FMSPCHeader, strings.ToUpper(p.FMSPC))
+	w.Header().Set(pckcert.CATypeHeader, caType)
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(leafPEM)
+}
+
+// WritePCKCertsResponse writes a successful /pckcerts response with a TCB level per PCK, which must share
+// their FMSPC and CA type
+func (ca *CA) WritePCKCertsResponse(w http.ResponseWriter, pcks ...PCK) {
+	type entry struct {
+		TCB  map[string]int `json:"tcb"`
+		TCBm string         `json:"tcbm"`
+		Cert string         `json:"cert"`
+	}
+	var entries []entry
+	for _, p := range pcks {
+		tcb, err := tcbComponents(p)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		e := entry{TCB: tcb, TCBm: strings.ToUpper(p.CPUSVN + p.PCESVN), Cert: "Not available"}
+		if !p.Unavailable {
+			issuer := ca
+			if p.Issuer != nil {
+				issuer = p.Issuer
+			}
+			leafPEM, err := issuer.IssuePCK(p)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			e.Cert = url.QueryEscape(string(leafPEM))
+		}
+		entries = append(entries, e)
+	}
+	body, err := json.Marshal(entries)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	caType := pcks[0].CAType
+	if caType == "" {
+		caType = pckcert.CATypeProcessor
+	}
+	w.Header().Set(pckcert.IssuerChainHeader, ca.IssuerChain())
+	w.Header().Set(pckcert.FMSPCHeader, strings.ToUpper(pcks[0].FMSPC))
+	w.Header().Set(pckcert.CATypeHeader, caType)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// tcbComponents returns the tcb object of a /pckcerts TCB level
+func tcbComponents(p PCK) (map[string]int, error) {
+	cpusvn, err := hex.DecodeString(p.CPUSVN)
+	if err != nil || len(cpusvn) != 16 {
+		return nil, fmt.Errorf("invalid CPUSVN '%s'", p.CPUSVN)
+	}
+	pcesvn, err := hex.DecodeString(p.PCESVN)
+	if err != nil || len(pcesvn) != 2 {
+		return nil, fmt.Errorf("invalid PCESVN '%s'", p.PCESVN)
+	}
+	tcb := map[string]int{"pcesvn": int(pcesvn[0]) | int(pcesvn[1])<<8}
+	for i, svn := range cpusvn {
+		tcb[fmt.Sprintf("sgxtcbcomp%02dsvn", i+1)] = int(svn)
+	}
+	return tcb, nil
+}
+
+// Revoke lists the PCK certificate with the given serial number in the CRLs issued afterwards
+func (ca *CA) Revoke(serial *big.Int) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.revoked = append(ca.revoked, serial)
+}
+
+// IssueCRL issues a DER encoded CRL of the PCK CA
+func (ca *CA) IssueCRL(nextUpdate time.Time) ([]byte, error) {
+	ca.mu.Lock()
+	ca.crlNum++
+	template := &x509.RevocationList{
+		Number:     big.NewInt(ca.crlNum),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: nextUpdate,
+	}
+	for _, serial := range ca.revoked {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries,
+			x509.RevocationListEntry{SerialNumber: serial, RevocationTime: time.Now().Add(-time.Minute)})
+	}
+	ca.mu.Unlock()
+	return x509.CreateRevocationList(rand.Reader, template, ca.Intermediate, ca.intermediateKey)
+}
+
+// WriteCRLResponse writes a successful PEM encoded PCK CRL response
+func (ca *CA) WriteCRLResponse(w http.ResponseWriter, nextUpdate time.Time) {
+	der, err := ca.IssueCRL(nextUpdate)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(pckcert.CRLIssuerChainHeader, ca.IssuerChain())
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.WriteHeader(http.StatusOK)
+	_ = pem.Encode(w, &pem.Block{Type: "X509 CRL", Bytes: der})
+}
+
+// TCBInfo returns a TCB Info of the FMSPC and PCEID with the given TCB levels, valid for a day
+func TCBInfo(fmspc, pceid string, levels ...tcbinfo.TCBLevel) tcbinfo.TCBInfo {
+	now := time.Now().UTC().Truncate(time.Second)
+	return tcbinfo.TCBInfo{
+		ID:                      "SGX",
+		Version:                 3,
+		IssueDate:               now,
+		NextUpdate:              now.Add(24 * time.Hour),
+		FMSPC:                   fmspc,
+		PCEID:                   pceid,
+		TCBEvaluationDataNumber: 17,
+		TCBLevels:               levels,
+	}
+}
+
+// TCBLevel returns a TCB level with the hex encoded CPUSVN and the PCESVN as minimum TCB
+func TCBLevel(cpusvn string, pcesvn uint16, status string, advisoryIDs ...string) tcbinfo.TCBLevel {
+	svns, err := hex.DecodeString(cpusvn)
+	if err != nil || len(svns) != 16 {
+		panic(fmt.Sprintf("invalid CPUSVN '%s'", cpusvn))
+	}
+	level := tcbinfo.TCBLevel{
+		TCB:         tcbinfo.TCB{PCESVN: pcesvn},
+		TCBDate:     time.Date(2024, time.November, 13, 0, 0, 0, 0, time.UTC),
+		TCBStatus:   status,
+		AdvisoryIDs: advisoryIDs,
+	}
+	for _, svn := range svns {
+		level.TCB.SGXTCBComponents = append(level.TCB.SGXTCBComponents, tcbinfo.TCBComponent{SVN: svn})
+	}
+	return level
+}
+
+// WriteTCBInfoResponse writes a successful TCB Info response signed by the TCB Signing certificate
+func (ca *CA) WriteTCBInfoResponse(w http.ResponseWriter, info tcbinfo.TCBInfo) {
+	ca.writeSignedResponse(w, "tcbInfo", tcbinfo.IssuerChainHeader, info)
+}
+
+// EnclaveIdentity returns the QE or QVE identity, valid for a day
+func EnclaveIdentity(id string) enclaveidentity.EnclaveIdentity {
+	now := time.Now().UTC().Truncate(time.Second)
+	return enclaveidentity.EnclaveIdentity{
+		ID:                      id,
+		Version:                 2,
+		IssueDate:               now,
+		NextUpdate:              now.Add(24 * time.Hour),
+		TCBEvaluationDataNumber: 17,
+	}
+}
+
+// WriteEnclaveIdentityResponse writes a successful enclave identity response signed by the TCB Signing
+// certificate
+func (ca *CA) WriteEnclaveIdentityResponse(w http.ResponseWriter, identity enclaveidentity.EnclaveIdentity) {
+	ca.writeSignedResponse(w, "enclaveIdentity", enclaveidentity.IssuerChainHeader, identity)
+}
+
+// writeSignedResponse writes the JSON encoded value under the field of the body, along with its signature
+// by the TCB Signing certificate
+func (ca *CA) writeSignedResponse(w http.ResponseWriter, field, issuerChainHeader string, value any) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	digest := sha256.Sum256(raw)
+	r, s, err := ecdsa.Sign(rand.Reader, ca.tcbSigningKey, digest[:])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	body, err := json.Marshal(map[string]any{
+		field:       json.RawMessage(raw),
+		"signature": hex.EncodeToString(signature),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(issuerChainHeader, url.QueryEscape(string(ca.TCBSigningPEM)+string(ca.RootPEM)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// WriteRootCACRLResponse writes a DER encoded CRL of the root CA, as distributed by Intel
+func (ca *CA) WriteRootCACRLResponse(w http.ResponseWriter, nextUpdate time.Time) {
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: nextUpdate,
+	}, ca.Root, ca.rootKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pkix-crl")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(der)
+}
+
+// platformCollateralKeys lists the keys required by the PCCS in a PUT /platformcollateral body, by object
+var platformCollateralKeys = map[string][]string{
+	"":            {"platforms", "collaterals"},
+	"collaterals": {"pck_certs", "tcbinfos", "pckcacrl", "qeidentity", "qveidentity", "rootcacrl", "certificates"},
+	"certificates": {
+		"sgx-pck-certificate-issuer-chain", "sgx-tcb-info-issuer-chain", "sgx-enclave-identity-issuer-chain",
+	},
+}
+
+// CheckPlatformCollateral returns an error when a key required by the PCCS is missing or empty in a
+// PUT /platformcollateral body, like the schema validation of the PCCS
+func CheckPlatformCollateral(body []byte) error {
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(body, &root); err != nil {
+		return err
+	}
+	if err := checkKeys(root, platformCollateralKeys[""], ""); err != nil {
+		return err
+	}
+	var collaterals map[string]json.RawMessage
+	if err := json.Unmarshal(root["collaterals"], &collaterals); err != nil {
+		return fmt.Errorf("collaterals: %w", err)
+	}
+	if err := checkKeys(collaterals, platformCollateralKeys["collaterals"], "collaterals."); err != nil {
+		return err
+	}
+	var certificates map[string]json.RawMessage
+	if err := json.Unmarshal(collaterals["certificates"], &certificates); err != nil {
+		return fmt.Errorf("collaterals.certificates: %w", err)
+	}
+	return checkKeys(certificates, platformCollateralKeys["certificates"], "collaterals.certificates.")
+}
+
+func checkKeys(object map[string]json.RawMessage, keys []string, prefix string) error {
+	for _, key := range keys {
+		switch strings.TrimSpace(string(object[key])) {
+		case "", "null", `""`, "[]", "{}":
+			return fmt.Errorf("missing required key %s%s", prefix, key)
+		}
+	}
+	return nil
+}
+
+type extensionEntry struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+// marshalSGXExtensions encodes the Intel SGX extensions of a PCK certificate
+func marshalSGXExtensions(p PCK) ([]byte, error) {
+	decode := func(value string, size int, name string) ([]byte, error) {
+		b, err := hex.DecodeString(value)
+		if err != nil || len(b) != size {
+			return nil, fmt.Errorf("invalid %s '%s'", name, value)
+		}
+		return b, nil
+	}
+	cpusvn, err := decode(p.CPUSVN, 16, "CPUSVN")
+	if err != nil {
+		return nil, err
+	}
+	pcesvn, err := decode(p.PCESVN, 2, "PCESVN")
+	if err != nil {
+		return nil, err
+	}
+	pceid, err := decode(p.PCEID, 2, "PCEID")
+	if err != nil {
+		return nil, err
+	}
+	fmspc, err := decode(p.FMSPC, 6, "FMSPC")
+	if err != nil {
+		return nil, err
+	}
+
+	var tcb []extensionEntry
+	for i, svn := range cpusvn {
+		tcb = append(tcb, entry(append(append(asn1.ObjectIdentifier{}, pckcert.OIDTCB...), i+1), int(svn)))
+	}
+	tcb = append(tcb,
+		entry(append(append(asn1.ObjectIdentifier{}, pckcert.OIDTCB...), 17), int(pcesvn[0])|int(pcesvn[1])<<8),
+		entry(append(append(asn1.ObjectIdentifier{}, pckcert.OIDTCB...), 18), cpusvn))
+
+	return asn1.Marshal([]extensionEntry{
+		entry(pckcert.OIDPPID, make([]byte, 16)),
+		entry(pckcert.OIDTCB, tcb),
+		entry(pckcert.OIDPCEID, pceid),
+		entry(pckcert.OIDFMSPC, fmspc),
+		entry(pckcert.OIDSGXType, asn1.Enumerated(0)),
+	})
+}
+
+func entry(id asn1.ObjectIdentifier, value any) extensionEntry {
+	b, err := asn1.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	return extensionEntry{ID: id, Value: asn1.RawValue{FullBytes: b}}
+}
+
+func (ca *CA) caTemplate(commonName string) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber:          ca.nextSerial(),
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"Intel Corporation"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+}
+
+func (ca *CA) nextSerial() *big.Int {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.serial++
+	return big.NewInt(ca.serial)
+}
+
+func (ca *CA) sign(template, parent *x509.Certificate, pub *ecdsa.PublicKey, priv *ecdsa.PrivateKey) (*x509.Certificate, []byte, error) {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate %s: %w", template.Subject.CommonName, err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	var buf bytes.Buffer
+	if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+		return nil, nil, err
+	}
+	return cert, buf.Bytes(), nil
+}
@@ -0,0 +1,218 @@
+// Package fakeplatform provides in-memory implementations of the SGX hardware
+// abstractions so the registration flow can run on machines without SGX.
+package fakeplatform
+
+import (
+	"context"
+	"sync"
+
+	mpmanagement "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_management"
+	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
+)
+
+// ManifestSource is an in-memory replacement for the SGX registration UEFI variables.
+// A non-empty Manifest or AddPackageRequest is the request pending in UEFI.
+type ManifestSource struct {
+	mu sync.Mutex
+
+	Registered        bool
+	Manifest          mpmanagement.PlatformManifest
+	AddPackageRequest mpmanagement.AddPackageRequest
+	ServerResponse    []byte
+
+	// Multi-package state returned by GetMultiPackageInfo
+	PackageInfoComplete bool
+	Packages            []mpmanagement.PackageKeyState
+
+	// SGX enablement returned by GetSgxEnablement
+	SgxEnablement *mpmanagement.SgxEnablement
+
+	// SgxRegistrationConfiguration content, absent when nil
+	RegistrationConfiguration *mpmanagement.RegistrationConfiguration
+
+	// Errors returned by the corresponding operations when set
+	IsMachineRegisteredErr  error
+	GetPlatformManifestErr  error
+	GetAddPackageRequestErr error
+	GetRequestTypeErr       error
+	SetServerResponseErr    error
+	CompleteErr             error
+	GetMultiPackageInfoErr  error
+	GetSgxEnablementErr     error
+
+	// CompleteLost makes CompleteMachineRegistrationStatus succeed without setting the registration flag,
+	// like a UEFI write that does not stick
+	CompleteLost bool
+
+	// Number of successful CompleteMachineRegistrationStatus calls
+	CompleteCalls int
+}
+
+// NewManifestSource creates an unregistered ManifestSource holding the given manifest
+func NewManifestSource(manifest mpmanagement.PlatformManifest) *ManifestSource {
+	return &ManifestSource{Manifest: manifest}
+}
+
+// IsMachineRegistered returns the in-memory registration flag
+func (f *ManifestSource) IsMachineRegistered() (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.IsMachineRegisteredErr != nil {
+		return false, f.IsMachineRegisteredErr
+	}
+	return f.Registered, nil
+}
+
+// GetMultiPackageInfo returns the in-memory multi-package state
+func (f *ManifestSource) GetMultiPackageInfo() (*mpmanagement.MultiPackageInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.GetMultiPackageInfoErr != nil {
+		return nil, f.GetMultiPackageInfoErr
+	}
+	return &mpmanagement.MultiPackageInfo{
+		RegistrationComplete: f.Registered,
+		PackageInfoComplete:  f.PackageInfoComplete,
+		PackageInfoAvailable: len(f.Packages) > 0,
+		Packages:             append([]mpmanagement.PackageKeyState(nil), f.Packages...),
+	}, nil
+}
+
+// GetSgxEnablement returns a copy of the in-memory SGX enablement, SGX being enabled when none is set
+func (f *ManifestSource) GetSgxEnablement() (*mpmanagement.SgxEnablement, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.GetSgxEnablementErr != nil {
+		return nil, f.GetSgxEnablementErr
+	}
+	if f.SgxEnablement == nil {
+		return &mpmanagement.SgxEnablement{State: mpmanagement.SgxBiosStateEnabled}, nil
+	}
+	enablement := *f.SgxEnablement
+	return &enablement, nil
+}
+
+// GetRegistrationConfiguration returns a copy of the in-memory registration configuration.
+// It reports mpmanagement.ErrNoPendingData when none is set.
+func (f *ManifestSource) GetRegistrationConfiguration() (*mpmanagement.RegistrationConfiguration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.RegistrationConfiguration == nil {
+		return nil, mpmanagement.ErrNoPendingData
+	}
+	config := *f.RegistrationConfiguration
+	return &config, nil
+}
+
+// GetPlatformManifest returns a copy of the in-memory platform manifest.
+// Like the UEFI backends, it reports mpmanagement.ErrNoPendingData when no manifest is pending.
+func (f *ManifestSource) GetPlatformManifest() (mpmanagement.PlatformManifest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.GetPlatformManifestErr != nil {
+		return nil, f.GetPlatformManifestErr
+	}
+	if f.Registered || len(f.Manifest) == 0 {
+		return nil, mpmanagement.ErrNoPendingData
+	}
+	return append(mpmanagement.PlatformManifest(nil), f.Manifest...), nil
+}
+
+// GetAddPackageRequest returns a copy of the in-memory AddPackage request.
+// It reports mpmanagement.ErrNoPendingData when no request is pending.
+func (f *ManifestSource) GetAddPackageRequest() (mpmanagement.AddPackageRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.GetAddPackageRequestErr != nil {
+		return nil, f.GetAddPackageRequestErr
+	}
+	if f.Registered || len(f.AddPackageRequest) == 0 {
+		return nil, mpmanagement.ErrNoPendingData
+	}
+	return append(mpmanagement.AddPackageRequest(nil), f.AddPackageRequest...), nil
+}
+
+// GetRequestType returns the type of the pending in-memory request, regardless of the registration flag
+func (f *ManifestSource) GetRequestType() (mpmanagement.RequestType, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.GetRequestTypeErr != nil {
+		return mpmanagement.RequestNone, f.GetRequestTypeErr
+	}
+	switch {
+	case len(f.Manifest) > 0:
+		return mpmanagement.RequestRegistration, nil
+	case len(f.AddPackageRequest) > 0:
+		return mpmanagement.RequestAddPackage, nil
+	default:
+		return mpmanagement.RequestNone, nil
+	}
+}
+
+// GetPendingRequest returns a copy of the pending in-memory request, regardless of the registration flag
+func (f *ManifestSource) GetPendingRequest() ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case len(f.Manifest) > 0:
+		return append([]byte(nil), f.Manifest...), nil
+	case len(f.AddPackageRequest) > 0:
+		return append([]byte(nil), f.AddPackageRequest...), nil
+	default:
+		return nil, mpmanagement.ErrNoPendingData
+	}
+}
+
+// SetServerResponse stores a copy of the response in memory
+func (f *ManifestSource) SetServerResponse(response []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.SetServerResponseErr != nil {
+		return f.SetServerResponseErr
+	}
+	f.ServerResponse = append([]byte(nil), response...)
+	return nil
+}
+
+// CompleteMachineRegistrationStatus sets the in-memory registration flag
+func (f *ManifestSource) CompleteMachineRegistrationStatus() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.CompleteErr != nil {
+		return f.CompleteErr
+	}
+	f.Registered = !f.CompleteLost
+	f.CompleteCalls++
+	return nil
+}
+
+// InfoProvider is an in-memory replacement for the SGX platform info enclave
+type InfoProvider struct {
+	mu sync.Mutex
+
+	Info *sgxplatforminfo.SgxPlatformInfo
+	Err  error
+
+	// Number of GetSgxPlatformInfo calls
+	Calls int
+}
+
+// NewInfoProvider creates an InfoProvider returning the given platform info
+func NewInfoProvider(info *sgxplatforminfo.SgxPlatformInfo) *InfoProvider {
+	return &InfoProvider{Info: info}
+}
+
+// GetSgxPlatformInfo returns a copy of the in-memory platform info
+func (f *InfoProvider) GetSgxPlatformInfo(ctx context.Context) (*sgxplatforminfo.SgxPlatformInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Calls++
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	info := *f.Info
+	return &info, nil
+}
@@ -1,21 +1,17 @@
 package mpmanagement
 
-/*
-#cgo CXXFLAGS: -std=c++17
-#cgo LDFLAGS: -lmp_management -lstdc++
-
-#include <stdlib.h>
-#include "../../../third_party/mp_management/src/include/c_wrapper/mp_management.h"
-*/
-import "C"
-
 import (
+	"errors"
 	"fmt"
+
+	mpuefi "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_uefi"
+	platformmanifest "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/platform_manifest"
 )
 
 // MPManagement constants
 const (
-	MPMaxRequestSize = 1024 * 56
+	MPMaxRequestSize  = 1024 * 56
+	MPMaxResponseSize = 1024 * 30
 
 	MPResultCodeSuccess            = 0
 	MPResultNoPendingData          = 1
@@ -32,90 +28,172 @@ const (
 	MPResultInsufficientPrivileges = 12
 )
 
-// MPManagement represents the Go wrapper for the mp_management functions
-type MPManagement struct {
-	initialized bool
-}
-
 type PlatformManifest []byte
 
-// NewMPManagement creates a new instance of MPManagement
-func NewMPManagement() *MPManagement {
+// AddPackageRequest is the AddRequest generated by the BIOS when a CPU package is added or replaced
+type AddPackageRequest []byte
+
+// RequestType is the type of the request pending in the SgxRegistrationServerRequest UEFI variable
+type RequestType = mpuefi.RequestType
+
+const (
+	RequestRegistration = mpuefi.RequestRegistration
+	RequestAddPackage   = mpuefi.RequestAddPackage
+	RequestNone         = mpuefi.RequestNone
+)
 
-	C.mp_management_init()
-	return &MPManagement{initialized: true}
+// ErrNoPendingData is returned when the requested data is not pending in the UEFI variables
+var ErrNoPendingData = mpuefi.ErrNoPendingData
+
+// MultiPackageInfo describes the CPU packages of the platform as published by the BIOS
+// in the SgxRegistrationStatus and SgxRegistrationPackageInfo UEFI variables
+type MultiPackageInfo struct {
+	// RegistrationComplete is the registrationStatus bit of SgxRegistrationStatus
+	RegistrationComplete bool
+	// PackageInfoComplete is the packageInfoStatus bit of SgxRegistrationStatus, set once the package info is consumed
+	PackageInfoComplete bool
+	// PackageInfoAvailable reports whether the SgxRegistrationPackageInfo variable is present
+	PackageInfoAvailable bool
+	// Packages holds the key state of each CPU package found in the package info
+	Packages []PackageKeyState
 }
 
-// Close terminates the MPManagement instance
-func (mp *MPManagement) Close() {
-	if mp.initialized {
-		C.mp_management_terminate()
-		mp.initialized = false
-	}
+// PackageCount returns the number of CPU packages found in the package info
+func (i *MultiPackageInfo) PackageCount() int {
+	return len(i.Packages)
 }
 
-// GetPlatformManifest retrieves the platform manifest by reading the UEFI SgxRegistrationServerRequest
-func (mp *MPManagement) GetPlatformManifest() (PlatformManifest, error) {
-	var size C.uint16_t = MPMaxRequestSize
-	buffer := make([]byte, size)
+// PackageKeyState is the state of the key blob of one CPU package
+type PackageKeyState struct {
+	Index       int
+	KeyBlobSize int
+	// Consumed reports whether the key blob has been consumed (package info status bit set)
+	Consumed bool
+}
 
-	operation_result := C.mp_management_get_platform_manifest((*C.uint8_t)(&buffer[0]), &size)
+// newMultiPackageInfo decodes the key blobs of the SgxRegistrationPackageInfo content (starting at its
+// StructureHeader). Key blobs are looked up at the top level and one level deep.
+func newMultiPackageInfo(registrationComplete, packageInfoComplete bool, packageInfo []byte) (*MultiPackageInfo, error) {
+	info := &MultiPackageInfo{
+		RegistrationComplete: registrationComplete,
+		PackageInfoComplete:  packageInfoComplete,
+		PackageInfoAvailable: packageInfo != nil,
+	}
+	if packageInfo == nil {
+		return info, nil
+	}
 
-	if operation_result != MPResultCodeSuccess {
-		return nil, fmt.Errorf("failed to get platform manifest uefi variable: %s", getErrorDescription(int(operation_result)))
+	structures, err := platformmanifest.ParseStructures(packageInfo)
+	if err != nil {
+		return info, fmt.Errorf("failed to parse package info: %w", err)
+	}
+	for _, s := range structures {
+		if s.GUID == platformmanifest.KeyBlobGUID {
+			info.addPackage(len(s.Data))
+			continue
+		}
+		nested, err := platformmanifest.ParseStructures(s.Data)
+		if err != nil {
+			continue
+		}
+		for _, n := range nested {
+			if n.GUID == platformmanifest.KeyBlobGUID {
+				info.addPackage(len(n.Data))
+			}
+		}
 	}
+	return info, nil
+}
 
-	return buffer[:size], nil
+func (i *MultiPackageInfo) addPackage(keyBlobSize int) {
+	i.Packages = append(i.Packages, PackageKeyState{
+		Index:       len(i.Packages),
+		KeyBlobSize: keyBlobSize,
+		Consumed:    i.PackageInfoComplete,
+	})
 }
 
-// IsMachineRegistered retrieves the machine registration status by reading the UEFI SgxRegistrationStatus.SgxRegistrationComplete variable flag
-func (mp *MPManagement) IsMachineRegistered() (bool, error) {
-	var status C.MpMachineRegistrationStatus
-	operation_result := C.mp_management_get_registration_status(&status)
-	if operation_result != MPResultCodeSuccess {
-		return false, fmt.Errorf("failed to get registration status uefi variable: %s", getErrorDescription(int(operation_result)))
-	}
-	return status == C.MP_MACHINE_REGISTERED, nil
+// SgxBiosState is the SGX setting of the BIOS
+type SgxBiosState = mpuefi.SgxBiosState
+
+const (
+	SgxBiosStateDisabled           = mpuefi.SgxBiosStateDisabled
+	SgxBiosStateEnabled            = mpuefi.SgxBiosStateEnabled
+	SgxBiosStateSoftwareControlled = mpuefi.SgxBiosStateSoftwareControlled
+	SgxBiosStateUnknown            = mpuefi.SgxBiosStateUnknown
+)
+
+// ErrEfivarsUnavailable is returned when the efivarfs file system is not mounted
+var ErrEfivarsUnavailable = mpuefi.ErrEfivarsUnavailable
+
+// SgxEnablement describes the SGX BIOS setting and the EPC/PRMRR configuration published in the
+// SOFTWAREGUARDSTATUS, EPCBIOS and EPCSW UEFI variables. Sizes are in MB.
+type SgxEnablement struct {
+	State              SgxBiosState `json:"state"`
+	EpcBiosAvailable   bool         `json:"epcBiosAvailable"`
+	MaxEpcSize         uint32       `json:"maxEpcSizeMB"`
+	EpcSize            uint32       `json:"epcSizeMB"`
+	PrmrrSize          uint32       `json:"prmrrSizeMB"`
+	RequestedEpcSize   uint32       `json:"requestedEpcSizeMB"`
+	RequestedPrmrrSize uint32       `json:"requestedPrmrrSizeMB"`
 }
 
-func getErrorDescription(operation_result int) string {
-	switch operation_result {
-	case MPResultCodeSuccess:
-		return "Code Success"
-	case MPResultNoPendingData:
-		return "No Pending Data"
-	case MPResultAlreadyRegistered:
-		return "Already Registered"
-	case MPResultMemoryError:
-		return "Memory Error"
-	case MPResultUefiInternalError:
-		return "Uefi Internal Error"
-	case MPResultUserInsufficientMemory:
-		return "User Insufficient Memory"
-	case MPResultInvalidParameter:
-		return "Invalid Parameter"
-	case MPResultSgxNotSupported:
-		return "Sgx Not Supported"
-	case MPResultUnexpectedError:
-		return "Unexpected Error"
-	case MPResultRedundantOperation:
-		return "Redundant Operation"
-	case MPResultNetworkError:
-		return "Network Error"
-	case MPResultNotInitialized:
-		return "NotInitialized"
-	case MPResultInsufficientPrivileges:
-		return "Insufficient Privileges"
-	default:
-		return "Unknown Error"
+// readSgxEnablement reads the SGX enablement UEFI variables. Missing variables are left empty,
+// while a missing efivarfs is reported with ErrEfivarsUnavailable.
+func readSgxEnablement(uefi *mpuefi.MPUefi) (*SgxEnablement, error) {
+	if err := uefi.CheckEfivars(); err != nil {
+		return nil, err
 	}
+
+	state, err := uefi.GetSgxBiosState()
+	if err != nil && !errors.Is(err, ErrNoPendingData) {
+		return nil, fmt.Errorf("failed to get software guard status uefi variable: %w", err)
+	}
+	enablement := &SgxEnablement{State: state}
+
+	epcBios, err := uefi.GetEpcBiosConfig()
+	switch {
+	case err == nil:
+		enablement.EpcBiosAvailable = true
+		enablement.MaxEpcSize = epcBios.MaxEpcSize
+		enablement.EpcSize = epcBios.EpcSize
+		enablement.PrmrrSize = epcBios.PrmSize(epcBios.EpcSize)
+	case !errors.Is(err, ErrNoPendingData):
+		return nil, fmt.Errorf("failed to get epc bios uefi variable: %w", err)
+	}
+
+	requestedEpcSize, err := uefi.GetRequestedEpcSize()
+	switch {
+	case err == nil:
+		enablement.RequestedEpcSize = requestedEpcSize
+		enablement.RequestedPrmrrSize = epcBios.PrmSize(requestedEpcSize)
+	case !errors.Is(err, ErrNoPendingData):
+		return nil, fmt.Errorf("failed to get epc sw uefi variable: %w", err)
+	}
+	return enablement, nil
 }
 
-// CompleteMachineRegistrationStatus sets the UEFI SgxRegistrationStatus.SgxRegistrationComplete flag to true
-func (mp *MPManagement) CompleteMachineRegistrationStatus() error {
-	operation_result := C.mp_management_set_registration_status_as_complete()
-	if operation_result != MPResultCodeSuccess {
-		return fmt.Errorf("failed to set the registration status uefi variable : %s", getErrorDescription(int(operation_result)))
+// RegistrationConfiguration is the content of the SgxRegistrationConfiguration UEFI variable
+type RegistrationConfiguration = mpuefi.Configuration
+
+// ProxyConf is the proxy configuration of the registration agent
+type ProxyConf = mpuefi.ProxyConf
+
+// ProxyType is the proxy setting of a ProxyConf
+type ProxyType = mpuefi.ProxyType
+
+const (
+	ProxyTypeDefault = mpuefi.ProxyTypeDefault
+	ProxyTypeDirect  = mpuefi.ProxyTypeDirect
+	ProxyTypeManual  = mpuefi.ProxyTypeManual
+)
+
+// readRegistrationConfiguration reads the SgxRegistrationConfiguration UEFI variable.
+// It returns ErrNoPendingData when the variable is absent.
+func readRegistrationConfiguration(uefi *mpuefi.MPUefi) (*RegistrationConfiguration, error) {
+	config, err := uefi.GetConfiguration()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registration configuration uefi variable: %w", err)
 	}
-	return nil
+	return &config, nil
 }
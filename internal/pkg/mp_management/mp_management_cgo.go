@@ -0,0 +1,209 @@
+//go:build sgx
+
+package mpmanagement
+
+/*
+#cgo CXXFLAGS: -std=c++17
+#cgo LDFLAGS: -lmp_management -lstdc++
+
+#include <stdlib.h>
+#include "../../../third_party/mp_management/src/include/c_wrapper/mp_management.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+
+	mpuefi "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_uefi"
+)
+
+// MPManagement represents the Go wrapper for the mp_management functions
+type MPManagement struct {
+	initialized bool
+}
+
+// NewMPManagement creates a new instance of MPManagement
+func NewMPManagement() *MPManagement {
+
+	C.mp_management_init()
+	return &MPManagement{initialized: true}
+}
+
+// Close terminates the MPManagement instance
+func (mp *MPManagement) Close() {
+	if mp.initialized {
+		C.mp_management_terminate()
+		mp.initialized = false
+	}
+}
+
+// GetPlatformManifest retrieves the platform manifest by reading the UEFI SgxRegistrationServerRequest
+func (mp *MPManagement) GetPlatformManifest() (PlatformManifest, error) {
+	var size C.uint16_t = MPMaxRequestSize
+	buffer := make([]byte, size)
+
+	operation_result := C.mp_management_get_platform_manifest((*C.uint8_t)(&buffer[0]), &size)
+
+	if operation_result != MPResultCodeSuccess {
+		return nil, fmt.Errorf("failed to get platform manifest uefi variable: %w", resultError(int(operation_result)))
+	}
+
+	return buffer[:size], nil
+}
+
+// GetAddPackageRequest retrieves the AddPackage request by reading the UEFI SgxRegistrationServerRequest
+func (mp *MPManagement) GetAddPackageRequest() (AddPackageRequest, error) {
+	var size C.uint16_t = MPMaxRequestSize
+	buffer := make([]byte, size)
+
+	operation_result := C.mp_management_get_add_package_request((*C.uint8_t)(&buffer[0]), &size)
+
+	if operation_result != MPResultCodeSuccess {
+		return nil, fmt.Errorf("failed to get add package request uefi variable: %w", resultError(int(operation_result)))
+	}
+
+	return buffer[:size], nil
+}
+
+// GetRequestType retrieves the type of the request pending in the UEFI SgxRegistrationServerRequest,
+// regardless of the registration status
+func (mp *MPManagement) GetRequestType() (RequestType, error) {
+	var requestType C.MpRequestType
+	operation_result := C.mp_management_get_request_type(&requestType)
+	if operation_result != MPResultCodeSuccess {
+		return RequestNone, fmt.Errorf("failed to get request type uefi variable: %w", resultError(int(operation_result)))
+	}
+	return RequestType(requestType), nil
+}
+
+// GetPendingRequest retrieves the request pending in the UEFI SgxRegistrationServerRequest,
+// regardless of the registration status
+func (mp *MPManagement) GetPendingRequest() ([]byte, error) {
+	var size C.uint16_t = MPMaxRequestSize
+	buffer := make([]byte, size)
+
+	operation_result := C.mp_management_get_pending_request((*C.uint8_t)(&buffer[0]), &size)
+
+	if operation_result != MPResultCodeSuccess {
+		return nil, fmt.Errorf("failed to get pending request uefi variable: %w", resultError(int(operation_result)))
+	}
+
+	return buffer[:size], nil
+}
+
+// SetServerResponse writes the registration server response to the UEFI SgxRegistrationServerResponse
+func (mp *MPManagement) SetServerResponse(response []byte) error {
+	if len(response) == 0 || len(response) > MPMaxResponseSize {
+		return fmt.Errorf("failed to set the server response uefi variable: invalid response size %d", len(response))
+	}
+
+	operation_result := C.mp_management_set_server_response((*C.uint8_t)(&response[0]), C.uint16_t(len(response)))
+	if operation_result != MPResultCodeSuccess {
+		return fmt.Errorf("failed to set the server response uefi variable: %w", resultError(int(operation_result)))
+	}
+	return nil
+}
+
+// IsMachineRegistered retrieves the machine registration status by reading the UEFI SgxRegistrationStatus.SgxRegistrationComplete variable flag
+func (mp *MPManagement) IsMachineRegistered() (bool, error) {
+	var status C.MpMachineRegistrationStatus
+	operation_result := C.mp_management_get_registration_status(&status)
+	if operation_result != MPResultCodeSuccess {
+		return false, fmt.Errorf("failed to get registration status uefi variable: %s", getErrorDescription(int(operation_result)))
+	}
+	return status == C.MP_MACHINE_REGISTERED, nil
+}
+
+// GetMultiPackageInfo retrieves the multi-package state from the UEFI SgxRegistrationStatus bits
+// and the SgxRegistrationPackageInfo variable
+func (mp *MPManagement) GetMultiPackageInfo() (*MultiPackageInfo, error) {
+	registered, err := mp.IsMachineRegistered()
+	if err != nil {
+		return nil, err
+	}
+
+	var packageInfoStatus C.MpMachineRegistrationStatus
+	operation_result := C.mp_management_get_package_info_status(&packageInfoStatus)
+	if operation_result != MPResultCodeSuccess {
+		return nil, fmt.Errorf("failed to get registration status uefi variable: %w", resultError(int(operation_result)))
+	}
+
+	var size C.uint16_t = MPMaxRequestSize
+	buffer := make([]byte, size)
+	var packageInfo []byte
+	operation_result = C.mp_management_get_package_info((*C.uint8_t)(&buffer[0]), &size)
+	switch operation_result {
+	case MPResultCodeSuccess:
+		packageInfo = buffer[:size]
+	case MPResultNoPendingData:
+	default:
+		return nil, fmt.Errorf("failed to get package info uefi variable: %w", resultError(int(operation_result)))
+	}
+
+	return newMultiPackageInfo(registered, packageInfoStatus == C.MP_MACHINE_REGISTERED, packageInfo)
+}
+
+// GetSgxEnablement retrieves the SGX BIOS setting and the EPC configuration from the UEFI
+// SOFTWAREGUARDSTATUS, EPCBIOS and EPCSW variables.
+// The mp_management library does not expose these variables, they are read from the default efivarfs.
+func (mp *MPManagement) GetSgxEnablement() (*SgxEnablement, error) {
+	return readSgxEnablement(mpuefi.NewMPUefi(mpuefi.DefaultEfivarsPath))
+}
+
+// GetRegistrationConfiguration retrieves the registration server URL, flags and proxy configuration
+// from the UEFI SgxRegistrationConfiguration.
+// The mp_management library does not expose this variable, it is read from the default efivarfs.
+func (mp *MPManagement) GetRegistrationConfiguration() (*RegistrationConfiguration, error) {
+	return readRegistrationConfiguration(mpuefi.NewMPUefi(mpuefi.DefaultEfivarsPath))
+}
+
+// resultError converts an MpResult into an error, preserving ErrNoPendingData
+func resultError(operation_result int) error {
+	if operation_result == MPResultNoPendingData {
+		return ErrNoPendingData
+	}
+	return errors.New(getErrorDescription(operation_result))
+}
+
+func getErrorDescription(operation_result int) string {
+	switch operation_result {
+	case MPResultCodeSuccess:
+		return "Code Success"
+	case MPResultNoPendingData:
+		return "No Pending Data"
+	case MPResultAlreadyRegistered:
+		return "Already Registered"
+	case MPResultMemoryError:
+		return "Memory Error"
+	case MPResultUefiInternalError:
+		return "Uefi Internal Error"
+	case MPResultUserInsufficientMemory:
+		return "User Insufficient Memory"
+	case MPResultInvalidParameter:
+		return "Invalid Parameter"
+	case MPResultSgxNotSupported:
+		return "Sgx Not Supported"
+	case MPResultUnexpectedError:
+		return "Unexpected Error"
+	case MPResultRedundantOperation:
+		return "Redundant Operation"
+	case MPResultNetworkError:
+		return "Network Error"
+	case MPResultNotInitialized:
+		return "NotInitialized"
+	case MPResultInsufficientPrivileges:
+		return "Insufficient Privileges"
+	default:
+		return "Unknown Error"
+	}
+}
+
+// CompleteMachineRegistrationStatus sets the UEFI SgxRegistrationStatus.SgxRegistrationComplete flag to true
+func (mp *MPManagement) CompleteMachineRegistrationStatus() error {
+	operation_result := C.mp_management_set_registration_status_as_complete()
+	if operation_result != MPResultCodeSuccess {
+		return fmt.Errorf("failed to set the registration status uefi variable : %s", getErrorDescription(int(operation_result)))
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package mpmanagement
+
+import (
+	"errors"
+	"fmt"
+
+	mpuefi "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_uefi"
+)
+
+// EfivarfsMPManagement implements the MPManagement operations natively in Go,
+// reading and writing the SGX registration UEFI variables through efivarfs
+type EfivarfsMPManagement struct {
+	uefi *mpuefi.MPUefi
+}
+
+// NewEfivarfsMPManagement creates a new EfivarfsMPManagement using the efivarfs mounted at efivarsPath
+func NewEfivarfsMPManagement(efivarsPath string) *EfivarfsMPManagement {
+	return &EfivarfsMPManagement{uefi: mpuefi.NewMPUefi(efivarsPath)}
+}
+
+// Close is a no-op, kept for parity with MPManagement
+func (mp *EfivarfsMPManagement) Close() {}
+
+// GetPlatformManifest retrieves the platform manifest by reading the UEFI SgxRegistrationServerRequest
+func (mp *EfivarfsMPManagement) GetPlatformManifest() (PlatformManifest, error) {
+	request, err := mp.getRequestData(mpuefi.RequestRegistration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get platform manifest uefi variable: %w", err)
+	}
+	return PlatformManifest(request), nil
+}
+
+// GetAddPackageRequest retrieves the AddPackage request by reading the UEFI SgxRegistrationServerRequest
+func (mp *EfivarfsMPManagement) GetAddPackageRequest() (AddPackageRequest, error) {
+	request, err := mp.getRequestData(mpuefi.RequestAddPackage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get add package request uefi variable: %w", err)
+	}
+	return AddPackageRequest(request), nil
+}
+
+// getRequestData returns the pending request if the machine is not registered yet
+// and the pending request has the expected type (MPManagement::getRequestData)
+func (mp *EfivarfsMPManagement) getRequestData(expectedType mpuefi.RequestType) ([]byte, error) {
+	status, err := mp.uefi.GetRegistrationStatus()
+	if err != nil {
+		return nil, err
+	}
+	if status.RegistrationComplete {
+		return nil, mpuefi.ErrNoPendingData
+	}
+
+	requestType, err := mp.uefi.GetRequestType()
+	if err != nil {
+		return nil, err
+	}
+	if requestType != expectedType {
+		return nil, mpuefi.ErrNoPendingData
+	}
+
+	request, err := mp.uefi.GetRequest()
+	if errors.Is(err, mpuefi.ErrNoPendingData) {
+		// the request disappeared between the type check and the read
+		return nil, fmt.Errorf("%w: %w", mpuefi.ErrUefiInternal, err)
+	}
+	return request, err
+}
+
+// GetRequestType retrieves the type of the request pending in the UEFI SgxRegistrationServerRequest,
+// regardless of the registration status
+func (mp *EfivarfsMPManagement) GetRequestType() (RequestType, error) {
+	requestType, err := mp.uefi.GetRequestType()
+	if err != nil {
+		return RequestNone, fmt.Errorf("failed to get request type uefi variable: %w", err)
+	}
+	return requestType, nil
+}
+
+// GetPendingRequest retrieves the request pending in the UEFI SgxRegistrationServerRequest,
+// regardless of the registration status
+func (mp *EfivarfsMPManagement) GetPendingRequest() ([]byte, error) {
+	request, err := mp.uefi.GetRequest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending request uefi variable: %w", err)
+	}
+	return request, nil
+}
+
+// SetServerResponse writes the registration server response to the UEFI SgxRegistrationServerResponse
+func (mp *EfivarfsMPManagement) SetServerResponse(response []byte) error {
+	if err := mp.uefi.SetServerResponse(response); err != nil {
+		return fmt.Errorf("failed to set the server response uefi variable: %w", err)
+	}
+	return nil
+}
+
+// IsMachineRegistered retrieves the machine registration status by reading the UEFI SgxRegistrationStatus.SgxRegistrationComplete variable flag
+func (mp *EfivarfsMPManagement) IsMachineRegistered() (bool, error) {
+	status, err := mp.uefi.GetRegistrationStatus()
+	if err != nil {
+		return false, fmt.Errorf("failed to get registration status uefi variable: %w", err)
+	}
+	return status.RegistrationComplete, nil
+}
+
+// GetMultiPackageInfo retrieves the multi-package state from the UEFI SgxRegistrationStatus bits
+// and the SgxRegistrationPackageInfo variable
+func (mp *EfivarfsMPManagement) GetMultiPackageInfo() (*MultiPackageInfo, error) {
+	status, err := mp.uefi.GetRegistrationStatus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registration status uefi variable: %w", err)
+	}
+
+	packageInfo, err := mp.uefi.GetPackageInfo()
+	if err != nil && !errors.Is(err, mpuefi.ErrNoPendingData) {
+		return nil, fmt.Errorf("failed to get package info uefi variable: %w", err)
+	}
+	return newMultiPackageInfo(status.RegistrationComplete, status.PackageInfoComplete, packageInfo)
+}
+
+// GetSgxEnablement retrieves the SGX BIOS setting and the EPC configuration from the UEFI
+// SOFTWAREGUARDSTATUS, EPCBIOS and EPCSW variables
+func (mp *EfivarfsMPManagement) GetSgxEnablement() (*SgxEnablement, error) {
+	return readSgxEnablement(mp.uefi)
+}
+
+// GetRegistrationConfiguration retrieves the registration server URL, flags and proxy configuration
+// from the UEFI SgxRegistrationConfiguration
+func (mp *EfivarfsMPManagement) GetRegistrationConfiguration() (*RegistrationConfiguration, error) {
+	return readRegistrationConfiguration(mp.uefi)
+}
+
+// CompleteMachineRegistrationStatus sets the UEFI SgxRegistrationStatus.SgxRegistrationComplete flag to true
+func (mp *EfivarfsMPManagement) CompleteMachineRegistrationStatus() error {
+	status, err := mp.uefi.GetRegistrationStatus()
+	if err != nil {
+		return fmt.Errorf("failed to set the registration status uefi variable : %w", err)
+	}
+	status.RegistrationComplete = true
+	if err := mp.uefi.SetRegistrationStatus(status); err != nil {
+		return fmt.Errorf("failed to set the registration status uefi variable : %w", err)
+	}
+	return nil
+}
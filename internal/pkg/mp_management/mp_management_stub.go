@@ -0,0 +1,72 @@
+//go:build !sgx
+
+package mpmanagement
+
+import (
+	"errors"
+)
+
+// ErrSgxSupportNotCompiled is returned by every operation when the binary was built without the sgx build tag
+var ErrSgxSupportNotCompiled = errors.New("mp_management: binary built without SGX support (sgx build tag)")
+
+// MPManagement is a placeholder used when the binary is built without the sgx build tag.
+// All operations fail with ErrSgxSupportNotCompiled.
+type MPManagement struct{}
+
+// NewMPManagement creates a new instance of MPManagement
+func NewMPManagement() *MPManagement {
+	return &MPManagement{}
+}
+
+// Close terminates the MPManagement instance
+func (mp *MPManagement) Close() {}
+
+// GetPlatformManifest always fails without SGX support
+func (mp *MPManagement) GetPlatformManifest() (PlatformManifest, error) {
+	return nil, ErrSgxSupportNotCompiled
+}
+
+// GetAddPackageRequest always fails without SGX support
+func (mp *MPManagement) GetAddPackageRequest() (AddPackageRequest, error) {
+	return nil, ErrSgxSupportNotCompiled
+}
+
+// GetRequestType always fails without SGX support
+func (mp *MPManagement) GetRequestType() (RequestType, error) {
+	return RequestNone, ErrSgxSupportNotCompiled
+}
+
+// GetPendingRequest always fails without SGX support
+func (mp *MPManagement) GetPendingRequest() ([]byte, error) {
+	return nil, ErrSgxSupportNotCompiled
+}
+
+// SetServerResponse always fails without SGX support
+func (mp *MPManagement) SetServerResponse(_ []byte) error {
+	return ErrSgxSupportNotCompiled
+}
+
+// IsMachineRegistered always fails without SGX support
+func (mp *MPManagement) IsMachineRegistered() (bool, error) {
+	return false, ErrSgxSupportNotCompiled
+}
+
+// GetMultiPackageInfo always fails without SGX support
+func (mp *MPManagement) GetMultiPackageInfo() (*MultiPackageInfo, error) {
+	return nil, ErrSgxSupportNotCompiled
+}
+
+// GetSgxEnablement always fails without SGX support
+func (mp *MPManagement) GetSgxEnablement() (*SgxEnablement, error) {
+	return nil, ErrSgxSupportNotCompiled
+}
+
+// GetRegistrationConfiguration always fails without SGX support
+func (mp *MPManagement) GetRegistrationConfiguration() (*RegistrationConfiguration, error) {
+	return nil, ErrSgxSupportNotCompiled
+}
+
+// CompleteMachineRegistrationStatus always fails without SGX support
+func (mp *MPManagement) CompleteMachineRegistrationStatus() error {
+	return ErrSgxSupportNotCompiled
+}
@@ -0,0 +1,62 @@
+package mpmanagement
+
+import (
+	"testing"
+
+	platformmanifest "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/platform_manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMultiPackageInfo(t *testing.T) {
+	keyBlob := platformmanifest.NewStructure(platformmanifest.KeyBlobGUID, make([]byte, 96))
+	nested, err := platformmanifest.New(platformmanifest.PlatformManifestGUID, keyBlob, keyBlob).Marshal()
+	assert.NoError(t, err)
+	flat := append(append([]byte(nil), nested[platformmanifest.HeaderSize:]...), nested[platformmanifest.HeaderSize:]...)
+
+	cases := []struct {
+		msg                 string
+		packageInfoComplete bool
+		packageInfo         []byte
+		wantedAvailable     bool
+		wantedPackages      int
+		wantedConsumed      bool
+		wantedErr           bool
+	}{
+		{
+			msg: "missing package info",
+		},
+		{
+			msg:             "key blobs nested in a structure",
+			packageInfo:     nested,
+			wantedAvailable: true,
+			wantedPackages:  2,
+		},
+		{
+			msg:                 "consumed top-level key blobs",
+			packageInfoComplete: true,
+			packageInfo:         flat,
+			wantedAvailable:     true,
+			wantedPackages:      4,
+			wantedConsumed:      true,
+		},
+		{
+			msg:             "malformed package info",
+			packageInfo:     nested[:len(nested)-1],
+			wantedAvailable: true,
+			wantedErr:       true,
+		},
+	}
+
+	for _, c := range cases {
+		info, err := newMultiPackageInfo(true, c.packageInfoComplete, c.packageInfo)
+		assert.Equal(t, c.wantedErr, err != nil, c.msg)
+		assert.True(t, info.RegistrationComplete, c.msg)
+		assert.Equal(t, c.wantedAvailable, info.PackageInfoAvailable, c.msg)
+		assert.Equal(t, c.wantedPackages, info.PackageCount(), c.msg)
+		for i, p := range info.Packages {
+			assert.Equal(t, i, p.Index, c.msg)
+			assert.Equal(t, 96, p.KeyBlobSize, c.msg)
+			assert.Equal(t, c.wantedConsumed, p.Consumed, c.msg)
+		}
+	}
+}
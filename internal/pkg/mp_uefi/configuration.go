@@ -0,0 +1,122 @@
+package mpuefi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// MAX_URL_SIZE from UefiVar.h
+	maxURLSize = 256
+	// MAX_PATH_SIZE from MultiPackageDefs.h
+	maxProxyURLSize = 256
+
+	// ConfigurationUEFI: version, size, flags (uint16), headerInfo, urlSize (uint16), url, headerId
+	configurationFlagsOffset    = uefiVarHeaderSize
+	configurationURLSizeOffset  = configurationFlagsOffset + 2 + StructureHeaderSize
+	configurationURLOffset      = configurationURLSizeOffset + 2
+	configurationHeaderIDOffset = configurationURLOffset + maxURLSize
+	configurationSize           = configurationHeaderIDOffset + StructureHeaderSize
+
+	// ProxyConf: proxy_type (enum) + proxy_url
+	proxyConfSize = 4 + maxProxyURLSize
+
+	// ServerInfoFlagRSNotSaveKeys is set when the registration server does not save the platform keys
+	// (SERVER_INFO_FLAG_RS_NOT_SAVE_KEYS), i.e. the platform is set up for indirect registration
+	ServerInfoFlagRSNotSaveKeys = 0x0001
+)
+
+// ProxyType is the proxy setting of a ProxyConf
+type ProxyType uint32
+
+const (
+	ProxyTypeDefault ProxyType = 0 // MP_REG_PROXY_TYPE_DEFAULT_PROXY
+	ProxyTypeDirect  ProxyType = 1 // MP_REG_PROXY_TYPE_DIRECT_ACCESS
+	ProxyTypeManual  ProxyType = 2 // MP_REG_PROXY_TYPE_MANUAL_PROXY
+)
+
+func (t ProxyType) String() string {
+	switch t {
+	case ProxyTypeDefault:
+		return "default"
+	case ProxyTypeDirect:
+		return "direct"
+	case ProxyTypeManual:
+		return "manual"
+	default:
+		return "unknown"
+	}
+}
+
+// ProxyConf is the proxy configuration of the registration agent
+type ProxyConf struct {
+	Type ProxyType
+	URL  string
+}
+
+// Configuration is the content of the SgxRegistrationConfiguration variable
+type Configuration struct {
+	Flags uint16
+	// URL of the registration server
+	URL string
+	// ServerID is the content of the structure following the headerId StructureHeader
+	ServerID []byte
+	// Proxy is the ProxyConf following the server ID, nil when the variable holds none
+	Proxy *ProxyConf
+}
+
+// RSNotSaveKeys reports whether the SERVER_INFO_FLAG_RS_NOT_SAVE_KEYS flag is set
+func (c Configuration) RSNotSaveKeys() bool {
+	return c.Flags&ServerInfoFlagRSNotSaveKeys != 0
+}
+
+// GetConfiguration reads the SgxRegistrationConfiguration variable.
+// It returns ErrNoPendingData when the variable is absent.
+func (u *MPUefi) GetConfiguration() (Configuration, error) {
+	data, err := u.readRawVar(VarConfiguration, configurationSize)
+	if err != nil {
+		return Configuration{}, err
+	}
+
+	version := binary.LittleEndian.Uint16(data[0:2])
+	if version != BiosUefiVariableVersion1 && version != BiosUefiVariableVersion2 {
+		return Configuration{}, fmt.Errorf("%w: unsupported configuration variable version %d", ErrUefiInternal, version)
+	}
+
+	urlSize := int(binary.LittleEndian.Uint16(data[configurationURLSizeOffset:]))
+	if urlSize > maxURLSize {
+		return Configuration{}, fmt.Errorf("%w: configuration url size %d exceeds %d", ErrUefiInternal, urlSize, maxURLSize)
+	}
+	// the URL size is rounded up to a multiple of 16; the URL is not null terminated but may be padded
+	url := data[configurationURLOffset : configurationURLOffset+urlSize]
+	if end := bytes.IndexByte(url, 0); end >= 0 {
+		url = url[:end]
+	}
+
+	config := Configuration{
+		Flags: binary.LittleEndian.Uint16(data[configurationFlagsOffset:]),
+		URL:   string(url),
+	}
+
+	rest := data[configurationSize:]
+	serverIDSize := int(binary.LittleEndian.Uint16(data[configurationHeaderIDOffset+GUIDSize:]))
+	if serverIDSize > len(rest) {
+		return Configuration{}, fmt.Errorf("%w: configuration server id size %d exceeds the %d available bytes",
+			ErrUefiInternal, serverIDSize, len(rest))
+	}
+	config.ServerID = rest[:serverIDSize]
+	rest = rest[serverIDSize:]
+
+	if len(rest) >= proxyConfSize {
+		proxyURL := rest[4:proxyConfSize]
+		if end := bytes.IndexByte(proxyURL, 0); end >= 0 {
+			proxyURL = proxyURL[:end]
+		}
+		config.Proxy = &ProxyConf{
+			Type: ProxyType(binary.LittleEndian.Uint32(rest[0:4])),
+			URL:  string(proxyURL),
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,81 @@
+package mpuefi
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestConfiguration(flags uint16, url string, serverID []byte, proxy *ProxyConf) []byte {
+	data := binary.LittleEndian.AppendUint16(nil, BiosUefiVariableVersion1)
+	data = binary.LittleEndian.AppendUint16(data, 0)
+	data = binary.LittleEndian.AppendUint16(data, flags)
+	data = append(data, make([]byte, StructureHeaderSize)...)
+	// the URL size is rounded up to a multiple of 16
+	data = binary.LittleEndian.AppendUint16(data, uint16((len(url)+15)/16*16))
+	urlField := make([]byte, maxURLSize)
+	copy(urlField, url)
+	data = append(data, urlField...)
+
+	headerID := make([]byte, StructureHeaderSize)
+	binary.LittleEndian.PutUint16(headerID[GUIDSize:], uint16(len(serverID)))
+	data = append(data, headerID...)
+	data = append(data, serverID...)
+
+	if proxy != nil {
+		data = binary.LittleEndian.AppendUint32(data, uint32(proxy.Type))
+		proxyURL := make([]byte, maxProxyURLSize)
+		copy(proxyURL, proxy.URL)
+		data = append(data, proxyURL...)
+	}
+	binary.LittleEndian.PutUint16(data[2:4], uint16(len(data)-uefiVarHeaderSize))
+	return data
+}
+
+func TestGetConfiguration(t *testing.T) {
+	root := t.TempDir()
+	uefi := NewMPUefi(root)
+
+	_, err := uefi.GetConfiguration()
+	assert.ErrorIs(t, err, ErrNoPendingData, "missing configuration variable")
+
+	writeTestVar(t, root, VarConfiguration, defaultAttributes,
+		newTestConfiguration(0, "https://api.trustedservices.intel.com", []byte{0x01, 0x02}, nil))
+	config, err := uefi.GetConfiguration()
+	assert.NoError(t, err)
+	assert.Equal(t, "https://api.trustedservices.intel.com", config.URL, "padding is stripped")
+	assert.False(t, config.RSNotSaveKeys())
+	assert.Equal(t, []byte{0x01, 0x02}, config.ServerID)
+	assert.Nil(t, config.Proxy)
+
+	writeTestVar(t, root, VarConfiguration, defaultAttributes,
+		newTestConfiguration(ServerInfoFlagRSNotSaveKeys, "https://rs.example.com", nil,
+			&ProxyConf{Type: ProxyTypeManual, URL: "http://proxy.example.com:3128"}))
+	config, err = uefi.GetConfiguration()
+	assert.NoError(t, err)
+	assert.True(t, config.RSNotSaveKeys())
+	assert.Equal(t, &ProxyConf{Type: ProxyTypeManual, URL: "http://proxy.example.com:3128"}, config.Proxy)
+}
+
+func TestGetConfigurationInvalid(t *testing.T) {
+	root := t.TempDir()
+	uefi := NewMPUefi(root)
+
+	valid := newTestConfiguration(0, "https://rs.example.com", make([]byte, 16), nil)
+
+	cases := []struct {
+		msg  string
+		data []byte
+	}{
+		{msg: "truncated", data: valid[:configurationSize-1]},
+		{msg: "unsupported version", data: append([]byte{3, 0}, valid[2:]...)},
+		{msg: "server id overflow", data: valid[:len(valid)-1]},
+	}
+
+	for _, c := range cases {
+		writeTestVar(t, root, VarConfiguration, defaultAttributes, c.data)
+		_, err := uefi.GetConfiguration()
+		assert.ErrorIs(t, err, ErrUefiInternal, c.msg)
+	}
+}
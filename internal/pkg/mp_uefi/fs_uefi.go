@@ -0,0 +1,120 @@
+package mpuefi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// DefaultEfivarsPath is the mount point of the efivarfs file system
+	DefaultEfivarsPath = "/sys/firmware/efi/efivars"
+
+	// Every efivarfs file starts with the 4-byte little-endian variable attributes
+	attributeSize = 4
+
+	// EFI variable attributes
+	efiVariableNonVolatile       = 0x00000001
+	efiVariableBootServiceAccess = 0x00000002
+	efiVariableRuntimeAccess     = 0x00000004
+
+	// Attributes set on every variable written by the agent
+	defaultAttributes = efiVariableNonVolatile | efiVariableBootServiceAccess | efiVariableRuntimeAccess
+)
+
+// ErrVariableNotWritable is returned when writing to a UEFI variable that is not non-volatile
+var ErrVariableNotWritable = errors.New("uefi variable is not non-volatile")
+
+// FSUefi reads and writes UEFI variables through the efivarfs file system (Go port of FSUefi.cpp)
+type FSUefi struct {
+	root string
+}
+
+// NewFSUefi creates a new FSUefi rooted at the given efivarfs path
+func NewFSUefi(root string) *FSUefi {
+	if root == "" {
+		root = DefaultEfivarsPath
+	}
+	return &FSUefi{root: root}
+}
+
+func (u *FSUefi) path(varName string) string {
+	return filepath.Join(u.root, varName)
+}
+
+// ReadVar returns the content of a UEFI variable without the attribute prefix.
+// A missing variable is reported with an error wrapping fs.ErrNotExist.
+func (u *FSUefi) ReadVar(varName string) ([]byte, error) {
+	raw, err := os.ReadFile(u.path(varName))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < attributeSize {
+		return nil, fmt.Errorf("uefi variable %s is shorter than its attribute prefix", varName)
+	}
+	return raw[attributeSize:], nil
+}
+
+// WriteVar writes the content of a UEFI variable, prefixed with the default attributes.
+// The immutable flag set by the kernel on efivarfs files is removed before writing.
+// When create is false, the variable must already exist.
+func (u *FSUefi) WriteVar(varName string, data []byte, create bool) error {
+	varPath := u.path(varName)
+
+	file, err := os.Open(varPath)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) || !create {
+			return err
+		}
+		file, err = os.OpenFile(varPath, os.O_RDONLY|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+	} else {
+		var attributes [attributeSize]byte
+		if _, err := io.ReadFull(file, attributes[:]); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to read attributes of uefi variable %s: %w", varName, err)
+		}
+		if binary.LittleEndian.Uint32(attributes[:])&efiVariableNonVolatile == 0 {
+			file.Close()
+			return ErrVariableNotWritable
+		}
+	}
+
+	onEfivarfs := isEfivarfs(file)
+	err = clearImmutable(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to remove immutable flag of uefi variable %s: %w", varName, err)
+	}
+
+	buffer := make([]byte, attributeSize+len(data))
+	binary.LittleEndian.PutUint32(buffer, defaultAttributes)
+	copy(buffer[attributeSize:], data)
+
+	// efivarfs replaces the whole variable on write; regular files (e.g. in tests) must be truncated
+	flags := os.O_WRONLY
+	if !onEfivarfs {
+		flags |= os.O_TRUNC
+	}
+	file, err = os.OpenFile(varPath, flags, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// efivarfs requires the attributes and the data in a single write
+	written, err := file.Write(buffer)
+	if err != nil {
+		return err
+	}
+	if written != len(buffer) {
+		return fmt.Errorf("short write to uefi variable %s: %d of %d bytes", varName, written, len(buffer))
+	}
+	return nil
+}
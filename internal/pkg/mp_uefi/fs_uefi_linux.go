@@ -0,0 +1,37 @@
+package mpuefi
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// FS_IMMUTABLE_FL from linux/fs.h
+const fsImmutableFlag = 0x00000010
+
+// isEfivarfs reports whether the file lives on an efivarfs mount
+func isEfivarfs(file *os.File) bool {
+	var stat unix.Statfs_t
+	if err := unix.Fstatfs(int(file.Fd()), &stat); err != nil {
+		return false
+	}
+	return uint32(stat.Type) == unix.EFIVARFS_MAGIC
+}
+
+// clearImmutable removes the FS_IMMUTABLE_FL inode flag if it is set
+func clearImmutable(file *os.File) error {
+	fd := int(file.Fd())
+	flags, err := unix.IoctlGetUint32(fd, unix.FS_IOC_GETFLAGS)
+	if err != nil {
+		// file systems without inode flags cannot hold an immutable file
+		if errors.Is(err, unix.ENOTTY) || errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EINVAL) {
+			return nil
+		}
+		return err
+	}
+	if flags&fsImmutableFlag == 0 {
+		return nil
+	}
+	return unix.IoctlSetPointerInt(fd, unix.FS_IOC_SETFLAGS, int(flags&^fsImmutableFlag))
+}
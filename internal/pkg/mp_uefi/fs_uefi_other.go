@@ -0,0 +1,17 @@
+//go:build !linux
+
+package mpuefi
+
+import (
+	"os"
+)
+
+// isEfivarfs reports whether the file lives on an efivarfs mount, which only exists on Linux
+func isEfivarfs(_ *os.File) bool {
+	return false
+}
+
+// clearImmutable is a no-op outside Linux
+func clearImmutable(_ *os.File) error {
+	return nil
+}
@@ -0,0 +1,237 @@
+// Package mpuefi is a native Go implementation of the Multi-Package UEFI interface
+// (MPUefi/FSUefi) used to read and write the SGX registration UEFI variables.
+package mpuefi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// UEFI variable names (name and vendor GUID) as defined in UefiVar.h
+const (
+	VarConfiguration       = "SgxRegistrationConfiguration-18b3bc81-e210-42b9-9ec8-2c5a7d4d89b6"
+	VarServerRequest       = "SgxRegistrationServerRequest-304e0796-d515-4698-ac6e-e76cb1a71c28"
+	VarServerResponse      = "SgxRegistrationServerResponse-89589c7b-b2d9-4fc9-bcda-463b983b2fb7"
+	VarPackageInfo         = "SgxRegistrationPackageInfo-ac406deb-ab92-42d6-aff7-0d78e0826c68"
+	VarStatus              = "SgxRegistrationStatus-f236c5dc-a491-4bbe-bcdd-88885770df45"
+	VarEpcBios             = "EPCBIOS-c60aa7f6-e8d6-4956-8ba1-fe26298f5e87"
+	VarEpcSw               = "EPCSW-d69a279b-58eb-45d1-a148-771bb9eb5251"
+	VarSoftwareGuardStatus = "SOFTWAREGUARDSTATUS-9cb2e73f-7325-40f4-a484-659bb344c3cd"
+)
+
+const (
+	GUIDSize = 16
+
+	BiosUefiVariableVersion1 = 1
+	BiosUefiVariableVersion2 = 2
+
+	// version (uint16) + size (uint16) preceding the data of the SGX UEFI variables
+	uefiVarHeaderSize = 4
+	// size of the StructureHeader (GUID, size, version, reserved)
+	StructureHeaderSize = GUIDSize + 2 + 2 + 12
+
+	// RegistrationStatusUEFI: version (uint16) + size (uint16) + status (uint16) + errorCode (uint8)
+	registrationStatusSize     = 7
+	registrationStatusDataSize = 3
+
+	// MAX_RESPONSE_SIZE from MultiPackageDefs.h
+	MaxResponseSize = 1024 * 30
+
+	registrationCompleteBitMask = 0x0001
+	packageInfoCompleteBitMask  = 0x0002
+)
+
+// GUIDs of the structures found in the SgxRegistrationServerRequest variable
+var (
+	PlatformManifestGUID = [GUIDSize]byte{0x17, 0x8E, 0x87, 0x4B, 0x49, 0xE4, 0x4A, 0xA5, 0x99, 0xBB, 0x30, 0x57, 0x17, 0x09, 0x25, 0xB4}
+	AddRequestGUID       = [GUIDSize]byte{0x69, 0x65, 0x19, 0xca, 0x73, 0xc1, 0x47, 0x85, 0xa0, 0xf6, 0x4d, 0x28, 0x9d, 0x37, 0xe9, 0x95}
+)
+
+var (
+	// ErrNoPendingData is returned when there is no pending request in the UEFI variables
+	ErrNoPendingData = errors.New("no pending data")
+	// ErrUefiInternal is returned when a UEFI variable is missing or malformed
+	ErrUefiInternal = errors.New("uefi internal error")
+	// ErrInvalidParameter is returned when an argument is out of range
+	ErrInvalidParameter = errors.New("invalid parameter")
+	// ErrInsufficientPrivileges is returned when a UEFI variable cannot be written
+	ErrInsufficientPrivileges = errors.New("insufficient privileges")
+)
+
+// RequestType is the type of the pending request in the SgxRegistrationServerRequest variable
+type RequestType int
+
+const (
+	RequestRegistration RequestType = iota // MP_REQ_REGISTRATION: PlatformManifest
+	RequestAddPackage                      // MP_REQ_ADD_PACKAGE: AddRequest
+	RequestNone                            // MP_REQ_NONE
+)
+
+func (t RequestType) String() string {
+	switch t {
+	case RequestRegistration:
+		return "PlatformManifest"
+	case RequestAddPackage:
+		return "AddPackage"
+	case RequestNone:
+		return "None"
+	default:
+		return "Unknown"
+	}
+}
+
+// RegistrationStatus is the content of the SgxRegistrationStatus variable
+type RegistrationStatus struct {
+	RegistrationComplete bool
+	PackageInfoComplete  bool
+	ErrorCode            uint8
+}
+
+// MPUefi gives access to the SGX registration UEFI variables (Go port of MPUefi.cpp)
+type MPUefi struct {
+	uefi *FSUefi
+}
+
+// NewMPUefi creates a new MPUefi using the efivarfs mounted at efivarsPath
+func NewMPUefi(efivarsPath string) *MPUefi {
+	return &MPUefi{uefi: NewFSUefi(efivarsPath)}
+}
+
+// readRequest reads and validates the SgxRegistrationServerRequest variable.
+// It returns the request starting at its StructureHeader.
+func (u *MPUefi) readRequest() ([]byte, error) {
+	return u.readSgxUefiVar(VarServerRequest)
+}
+
+// readSgxUefiVar reads and validates a variable holding a SgxUefiVar (version, size, StructureHeader).
+// It returns the content starting at its StructureHeader.
+func (u *MPUefi) readSgxUefiVar(varName string) ([]byte, error) {
+	data, err := u.uefi.ReadVar(varName)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNoPendingData
+		}
+		return nil, fmt.Errorf("%w: %w", ErrUefiInternal, err)
+	}
+	if len(data) < uefiVarHeaderSize+StructureHeaderSize {
+		return nil, fmt.Errorf("%w: variable %s too short (%d bytes)", ErrUefiInternal, varName, len(data))
+	}
+
+	version := binary.LittleEndian.Uint16(data[0:2])
+	if version != BiosUefiVariableVersion1 && version != BiosUefiVariableVersion2 {
+		return nil, fmt.Errorf("%w: unsupported variable %s version %d", ErrUefiInternal, varName, version)
+	}
+
+	size := int(binary.LittleEndian.Uint16(data[2:4]))
+	if len(data) != uefiVarHeaderSize+size {
+		return nil, fmt.Errorf("%w: variable %s size %d does not match its content (%d bytes)",
+			ErrUefiInternal, varName, size, len(data)-uefiVarHeaderSize)
+	}
+	return data[uefiVarHeaderSize:], nil
+}
+
+// GetRequestType returns the type of the pending request, or RequestNone when there is none
+func (u *MPUefi) GetRequestType() (RequestType, error) {
+	request, err := u.readRequest()
+	if err != nil {
+		if errors.Is(err, ErrNoPendingData) {
+			return RequestNone, nil
+		}
+		return RequestNone, err
+	}
+
+	guid := request[:GUIDSize]
+	switch {
+	case bytes.Equal(guid, PlatformManifestGUID[:]):
+		return RequestRegistration, nil
+	case bytes.Equal(guid, AddRequestGUID[:]):
+		return RequestAddPackage, nil
+	default:
+		return RequestNone, fmt.Errorf("%w: unknown request GUID %x", ErrUefiInternal, guid)
+	}
+}
+
+// GetRequest returns the content of the pending request, to be sent to the SGX Registration Server
+func (u *MPUefi) GetRequest() ([]byte, error) {
+	return u.readRequest()
+}
+
+// GetPackageInfo returns the content of the SgxRegistrationPackageInfo variable, in which the BIOS
+// publishes the key blobs of the CPU packages. It returns ErrNoPendingData when the variable is absent.
+func (u *MPUefi) GetPackageInfo() ([]byte, error) {
+	return u.readSgxUefiVar(VarPackageInfo)
+}
+
+// GetRegistrationStatus reads the SgxRegistrationStatus variable
+func (u *MPUefi) GetRegistrationStatus() (RegistrationStatus, error) {
+	data, err := u.uefi.ReadVar(VarStatus)
+	if err != nil {
+		return RegistrationStatus{}, fmt.Errorf("%w: %w", ErrUefiInternal, err)
+	}
+	if len(data) != registrationStatusSize {
+		return RegistrationStatus{}, fmt.Errorf("%w: unexpected status variable size %d", ErrUefiInternal, len(data))
+	}
+
+	if version := binary.LittleEndian.Uint16(data[0:2]); version != BiosUefiVariableVersion1 {
+		return RegistrationStatus{}, fmt.Errorf("%w: unsupported status variable version %d", ErrUefiInternal, version)
+	}
+	if size := binary.LittleEndian.Uint16(data[2:4]); size != registrationStatusDataSize {
+		return RegistrationStatus{}, fmt.Errorf("%w: unexpected status structure size %d", ErrUefiInternal, size)
+	}
+
+	status := binary.LittleEndian.Uint16(data[4:6])
+	return RegistrationStatus{
+		RegistrationComplete: status&registrationCompleteBitMask != 0,
+		PackageInfoComplete:  status&packageInfoCompleteBitMask != 0,
+		ErrorCode:            data[6],
+	}, nil
+}
+
+// SetRegistrationStatus writes the SgxRegistrationStatus variable
+func (u *MPUefi) SetRegistrationStatus(status RegistrationStatus) error {
+	data := make([]byte, registrationStatusSize)
+	binary.LittleEndian.PutUint16(data[0:2], BiosUefiVariableVersion1)
+	binary.LittleEndian.PutUint16(data[2:4], registrationStatusDataSize)
+
+	var bits uint16
+	if status.RegistrationComplete {
+		bits |= registrationCompleteBitMask
+	}
+	if status.PackageInfoComplete {
+		bits |= packageInfoCompleteBitMask
+	}
+	binary.LittleEndian.PutUint16(data[4:6], bits)
+	data[6] = status.ErrorCode
+
+	return u.writeVar(VarStatus, data, false)
+}
+
+// SetServerResponse writes the SGX Registration Server response to the SgxRegistrationServerResponse
+// variable, creating it if needed, so the BIOS can consume it on the next boot
+func (u *MPUefi) SetServerResponse(response []byte) error {
+	if len(response) == 0 || len(response) > MaxResponseSize {
+		return fmt.Errorf("%w: response size %d", ErrInvalidParameter, len(response))
+	}
+
+	data := make([]byte, uefiVarHeaderSize, uefiVarHeaderSize+len(response))
+	binary.LittleEndian.PutUint16(data[0:2], BiosUefiVariableVersion1)
+	binary.LittleEndian.PutUint16(data[2:4], uint16(len(response)))
+	data = append(data, response...)
+
+	return u.writeVar(VarServerResponse, data, true)
+}
+
+// writeVar writes a UEFI variable and classifies the failure like MPUefi.cpp
+func (u *MPUefi) writeVar(varName string, data []byte, create bool) error {
+	err := u.uefi.WriteVar(varName, data, create)
+	if err != nil {
+		if errors.Is(err, ErrVariableNotWritable) || errors.Is(err, fs.ErrPermission) {
+			return fmt.Errorf("%w: %w", ErrInsufficientPrivileges, err)
+		}
+		return fmt.Errorf("%w: %w", ErrUefiInternal, err)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package mpuefi
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestVar(t *testing.T, root, name string, attributes uint32, data []byte) {
+	t.Helper()
+	raw := binary.LittleEndian.AppendUint32(nil, attributes)
+	raw = append(raw, data...)
+	if err := os.WriteFile(filepath.Join(root, name), raw, 0644); err != nil {
+		t.Fatalf("failed to write test variable: %v", err)
+	}
+}
+
+func newTestRequest(version uint16, guid [GUIDSize]byte, payloadSize int) []byte {
+	structure := make([]byte, StructureHeaderSize+payloadSize)
+	copy(structure, guid[:])
+	binary.LittleEndian.PutUint16(structure[GUIDSize:], uint16(payloadSize))
+	binary.LittleEndian.PutUint16(structure[GUIDSize+2:], 1)
+
+	data := binary.LittleEndian.AppendUint16(nil, version)
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(structure)))
+	return append(data, structure...)
+}
+
+func newTestStatus(status uint16, errorCode uint8) []byte {
+	data := binary.LittleEndian.AppendUint16(nil, BiosUefiVariableVersion1)
+	data = binary.LittleEndian.AppendUint16(data, registrationStatusDataSize)
+	data = binary.LittleEndian.AppendUint16(data, status)
+	return append(data, errorCode)
+}
+
+func TestGetRequestType(t *testing.T) {
+	cases := []struct {
+		msg          string
+		request      []byte
+		wantedType   RequestType
+		wantedErrIs  error
+		wantedErrNil bool
+	}{
+		{
+			msg:          "missing request variable means no pending request",
+			wantedType:   RequestNone,
+			wantedErrNil: true,
+		},
+		{
+			msg:          "platform manifest request",
+			request:      newTestRequest(BiosUefiVariableVersion2, PlatformManifestGUID, 64),
+			wantedType:   RequestRegistration,
+			wantedErrNil: true,
+		},
+		{
+			msg:          "add package request",
+			request:      newTestRequest(BiosUefiVariableVersion1, AddRequestGUID, 64),
+			wantedType:   RequestAddPackage,
+			wantedErrNil: true,
+		},
+		{
+			msg:         "unsupported version",
+			request:     newTestRequest(3, PlatformManifestGUID, 64),
+			wantedType:  RequestNone,
+			wantedErrIs: ErrUefiInternal,
+		},
+		{
+			msg:         "unknown GUID",
+			request:     newTestRequest(BiosUefiVariableVersion1, [GUIDSize]byte{0x01}, 64),
+			wantedType:  RequestNone,
+			wantedErrIs: ErrUefiInternal,
+		},
+		{
+			msg:         "size mismatch",
+			request:     append(newTestRequest(BiosUefiVariableVersion1, PlatformManifestGUID, 64), 0x00),
+			wantedType:  RequestNone,
+			wantedErrIs: ErrUefiInternal,
+		},
+	}
+
+	for _, c := range cases {
+		root := t.TempDir()
+		if c.request != nil {
+			writeTestVar(t, root, VarServerRequest, defaultAttributes, c.request)
+		}
+
+		requestType, err := NewMPUefi(root).GetRequestType()
+		assert.Equal(t, c.wantedType, requestType, c.msg)
+		if c.wantedErrNil {
+			assert.NoError(t, err, c.msg)
+		} else {
+			assert.ErrorIs(t, err, c.wantedErrIs, c.msg)
+		}
+	}
+}
+
+func TestGetRequest(t *testing.T) {
+	root := t.TempDir()
+	uefi := NewMPUefi(root)
+
+	_, err := uefi.GetRequest()
+	assert.ErrorIs(t, err, ErrNoPendingData, "missing request variable")
+
+	request := newTestRequest(BiosUefiVariableVersion1, PlatformManifestGUID, 128)
+	writeTestVar(t, root, VarServerRequest, defaultAttributes, request)
+
+	content, err := uefi.GetRequest()
+	assert.NoError(t, err)
+	assert.Equal(t, request[uefiVarHeaderSize:], content, "request starts at the structure header")
+}
+
+func TestGetPackageInfo(t *testing.T) {
+	root := t.TempDir()
+	uefi := NewMPUefi(root)
+
+	_, err := uefi.GetPackageInfo()
+	assert.ErrorIs(t, err, ErrNoPendingData, "missing package info variable")
+
+	packageInfo := newTestRequest(BiosUefiVariableVersion1, [GUIDSize]byte{0x2E, 0xCF}, 96)
+	writeTestVar(t, root, VarPackageInfo, defaultAttributes, packageInfo)
+
+	content, err := uefi.GetPackageInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, packageInfo[uefiVarHeaderSize:], content, "package info starts at the structure header")
+}
+
+func TestRegistrationStatus(t *testing.T) {
+	root := t.TempDir()
+	uefi := NewMPUefi(root)
+
+	_, err := uefi.GetRegistrationStatus()
+	assert.ErrorIs(t, err, ErrUefiInternal, "missing status variable")
+
+	err = uefi.SetRegistrationStatus(RegistrationStatus{RegistrationComplete: true})
+	assert.Error(t, err, "status variable is never created")
+
+	writeTestVar(t, root, VarStatus, defaultAttributes, newTestStatus(packageInfoCompleteBitMask, 0x82))
+	status, err := uefi.GetRegistrationStatus()
+	assert.NoError(t, err)
+	assert.Equal(t, RegistrationStatus{PackageInfoComplete: true, ErrorCode: 0x82}, status)
+
+	status.RegistrationComplete = true
+	status.ErrorCode = 0
+	assert.NoError(t, uefi.SetRegistrationStatus(status))
+
+	raw, err := os.ReadFile(filepath.Join(root, VarStatus))
+	assert.NoError(t, err)
+	assert.Equal(t, append(binary.LittleEndian.AppendUint32(nil, defaultAttributes),
+		newTestStatus(registrationCompleteBitMask|packageInfoCompleteBitMask, 0)...), raw)
+
+	status, err = uefi.GetRegistrationStatus()
+	assert.NoError(t, err)
+	assert.Equal(t, RegistrationStatus{RegistrationComplete: true, PackageInfoComplete: true}, status)
+}
+
+func TestSetRegistrationStatusVolatileVariable(t *testing.T) {
+	root := t.TempDir()
+	writeTestVar(t, root, VarStatus, efiVariableBootServiceAccess|efiVariableRuntimeAccess, newTestStatus(0, 0))
+
+	err := NewMPUefi(root).SetRegistrationStatus(RegistrationStatus{RegistrationComplete: true})
+	assert.ErrorIs(t, err, ErrInsufficientPrivileges)
+}
+
+func TestWriteVarCreate(t *testing.T) {
+	root := t.TempDir()
+	uefi := NewFSUefi(root)
+
+	assert.Error(t, uefi.WriteVar(VarServerResponse, []byte{0x01, 0x02}, false), "missing variable without create")
+	assert.NoError(t, uefi.WriteVar(VarServerResponse, []byte{0x01, 0x02, 0x03}, true))
+	assert.NoError(t, uefi.WriteVar(VarServerResponse, []byte{0x04}, false))
+
+	data, err := uefi.ReadVar(VarServerResponse)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x04}, data, "shorter content replaces the whole variable")
+}
+
+func TestSetServerResponse(t *testing.T) {
+	root := t.TempDir()
+	uefi := NewMPUefi(root)
+
+	assert.ErrorIs(t, uefi.SetServerResponse(nil), ErrInvalidParameter, "empty response")
+	assert.ErrorIs(t, uefi.SetServerResponse(make([]byte, MaxResponseSize+1)), ErrInvalidParameter, "oversized response")
+
+	response := []byte{0xde, 0xad, 0xbe, 0xef}
+	assert.NoError(t, uefi.SetServerResponse(response))
+
+	data, err := NewFSUefi(root).ReadVar(VarServerResponse)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{BiosUefiVariableVersion1, 0x00, 0x04, 0x00, 0xde, 0xad, 0xbe, 0xef}, data)
+}
@@ -0,0 +1,153 @@
+package mpuefi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+const (
+	// EpcBiosConfigUEFI: supportedPrmBins, maxEpcSize, epcSize (uint32) + epcMap[32] (uint32)
+	epcBiosConfigSize = 4*3 + 4*epcMapEntries
+	epcMapEntries     = 32
+	// EpcOsConfigUEFI: epcSize (uint32)
+	epcOsConfigSize = 4
+	// SoftwareGuardStatusUEFI: sgxStatus (uint8) + reserved (uint8)
+	softwareGuardStatusSize = 2
+)
+
+// ErrEfivarsUnavailable is returned when the efivarfs file system is not mounted
+var ErrEfivarsUnavailable = errors.New("efivarfs not available")
+
+// SgxBiosState is the SGX setting of the BIOS, as reported by the SOFTWAREGUARDSTATUS variable
+type SgxBiosState int
+
+const (
+	SgxBiosStateDisabled           SgxBiosState = 0
+	SgxBiosStateEnabled            SgxBiosState = 1
+	SgxBiosStateSoftwareControlled SgxBiosState = 2
+	SgxBiosStateUnknown            SgxBiosState = -1
+)
+
+func (s SgxBiosState) String() string {
+	switch s {
+	case SgxBiosStateDisabled:
+		return "disabled"
+	case SgxBiosStateEnabled:
+		return "enabled"
+	case SgxBiosStateSoftwareControlled:
+		return "software_controlled"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the state with its name
+func (s SgxBiosState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a state encoded with MarshalText
+func (s *SgxBiosState) UnmarshalText(text []byte) error {
+	for _, state := range []SgxBiosState{SgxBiosStateDisabled, SgxBiosStateEnabled, SgxBiosStateSoftwareControlled} {
+		if state.String() == string(text) {
+			*s = state
+			return nil
+		}
+	}
+	*s = SgxBiosStateUnknown
+	return nil
+}
+
+// EpcBiosConfig is the content of the EPCBIOS variable. Sizes are in MB.
+type EpcBiosConfig struct {
+	// SupportedPrmBins is a bitmap of the supported PRM sizes: bit n stands for 2^n MB
+	SupportedPrmBins uint32
+	MaxEpcSize       uint32
+	EpcSize          uint32
+	// EpcMap maps each PRM bin to the corresponding EPC size
+	EpcMap [epcMapEntries]uint32
+}
+
+// PrmSize returns the PRM (PRMRR) size in MB of the supported bin providing the given EPC size,
+// or 0 when no bin matches
+func (c EpcBiosConfig) PrmSize(epcSize uint32) uint32 {
+	if epcSize == 0 {
+		return 0
+	}
+	for bin := 0; bin < epcMapEntries; bin++ {
+		if c.SupportedPrmBins&(1<<bin) != 0 && c.EpcMap[bin] == epcSize {
+			return 1 << bin
+		}
+	}
+	return 0
+}
+
+// CheckEfivars reports ErrEfivarsUnavailable when the efivarfs root does not exist
+func (u *MPUefi) CheckEfivars() error {
+	if _, err := os.Stat(u.uefi.root); err != nil {
+		return fmt.Errorf("%w: %w", ErrEfivarsUnavailable, err)
+	}
+	return nil
+}
+
+// GetSgxBiosState reads the SOFTWAREGUARDSTATUS variable.
+// It returns ErrNoPendingData when the variable is absent.
+func (u *MPUefi) GetSgxBiosState() (SgxBiosState, error) {
+	data, err := u.readRawVar(VarSoftwareGuardStatus, softwareGuardStatusSize)
+	if err != nil {
+		return SgxBiosStateUnknown, err
+	}
+	switch state := SgxBiosState(data[0]); state {
+	case SgxBiosStateDisabled, SgxBiosStateEnabled, SgxBiosStateSoftwareControlled:
+		return state, nil
+	default:
+		return SgxBiosStateUnknown, fmt.Errorf("%w: unknown SGX status %d", ErrUefiInternal, data[0])
+	}
+}
+
+// GetEpcBiosConfig reads the EPCBIOS variable.
+// It returns ErrNoPendingData when the variable is absent.
+func (u *MPUefi) GetEpcBiosConfig() (EpcBiosConfig, error) {
+	data, err := u.readRawVar(VarEpcBios, epcBiosConfigSize)
+	if err != nil {
+		return EpcBiosConfig{}, err
+	}
+
+	config := EpcBiosConfig{
+		SupportedPrmBins: binary.LittleEndian.Uint32(data[0:4]),
+		MaxEpcSize:       binary.LittleEndian.Uint32(data[4:8]),
+		EpcSize:          binary.LittleEndian.Uint32(data[8:12]),
+	}
+	for i := range config.EpcMap {
+		config.EpcMap[i] = binary.LittleEndian.Uint32(data[12+4*i:])
+	}
+	return config, nil
+}
+
+// GetRequestedEpcSize reads the EPC size in MB requested by the OS in the EPCSW variable.
+// It returns ErrNoPendingData when the variable is absent.
+func (u *MPUefi) GetRequestedEpcSize() (uint32, error) {
+	data, err := u.readRawVar(VarEpcSw, epcOsConfigSize)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(data), nil
+}
+
+// readRawVar reads a variable holding a fixed-size structure without SgxUefiVar header
+func (u *MPUefi) readRawVar(varName string, size int) ([]byte, error) {
+	data, err := u.uefi.ReadVar(varName)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNoPendingData
+		}
+		return nil, fmt.Errorf("%w: %w", ErrUefiInternal, err)
+	}
+	if len(data) < size {
+		return nil, fmt.Errorf("%w: variable %s too short (%d bytes, expected %d)", ErrUefiInternal, varName, len(data), size)
+	}
+	return data, nil
+}
@@ -0,0 +1,68 @@
+package mpuefi
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEpcBios(supportedPrmBins, maxEpcSize, epcSize uint32, epcMap map[int]uint32) []byte {
+	data := binary.LittleEndian.AppendUint32(nil, supportedPrmBins)
+	data = binary.LittleEndian.AppendUint32(data, maxEpcSize)
+	data = binary.LittleEndian.AppendUint32(data, epcSize)
+	for bin := 0; bin < epcMapEntries; bin++ {
+		data = binary.LittleEndian.AppendUint32(data, epcMap[bin])
+	}
+	return data
+}
+
+func TestSgxEnablement(t *testing.T) {
+	root := t.TempDir()
+	uefi := NewMPUefi(root)
+
+	assert.NoError(t, uefi.CheckEfivars())
+	assert.ErrorIs(t, NewMPUefi(filepath.Join(root, "missing")).CheckEfivars(), ErrEfivarsUnavailable)
+
+	_, err := uefi.GetSgxBiosState()
+	assert.ErrorIs(t, err, ErrNoPendingData, "missing software guard status")
+	_, err = uefi.GetEpcBiosConfig()
+	assert.ErrorIs(t, err, ErrNoPendingData, "missing epc bios")
+	_, err = uefi.GetRequestedEpcSize()
+	assert.ErrorIs(t, err, ErrNoPendingData, "missing epc sw")
+
+	writeTestVar(t, root, VarSoftwareGuardStatus, defaultAttributes, []byte{byte(SgxBiosStateSoftwareControlled), 0})
+	state, err := uefi.GetSgxBiosState()
+	assert.NoError(t, err)
+	assert.Equal(t, SgxBiosStateSoftwareControlled, state)
+
+	// 64 MB (bin 6) and 128 MB (bin 7) PRM providing 56 MB and 120 MB of EPC
+	epcBios := newTestEpcBios(1<<6|1<<7, 120, 56, map[int]uint32{6: 56, 7: 120})
+	writeTestVar(t, root, VarEpcBios, defaultAttributes, epcBios)
+	config, err := uefi.GetEpcBiosConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(120), config.MaxEpcSize)
+	assert.Equal(t, uint32(56), config.EpcSize)
+	assert.Equal(t, uint32(64), config.PrmSize(config.EpcSize))
+	assert.Equal(t, uint32(128), config.PrmSize(120))
+	assert.Equal(t, uint32(0), config.PrmSize(42), "no bin provides the EPC size")
+
+	writeTestVar(t, root, VarEpcSw, defaultAttributes, binary.LittleEndian.AppendUint32(nil, 120))
+	requested, err := uefi.GetRequestedEpcSize()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(120), requested)
+}
+
+func TestSgxEnablementInvalid(t *testing.T) {
+	root := t.TempDir()
+	uefi := NewMPUefi(root)
+
+	writeTestVar(t, root, VarSoftwareGuardStatus, defaultAttributes, []byte{7, 0})
+	_, err := uefi.GetSgxBiosState()
+	assert.ErrorIs(t, err, ErrUefiInternal, "unknown SGX status")
+
+	writeTestVar(t, root, VarEpcBios, defaultAttributes, make([]byte, epcBiosConfigSize-1))
+	_, err = uefi.GetEpcBiosConfig()
+	assert.ErrorIs(t, err, ErrUefiInternal, "truncated epc bios")
+}
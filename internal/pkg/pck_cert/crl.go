@@ -0,0 +1,51 @@
+package pckcert
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+)
+
+// CRLIssuerChainHeader is the header of the PCK CRL responses holding the issuer chain
+const CRLIssuerChainHeader = "SGX-PCK-CRL-Issuer-Chain"
+
+// ParseCRL decodes a PCK CRL, which the PCS returns PEM or DER encoded and some PCCS versions hex encoded
+func ParseCRL(body []byte) (*x509.RevocationList, error) {
+	der := bytes.TrimSpace(body)
+	if block, _ := pem.Decode(der); block != nil {
+		if block.Type != "X509 CRL" {
+			return nil, fmt.Errorf("%w: unexpected PEM block %s", ErrInvalidResponse, block.Type)
+		}
+		der = block.Bytes
+	} else if decoded, err := hex.DecodeString(string(der)); err == nil {
+		der = decoded
+	}
+
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		return nil, fmt.Errorf("%w: PCK CRL: %w", ErrInvalidResponse, err)
+	}
+	return crl, nil
+}
+
+// IsRevoked reports whether the PCK certificate is listed in the CRL
+func (c *Certificate) IsRevoked(crl *x509.RevocationList) bool {
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(c.Leaf.SerialNumber) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Issuer returns the certificate of the PCK certificate issuer (PCK Processor or Platform CA)
+func (c *Certificate) Issuer() *x509.Certificate {
+	for _, cert := range c.IssuerChain {
+		if bytes.Equal(cert.RawSubject, c.Leaf.RawIssuer) {
+			return cert
+		}
+	}
+	return nil
+}
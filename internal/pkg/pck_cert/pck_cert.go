@@ -0,0 +1,179 @@
+// Package pckcert decodes the PCK certificates returned by the Intel PCS and PCCS
+// (see the Intel SGX PCK Certificate and Certificate Revocation List Profile Specification).
+package pckcert
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Headers of the PCK certificate responses
+const (
+	IssuerChainHeader = "SGX-PCK-Certificate-Issuer-Chain"
+	TCBmHeader        = "SGX-TCBm"
+	FMSPCHeader       = "SGX-FMSPC"
+	CATypeHeader      = "SGX-PCK-Certificate-CA-Type"
+)
+
+// CA types of the PCK certificate issuer
+const (
+	CATypeProcessor = "processor"
+	CATypePlatform  = "platform"
+)
+
+const (
+	// TCBm is the 16 bytes CPUSVN followed by the 2 bytes PCESVN
+	tcbmSize  = 18
+	fmspcSize = 6
+)
+
+// ErrInvalidResponse is returned when a PCK certificate response cannot be decoded
+var ErrInvalidResponse = errors.New("invalid PCK certificate response")
+
+// Certificate is a PCK certificate and the attributes returned along with it
+type Certificate struct {
+	// Leaf is the PCK certificate
+	Leaf *x509.Certificate
+	// LeafPEM is the PEM encoded PCK certificate as returned in the response body
+	LeafPEM []byte
+	// IssuerChain holds the intermediate (PCK Processor or Platform CA) and root CA certificates
+	IssuerChain []*x509.Certificate
+	// TCBm is the hex encoded CPUSVN and PCESVN of the TCB level of the certificate
+	TCBm string
+	// FMSPC is the hex encoded Family-Model-Stepping-Platform-CustomSKU of the platform
+	FMSPC string
+	// CAType is the type of the PCK certificate issuer, processor or platform
+	CAType string
+	// NotAfter is the expiry date of the PCK certificate
+	NotAfter time.Time
+}
+
+// CPUSVN returns the hex encoded CPUSVN part of TCBm
+func (c *Certificate) CPUSVN() string {
+	return c.TCBm[:2*(tcbmSize-2)]
+}
+
+// PCESVN returns the hex encoded little-endian PCESVN part of TCBm
+func (c *Certificate) PCESVN() string {
+	return c.TCBm[2*(tcbmSize-2):]
+}
+
+// ParseResponse decodes the body and headers of a successful PCK certificate response
+func ParseResponse(body []byte, header http.Header) (*Certificate, error) {
+	leaf, err := parseLeaf(body)
+	if err != nil {
+		return nil, err
+	}
+
+	tcbm, err := parseHex(header.Get(TCBmHeader), tcbmSize, TCBmHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := parseHeaders(header)
+	if err != nil {
+		return nil, err
+	}
+	cert.Leaf = leaf
+	cert.LeafPEM = body
+	cert.TCBm = tcbm
+	cert.NotAfter = leaf.NotAfter
+	return cert, nil
+}
+
+// parseHeaders decodes the issuer chain, FMSPC and CA type headers shared by the PCK certificate responses
+func parseHeaders(header http.Header) (*Certificate, error) {
+	issuerChain, err := ParseIssuerChain(header.Get(IssuerChainHeader))
+	if err != nil {
+		return nil, err
+	}
+
+	fmspc, err := parseHex(header.Get(FMSPCHeader), fmspcSize, FMSPCHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	caType := strings.ToLower(header.Get(CATypeHeader))
+	if caType != CATypeProcessor && caType != CATypePlatform {
+		return nil, fmt.Errorf("%w: unknown %s '%s'", ErrInvalidResponse, CATypeHeader, header.Get(CATypeHeader))
+	}
+
+	return &Certificate{
+		IssuerChain: issuerChain,
+		FMSPC:       fmspc,
+		CAType:      caType,
+	}, nil
+}
+
+// ParseIssuerChain decodes a URL-encoded PEM certificate chain header
+func ParseIssuerChain(value string) ([]*x509.Certificate, error) {
+	if value == "" {
+		return nil, fmt.Errorf("%w: missing issuer chain", ErrInvalidResponse)
+	}
+	decoded, err := url.QueryUnescape(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: issuer chain is not URL-encoded: %w", ErrInvalidResponse, err)
+	}
+	chain, err := parseCertificates([]byte(decoded))
+	if err != nil {
+		return nil, fmt.Errorf("%w: issuer chain: %w", ErrInvalidResponse, err)
+	}
+	return chain, nil
+}
+
+func parseLeaf(body []byte) (*x509.Certificate, error) {
+	certs, err := parseCertificates(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: PCK certificate: %w", ErrInvalidResponse, err)
+	}
+	if len(certs) != 1 {
+		return nil, fmt.Errorf("%w: expected a single PCK certificate, got %d", ErrInvalidResponse, len(certs))
+	}
+	return certs[0], nil
+}
+
+// parseCertificates decodes a sequence of PEM certificates
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block %s", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificate found")
+	}
+	if len(strings.TrimSpace(string(data))) != 0 {
+		return nil, errors.New("trailing data after the PEM certificates")
+	}
+	return certs, nil
+}
+
+// parseHex validates a hex encoded header of the given size in bytes and returns it in lower case
+func parseHex(value string, size int, name string) (string, error) {
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s is not hex encoded: %w", ErrInvalidResponse, name, err)
+	}
+	if len(decoded) != size {
+		return "", fmt.Errorf("%w: %s has %d bytes, expected %d", ErrInvalidResponse, name, len(decoded), size)
+	}
+	return hex.EncodeToString(decoded), nil
+}
@@ -0,0 +1,330 @@
+package pckcert_test
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	fakepcs "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/fake_pcs"
+	pckcert "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/pck_cert"
+	"github.com/stretchr/testify/assert"
+)
+
+var testPCK = fakepcs.PCK{
+	FMSPC:  "00906ed50000",
+	CPUSVN: "0f0f0202ff8003000000000000000000",
+	PCESVN: "0d00",
+	PCEID:  "0000",
+}
+
+func TestParseResponse(t *testing.T) {
+	ca, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	notAfter := time.Now().Add(48 * time.Hour).Truncate(time.Second).UTC()
+
+	recorder := httptest.NewRecorder()
+	ca.WritePCKResponse(recorder, fakepcs.PCK{
+		FMSPC:    "00906ed50000",
+		CPUSVN:   "0f0f0202ff8003000000000000000000",
+		PCESVN:   "0d00",
+		PCEID:    "0000",
+		CAType:   "Platform",
+		NotAfter: notAfter,
+	})
+
+	cert, err := pckcert.ParseResponse(recorder.Body.Bytes(), recorder.Header())
+	assert.NoError(t, err)
+	assert.Equal(t, "00906ed50000", cert.FMSPC)
+	assert.Equal(t, "0f0f0202ff80030000000000000000000d00", cert.TCBm)
+	assert.Equal(t, "0f0f0202ff8003000000000000000000", cert.CPUSVN())
+	assert.Equal(t, "0d00", cert.PCESVN())
+	assert.Equal(t, pckcert.CATypePlatform, cert.CAType)
+	assert.Equal(t, notAfter, cert.NotAfter)
+	assert.Len(t, cert.IssuerChain, 2)
+	assert.Equal(t, ca.Intermediate.Raw, cert.IssuerChain[0].Raw)
+	assert.Equal(t, ca.Root.Raw, cert.IssuerChain[1].Raw)
+}
+
+func TestParseResponseInvalid(t *testing.T) {
+	ca, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	valid := httptest.NewRecorder()
+	ca.WritePCKResponse(valid, testPCK)
+
+	cases := []struct {
+		msg    string
+		body   []byte
+		header string
+		value  string
+	}{
+		{msg: "empty body", body: []byte{}},
+		{msg: "body is not PEM", body: []byte("garbage")},
+		{msg: "two certificates in the body", body: append(append([]byte{}, ca.RootPEM...), ca.IntermediatePEM...)},
+		{msg: "missing issuer chain", header: pckcert.IssuerChainHeader, value: ""},
+		{msg: "issuer chain is not PEM", header: pckcert.IssuerChainHeader, value: "garbage"},
+		{msg: "short TCBm", header: pckcert.TCBmHeader, value: "0f0f"},
+		{msg: "FMSPC is not hex", header: pckcert.FMSPCHeader, value: "00906ed5000z"},
+		{msg: "unknown CA type", header: pckcert.CATypeHeader, value: "root"},
+	}
+
+	for _, c := range cases {
+		body := valid.Body.Bytes()
+		if c.body != nil {
+			body = c.body
+		}
+		header := valid.Header().Clone()
+		if c.header != "" {
+			header.Set(c.header, c.value)
+		}
+		_, err := pckcert.ParseResponse(body, header)
+		assert.ErrorIs(t, err, pckcert.ErrInvalidResponse, c.msg)
+	}
+}
+
+func TestParseSGXExtensions(t *testing.T) {
+	ca, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	ca.WritePCKResponse(recorder, testPCK)
+	cert, err := pckcert.ParseResponse(recorder.Body.Bytes(), recorder.Header())
+	assert.NoError(t, err)
+
+	ext, err := pckcert.ParseSGXExtensions(cert.Leaf)
+	assert.NoError(t, err)
+	assert.Equal(t, [16]byte{0x0f, 0x0f, 0x02, 0x02, 0xff, 0x80, 0x03}, ext.CPUSVN)
+	assert.Equal(t, uint16(13), ext.PCESVN)
+	assert.Equal(t, [2]byte{}, ext.PCEID)
+	assert.Equal(t, [6]byte{0x00, 0x90, 0x6e, 0xd5}, ext.FMSPC)
+	assert.Len(t, ext.PPID, 16)
+
+	_, err = pckcert.ParseSGXExtensions(ca.Intermediate)
+	assert.ErrorIs(t, err, pckcert.ErrInvalidResponse)
+}
+
+func TestIntelSGXRootCA(t *testing.T) {
+	root := pckcert.IntelSGXRootCA()
+	assert.Equal(t, "Intel SGX Root CA", root.Subject.CommonName)
+	assert.NoError(t, root.CheckSignatureFrom(root))
+
+	loaded, err := pckcert.LoadRootCA("")
+	assert.NoError(t, err)
+	assert.True(t, root.Equal(loaded))
+}
+
+func TestVerify(t *testing.T) {
+	ca, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	otherCA, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	platform := pckcert.Platform{PCEID: "0000", CPUSVN: "0f0f0202ff8003000000000000000000", PCESVN: 13}
+
+	cases := []struct {
+		msg         string
+		pck         fakepcs.PCK
+		root        *x509.Certificate
+		platform    pckcert.Platform
+		wantedError bool
+	}{
+		{
+			msg:      "certificate of the queried platform",
+			pck:      testPCK,
+			root:     ca.Root,
+			platform: platform,
+		},
+		{
+			msg:      "certificate of a lower TCB level",
+			pck:      fakepcs.PCK{FMSPC: "00906ed50000", CPUSVN: "0e0f0202ff8003000000000000000000", PCESVN: "0b00", PCEID: "0000"},
+			root:     ca.Root,
+			platform: platform,
+		},
+		{
+			msg:         "untrusted root CA",
+			pck:         testPCK,
+			root:        otherCA.Root,
+			platform:    platform,
+			wantedError: true,
+		},
+		{
+			msg:         "Intel SGX Root CA does not trust the test CA",
+			pck:         testPCK,
+			root:        pckcert.IntelSGXRootCA(),
+			platform:    platform,
+			wantedError: true,
+		},
+		{
+			msg:         "certificate of another PCE",
+			pck:         testPCK,
+			root:        ca.Root,
+			platform:    pckcert.Platform{PCEID: "0001", CPUSVN: platform.CPUSVN, PCESVN: platform.PCESVN},
+			wantedError: true,
+		},
+		{
+			msg:         "CPUSVN higher than the queried one",
+			pck:         testPCK,
+			root:        ca.Root,
+			platform:    pckcert.Platform{PCEID: "0000", CPUSVN: "0f0f0202ff7003000000000000000000", PCESVN: 13},
+			wantedError: true,
+		},
+		{
+			msg:         "PCESVN higher than the queried one",
+			pck:         testPCK,
+			root:        ca.Root,
+			platform:    pckcert.Platform{PCEID: "0000", CPUSVN: platform.CPUSVN, PCESVN: 12},
+			wantedError: true,
+		},
+	}
+
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		ca.WritePCKResponse(recorder, c.pck)
+		cert, err := pckcert.ParseResponse(recorder.Body.Bytes(), recorder.Header())
+		assert.NoError(t, err, c.msg)
+
+		err = cert.Verify(c.root, c.platform, time.Now())
+		if c.wantedError {
+			assert.ErrorIs(t, err, pckcert.ErrVerificationFailed, c.msg)
+		} else {
+			assert.NoError(t, err, c.msg)
+		}
+	}
+}
+
+func TestVerifyHeaderMismatch(t *testing.T) {
+	ca, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	platform := pckcert.Platform{PCEID: "0000", CPUSVN: "0f0f0202ff8003000000000000000000", PCESVN: 13}
+
+	for _, header := range []struct{ name, value string }{
+		{pckcert.FMSPCHeader, "00606a000000"},
+		{pckcert.TCBmHeader, "0e0f0202ff80030000000000000000000d00"},
+	} {
+		recorder := httptest.NewRecorder()
+		ca.WritePCKResponse(recorder, testPCK)
+		recorder.Header().Set(header.name, header.value)
+		cert, err := pckcert.ParseResponse(recorder.Body.Bytes(), recorder.Header())
+		assert.NoError(t, err, header.name)
+		assert.ErrorIs(t, cert.Verify(ca.Root, platform, time.Now()), pckcert.ErrVerificationFailed, header.name)
+	}
+}
+
+func TestVerifyCerts(t *testing.T) {
+	ca, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	otherCA, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	// TCB levels higher than the raw TCB of the platform are verified too
+	higher := testPCK
+	higher.CPUSVN = "1f1f0202ff8003000000000000000000"
+	foreign := testPCK
+	foreign.CPUSVN = "07070202ff8003000000000000000000"
+	foreign.Issuer = otherCA
+	otherPCE := testPCK
+	otherPCE.CPUSVN = "07070202ff8003000000000000000000"
+	otherPCE.PCEID = "0001"
+
+	cases := []struct {
+		msg         string
+		pcks        []fakepcs.PCK
+		wantedError bool
+	}{
+		{
+			msg:  "certificates of all the TCB levels of the platform",
+			pcks: []fakepcs.PCK{higher, testPCK},
+		},
+		{
+			msg:         "TCB level signed by a foreign CA",
+			pcks:        []fakepcs.PCK{testPCK, foreign},
+			wantedError: true,
+		},
+		{
+			msg:         "TCB level of another PCE",
+			pcks:        []fakepcs.PCK{testPCK, otherPCE},
+			wantedError: true,
+		},
+	}
+
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		ca.WritePCKCertsResponse(recorder, c.pcks...)
+		certs, err := pckcert.ParseCertsResponse(recorder.Body.Bytes(), recorder.Header())
+		assert.NoError(t, err, c.msg)
+
+		err = pckcert.VerifyCerts(certs, ca.Root, "0000", time.Now())
+		if c.wantedError {
+			assert.ErrorIs(t, err, pckcert.ErrVerificationFailed, c.msg)
+		} else {
+			assert.NoError(t, err, c.msg)
+		}
+	}
+}
+
+func TestParseCRL(t *testing.T) {
+	ca, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	ca.WritePCKResponse(recorder, testPCK)
+	cert, err := pckcert.ParseResponse(recorder.Body.Bytes(), recorder.Header())
+	assert.NoError(t, err)
+	assert.Equal(t, ca.Intermediate.Raw, cert.Issuer().Raw)
+
+	nextUpdate := time.Now().Add(24 * time.Hour).Truncate(time.Second).UTC()
+	der, err := ca.IssueCRL(nextUpdate)
+	assert.NoError(t, err)
+	ca.Revoke(cert.Leaf.SerialNumber)
+	revokedDER, err := ca.IssueCRL(nextUpdate)
+	assert.NoError(t, err)
+	pemRecorder := httptest.NewRecorder()
+	ca.WriteCRLResponse(pemRecorder, nextUpdate)
+
+	cases := []struct {
+		msg           string
+		body          []byte
+		wantedRevoked bool
+	}{
+		{msg: "DER encoded CRL", body: der},
+		{msg: "hex encoded CRL", body: []byte(hex.EncodeToString(revokedDER) + "\n"), wantedRevoked: true},
+		{msg: "PEM encoded CRL", body: pemRecorder.Body.Bytes(), wantedRevoked: true},
+	}
+	for _, c := range cases {
+		crl, err := pckcert.ParseCRL(c.body)
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, nextUpdate, crl.NextUpdate, c.msg)
+		assert.NoError(t, crl.CheckSignatureFrom(cert.Issuer()), c.msg)
+		assert.Equal(t, c.wantedRevoked, cert.IsRevoked(crl), c.msg)
+	}
+
+	_, err = pckcert.ParseCRL([]byte("garbage"))
+	assert.ErrorIs(t, err, pckcert.ErrInvalidResponse)
+}
+
+func TestParseCertsResponse(t *testing.T) {
+	ca, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	lower := testPCK
+	lower.CPUSVN = "07070202ff8003000000000000000000"
+	unavailable := testPCK
+	unavailable.CPUSVN = "01010202ff8003000000000000000000"
+	unavailable.Unavailable = true
+
+	recorder := httptest.NewRecorder()
+	ca.WritePCKCertsResponse(recorder, testPCK, lower, unavailable)
+
+	certs, err := pckcert.ParseCertsResponse(recorder.Body.Bytes(), recorder.Header())
+	assert.NoError(t, err)
+	assert.Len(t, certs, 2)
+	assert.Equal(t, "0f0f0202ff80030000000000000000000d00", certs[0].TCBm)
+	assert.Equal(t, "07070202ff80030000000000000000000d00", certs[1].TCBm)
+	for _, cert := range certs {
+		assert.Equal(t, "00906ed50000", cert.FMSPC)
+		assert.Equal(t, pckcert.CATypeProcessor, cert.CAType)
+		assert.Len(t, cert.IssuerChain, 2)
+		assert.NoError(t, cert.Verify(ca.Root, pckcert.Platform{PCEID: "0000", CPUSVN: testPCK.CPUSVN, PCESVN: 13}, time.Now()))
+	}
+
+	onlyUnavailable := httptest.NewRecorder()
+	ca.WritePCKCertsResponse(onlyUnavailable, unavailable)
+	_, err = pckcert.ParseCertsResponse(onlyUnavailable.Body.Bytes(), onlyUnavailable.Header())
+	assert.ErrorIs(t, err, pckcert.ErrInvalidResponse)
+}
@@ -0,0 +1,62 @@
+package pckcert
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// certNotAvailable is the cert value of the TCB levels Intel has no PCK certificate for
+const certNotAvailable = "Not available"
+
+// certsEntry is a TCB level of a /pckcerts response
+type certsEntry struct {
+	TCBm string `json:"tcbm"`
+	// Cert is the URL-encoded PEM PCK certificate
+	Cert string `json:"cert"`
+}
+
+// ParseCertsResponse decodes the body and headers of a successful /pckcerts response, which holds the
+// PCK certificates of all the TCB levels of the platform. The TCB levels without certificate are skipped.
+func ParseCertsResponse(body []byte, header http.Header) ([]*Certificate, error) {
+	var entries []certsEntry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+
+	common, err := parseHeaders(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*Certificate
+	for i, entry := range entries {
+		if entry.Cert == certNotAvailable {
+			continue
+		}
+		leafPEM, err := url.QueryUnescape(entry.Cert)
+		if err != nil {
+			return nil, fmt.Errorf("%w: certificate %d is not URL-encoded: %w", ErrInvalidResponse, i, err)
+		}
+		leaf, err := parseLeaf([]byte(leafPEM))
+		if err != nil {
+			return nil, err
+		}
+		tcbm, err := parseHex(entry.TCBm, tcbmSize, "tcbm")
+		if err != nil {
+			return nil, err
+		}
+
+		cert := *common
+		cert.Leaf = leaf
+		cert.LeafPEM = []byte(leafPEM)
+		cert.TCBm = tcbm
+		cert.NotAfter = leaf.NotAfter
+		certs = append(certs, &cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%w: no PCK certificate available", ErrInvalidResponse)
+	}
+	return certs, nil
+}
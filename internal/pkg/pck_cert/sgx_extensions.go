@@ -0,0 +1,160 @@
+package pckcert
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+)
+
+// OIDs of the Intel SGX extensions of the PCK certificates
+var (
+	OIDSGXExtensions = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1}
+	OIDPPID          = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 1}
+	OIDTCB           = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 2}
+	OIDPCEID         = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 3}
+	OIDFMSPC         = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 4}
+	OIDSGXType       = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 5}
+)
+
+const (
+	// the TCB extension holds the 16 CPUSVN components (arcs 1 to 16), the PCESVN (17) and the CPUSVN (18)
+	tcbComponentCount = 16
+	tcbPCESVNArc      = 17
+	tcbCPUSVNArc      = 18
+)
+
+// SGXExtensions holds the Intel SGX extensions of a PCK certificate
+type SGXExtensions struct {
+	PPID    []byte
+	CPUSVN  [16]byte
+	PCESVN  uint16
+	PCEID   [2]byte
+	FMSPC   [6]byte
+	SGXType int
+}
+
+// extensionEntry is an entry of the SGX extensions and TCB sequences
+type extensionEntry struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+// ParseSGXExtensions decodes the Intel SGX extensions of a PCK certificate
+func ParseSGXExtensions(cert *x509.Certificate) (*SGXExtensions, error) {
+	var raw []byte
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(OIDSGXExtensions) {
+			raw = ext.Value
+			break
+		}
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("%w: no SGX extensions", ErrInvalidResponse)
+	}
+
+	entries, err := parseEntries(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: SGX extensions: %w", ErrInvalidResponse, err)
+	}
+
+	ext := &SGXExtensions{}
+	found := map[string]bool{}
+	for _, entry := range entries {
+		var err error
+		switch {
+		case entry.ID.Equal(OIDPPID):
+			ext.PPID, err = parseOctetString(entry.Value, 16)
+		case entry.ID.Equal(OIDTCB):
+			err = ext.parseTCB(entry.Value)
+		case entry.ID.Equal(OIDPCEID):
+			err = parseFixedOctetString(entry.Value, ext.PCEID[:])
+		case entry.ID.Equal(OIDFMSPC):
+			err = parseFixedOctetString(entry.Value, ext.FMSPC[:])
+		case entry.ID.Equal(OIDSGXType):
+			var sgxType asn1.Enumerated
+			_, err = asn1.Unmarshal(entry.Value.FullBytes, &sgxType)
+			ext.SGXType = int(sgxType)
+		default:
+			// platform instance ID and configuration of the platform CA certificates
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: SGX extension %s: %w", ErrInvalidResponse, entry.ID, err)
+		}
+		found[entry.ID.String()] = true
+	}
+
+	for _, oid := range []asn1.ObjectIdentifier{OIDPPID, OIDTCB, OIDPCEID, OIDFMSPC} {
+		if !found[oid.String()] {
+			return nil, fmt.Errorf("%w: missing SGX extension %s", ErrInvalidResponse, oid)
+		}
+	}
+	return ext, nil
+}
+
+func (ext *SGXExtensions) parseTCB(value asn1.RawValue) error {
+	entries, err := parseEntries(value.FullBytes)
+	if err != nil {
+		return err
+	}
+	components := 0
+	for _, entry := range entries {
+		if len(entry.ID) != len(OIDTCB)+1 || !asn1.ObjectIdentifier(entry.ID[:len(OIDTCB)]).Equal(OIDTCB) {
+			return fmt.Errorf("unexpected TCB component %s", entry.ID)
+		}
+		switch arc := entry.ID[len(OIDTCB)]; {
+		case arc >= 1 && arc <= tcbComponentCount:
+			// the components repeat the CPUSVN bytes
+			components++
+		case arc == tcbPCESVNArc:
+			var pcesvn int
+			if _, err := asn1.Unmarshal(entry.Value.FullBytes, &pcesvn); err != nil {
+				return err
+			}
+			if pcesvn < 0 || pcesvn > 0xffff {
+				return fmt.Errorf("PCESVN %d out of range", pcesvn)
+			}
+			ext.PCESVN = uint16(pcesvn)
+		case arc == tcbCPUSVNArc:
+			if err := parseFixedOctetString(entry.Value, ext.CPUSVN[:]); err != nil {
+				return err
+			}
+		}
+	}
+	if components != tcbComponentCount {
+		return fmt.Errorf("expected %d TCB components, got %d", tcbComponentCount, components)
+	}
+	return nil
+}
+
+func parseEntries(data []byte) ([]extensionEntry, error) {
+	var entries []extensionEntry
+	rest, err := asn1.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%d trailing bytes", len(rest))
+	}
+	return entries, nil
+}
+
+func parseOctetString(value asn1.RawValue, size int) ([]byte, error) {
+	var b []byte
+	if _, err := asn1.Unmarshal(value.FullBytes, &b); err != nil {
+		return nil, err
+	}
+	if len(b) != size {
+		return nil, fmt.Errorf("expected %d bytes, got %d", size, len(b))
+	}
+	return b, nil
+}
+
+func parseFixedOctetString(value asn1.RawValue, dst []byte) error {
+	b, err := parseOctetString(value, len(dst))
+	if err != nil {
+		return err
+	}
+	copy(dst, b)
+	return nil
+}
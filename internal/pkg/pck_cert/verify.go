@@ -0,0 +1,199 @@
+package pckcert
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+)
+
+// intelSGXRootCAPEM is the Intel SGX Root CA certificate, published at
+// https://certificates.trustedservices.intel.com/Intel_SGX_Provisioning_Certification_RootCA.pem
+//
+//go:embed intel_sgx_root_ca.pem
+var intelSGXRootCAPEM []byte
+
+// ErrVerificationFailed is returned when a PCK certificate does not verify against the root CA
+// or was not issued for the queried platform
+var ErrVerificationFailed = errors.New("PCK certificate verification failed")
+
+// Platform identifies the platform and raw TCB a PCK certificate was queried for
+type Platform struct {
+	PCEID  string // hex encoded
+	CPUSVN string // hex encoded
+	PCESVN uint16
+}
+
+// IntelSGXRootCA returns the embedded Intel SGX Root CA certificate
+func IntelSGXRootCA() *x509.Certificate {
+	certs, err := parseCertificates(intelSGXRootCAPEM)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded Intel SGX Root CA: %v", err))
+	}
+	return certs[0]
+}
+
+// LoadRootCA reads a PEM root CA certificate, which overrides the Intel SGX Root CA.
+// An empty path returns the embedded Intel SGX Root CA.
+func LoadRootCA(path string) (*x509.Certificate, error) {
+	if path == "" {
+		return IntelSGXRootCA(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SGX root CA %s: %w", path, err)
+	}
+	certs, err := parseCertificates(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SGX root CA %s: %w", path, err)
+	}
+	if len(certs) != 1 || !certs[0].IsCA {
+		return nil, fmt.Errorf("SGX root CA %s must hold a single CA certificate", path)
+	}
+	return certs[0], nil
+}
+
+// Verify checks that the PCK certificate chains up to root through the issuer chain at the given time,
+// and that its SGX extensions match the platform it was queried for.
+// The certificate is issued for the highest TCB level not above the raw TCB of the platform, so its
+// CPUSVN and PCESVN must be equal to TCBm and not higher than the queried ones, component by component.
+func (c *Certificate) Verify(root *x509.Certificate, platform Platform, now time.Time) error {
+	ext, err := c.verifyTCBLevel(root, platform.PCEID, now)
+	if err != nil {
+		return err
+	}
+
+	cpusvn, err := hex.DecodeString(platform.CPUSVN)
+	if err != nil || len(cpusvn) != len(ext.CPUSVN) {
+		return fmt.Errorf("%w: invalid queried CPUSVN %s", ErrVerificationFailed, platform.CPUSVN)
+	}
+	for i := range cpusvn {
+		if ext.CPUSVN[i] > cpusvn[i] {
+			return fmt.Errorf("%w: CPUSVN %x is higher than the queried %s", ErrVerificationFailed, ext.CPUSVN, platform.CPUSVN)
+		}
+	}
+	if ext.PCESVN > platform.PCESVN {
+		return fmt.Errorf("%w: PCESVN %d is higher than the queried %d", ErrVerificationFailed, ext.PCESVN, platform.PCESVN)
+	}
+	return nil
+}
+
+// VerifyCerts checks every PCK certificate of a /pckcerts response like Verify, without bounding their
+// TCB levels by the raw TCB of the platform, and that they were all issued for the same platform.
+func VerifyCerts(certs []*Certificate, root *x509.Certificate, pceid string, now time.Time) error {
+	var ppid []byte
+	for _, cert := range certs {
+		ext, err := cert.verifyTCBLevel(root, pceid, now)
+		if err != nil {
+			return fmt.Errorf("TCB level %s: %w", cert.TCBm, err)
+		}
+		if ppid == nil {
+			ppid = ext.PPID
+		} else if !bytes.Equal(ppid, ext.PPID) {
+			return fmt.Errorf("%w: TCB level %s was issued for another platform", ErrVerificationFailed, cert.TCBm)
+		}
+	}
+	return nil
+}
+
+// verifyTCBLevel checks the chain of the PCK certificate, that it was issued for the PCE and that its
+// SGX extensions match the FMSPC and TCBm headers
+func (c *Certificate) verifyTCBLevel(root *x509.Certificate, pceid string, now time.Time) (*SGXExtensions, error) {
+	if err := c.verifyChain(root, now); err != nil {
+		return nil, err
+	}
+
+	ext, err := ParseSGXExtensions(c.Leaf)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrVerificationFailed, err)
+	}
+
+	queried, err := hex.DecodeString(pceid)
+	if err != nil || !bytes.Equal(queried, ext.PCEID[:]) {
+		return nil, fmt.Errorf("%w: PCEID %x does not match the queried %s", ErrVerificationFailed, ext.PCEID, pceid)
+	}
+	if fmspc := hex.EncodeToString(ext.FMSPC[:]); fmspc != c.FMSPC {
+		return nil, fmt.Errorf("%w: FMSPC %s does not match the %s header %s", ErrVerificationFailed, fmspc, FMSPCHeader, c.FMSPC)
+	}
+	if hex.EncodeToString(ext.CPUSVN[:]) != c.CPUSVN() || ext.PCESVN != c.pcesvnValue() {
+		return nil, fmt.Errorf("%w: TCB %x/%d does not match the %s header %s", ErrVerificationFailed, ext.CPUSVN, ext.PCESVN, TCBmHeader, c.TCBm)
+	}
+	return ext, nil
+}
+
+func (c *Certificate) verifyChain(root *x509.Certificate, now time.Time) error {
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	intermediates := x509.NewCertPool()
+	for _, cert := range c.IssuerChain {
+		if !cert.Equal(root) {
+			intermediates.AddCert(cert)
+		}
+	}
+
+	_, err := c.Leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrVerificationFailed, err)
+	}
+	return nil
+}
+
+// VerifySignature checks that data is signed by the first certificate of the issuer chain, which must chain
+// up to root at the given time. The signature is the concatenation of the 32 bytes r and s ECDSA P-256
+// values, as returned along with the TCB Info and the enclave identities.
+func VerifySignature(issuerChain []*x509.Certificate, root *x509.Certificate, data, signature []byte, now time.Time) error {
+	if len(issuerChain) == 0 {
+		return errors.New("empty issuer chain")
+	}
+	signer := issuerChain[0]
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	intermediates := x509.NewCertPool()
+	for _, cert := range issuerChain[1:] {
+		if !cert.Equal(root) {
+			intermediates.AddCert(cert)
+		}
+	}
+	_, err := signer.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return err
+	}
+
+	publicKey, ok := signer.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%s does not hold an ECDSA key", signer.Subject.CommonName)
+	}
+	if len(signature) != 64 {
+		return fmt.Errorf("signature must be 64 bytes, got %d", len(signature))
+	}
+	digest := sha256.Sum256(data)
+	sigR := new(big.Int).SetBytes(signature[:32])
+	sigS := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(publicKey, digest[:], sigR, sigS) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// pcesvnValue decodes the little-endian PCESVN part of TCBm
+func (c *Certificate) pcesvnValue() uint16 {
+	b, _ := hex.DecodeString(c.PCESVN())
+	return uint16(b[0]) | uint16(b[1])<<8
+}
@@ -0,0 +1,234 @@
+// Package platformmanifest decodes and builds the StructureHeader-framed binary structures
+// (PlatformManifest and AddRequest) that the BIOS publishes in the SgxRegistrationServerRequest
+// UEFI variable, as defined in UefiVar.h.
+package platformmanifest
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+const (
+	GUIDSize = 16
+
+	// HeaderSize is the size of a StructureHeader: GUID, size (uint16), version (uint16), reserved (12 bytes)
+	HeaderSize   = GUIDSize + 2 + 2 + reservedSize
+	reservedSize = 12
+
+	// StructureVersion is the only supported version of every structure (MP_STRUCTURE_VERSION)
+	StructureVersion = 1
+
+	// MaxSize is the largest request the BIOS can publish (MAX_REQUEST_SIZE)
+	MaxSize = 1024 * 56
+
+	platformInstanceIDSize = 16
+)
+
+// GUID identifies the type of a structure
+type GUID [GUIDSize]byte
+
+func (g GUID) String() string {
+	return hex.EncodeToString(g[:])
+}
+
+// Structure GUIDs as defined in UefiVar.h
+var (
+	RegistrationServerIDGUID   = GUID{0x31, 0xA1, 0x2A, 0xFE, 0x07, 0x20, 0x4E, 0xBC, 0xB6, 0x4E, 0xC4, 0xB3, 0xC7, 0xF8, 0xBC, 0x0F}
+	RegistrationServerInfoGUID = GUID{0x21, 0x2F, 0xE1, 0x83, 0x6B, 0x1A, 0x42, 0xA1, 0xA7, 0xA9, 0xDA, 0x3A, 0xB6, 0xB7, 0xBD, 0x02}
+	PlatformInfoGUID           = GUID{0x84, 0x94, 0x7A, 0xC6, 0x84, 0x40, 0x41, 0x89, 0x90, 0x2A, 0x7E, 0x76, 0xCD, 0x65, 0x89, 0x26}
+	EncryptedPlatformKeyGUID   = GUID{0xFD, 0x8F, 0x5C, 0x41, 0x1B, 0x61, 0x4B, 0x97, 0xA7, 0x47, 0x96, 0xF0, 0x89, 0x26, 0x75, 0x7B}
+	PairingReceiptGUID         = GUID{0xB4, 0x0B, 0xC4, 0x67, 0x1A, 0xB5, 0x40, 0x66, 0xB7, 0xF9, 0x60, 0xB6, 0x50, 0x4B, 0xC1, 0x8B}
+	PlatformManifestGUID       = GUID{0x17, 0x8E, 0x87, 0x4B, 0x49, 0xE4, 0x4A, 0xA5, 0x99, 0xBB, 0x30, 0x57, 0x17, 0x09, 0x25, 0xB4}
+	KeyBlobGUID                = GUID{0x2E, 0xCF, 0x43, 0xFD, 0x61, 0x4E, 0x4F, 0x94, 0x98, 0x2C, 0xDF, 0x36, 0x10, 0xF4, 0x3A, 0x9D}
+	PlatformMembershipGUID     = GUID{0xEB, 0xBD, 0x00, 0xC0, 0xEF, 0x90, 0x49, 0x10, 0xA4, 0x80, 0xF7, 0x72, 0xE7, 0x35, 0x5D, 0xB4}
+	AddRequestGUID             = GUID{0x69, 0x65, 0x19, 0xca, 0x73, 0xc1, 0x47, 0x85, 0xa0, 0xf6, 0x4d, 0x28, 0x9d, 0x37, 0xe9, 0x95}
+)
+
+// ErrInvalidManifest is returned when the binary data is not a well-formed PlatformManifest or AddRequest
+var ErrInvalidManifest = errors.New("invalid platform manifest")
+
+// Header is a decoded StructureHeader
+type Header struct {
+	GUID    GUID
+	Size    uint16 // size of the structure minus the size of its header
+	Version uint16
+}
+
+// Structure is a StructureHeader-framed structure nested in a manifest
+type Structure struct {
+	GUID    GUID
+	Version uint16
+	Data    []byte
+}
+
+// NewStructure creates a structure of the current version
+func NewStructure(guid GUID, data []byte) Structure {
+	return Structure{GUID: guid, Version: StructureVersion, Data: data}
+}
+
+// PlatformInfo is the decoded PlatformInfo structure
+type PlatformInfo struct {
+	PlatformInstanceID [platformInstanceIDSize]byte
+	Data               []byte // complete structure content, including the platform instance ID
+}
+
+// Manifest is a decoded PlatformManifest or AddRequest
+type Manifest struct {
+	Header     Header
+	Structures []Structure
+}
+
+// New creates a manifest of the given type (PlatformManifestGUID or AddRequestGUID)
+// holding the given structures, e.g. to build synthetic manifests for tests
+func New(guid GUID, structures ...Structure) *Manifest {
+	return &Manifest{
+		Header:     Header{GUID: guid, Version: StructureVersion},
+		Structures: structures,
+	}
+}
+
+// IsAddRequest reports whether the manifest is an AddRequest rather than a PlatformManifest
+func (m *Manifest) IsAddRequest() bool {
+	return m.Header.GUID == AddRequestGUID
+}
+
+// Count returns the number of nested structures with the given GUID
+func (m *Manifest) Count(guid GUID) int {
+	count := 0
+	for _, s := range m.Structures {
+		if s.GUID == guid {
+			count++
+		}
+	}
+	return count
+}
+
+// PackageCount returns the number of CPU packages in the manifest (one KeyBlob per package)
+func (m *Manifest) PackageCount() int {
+	return m.Count(KeyBlobGUID)
+}
+
+// PairingReceiptCount returns the number of PairingReceipt structures in the manifest
+func (m *Manifest) PairingReceiptCount() int {
+	return m.Count(PairingReceiptGUID)
+}
+
+// PlatformInfo decodes the PlatformInfo structure of the manifest
+func (m *Manifest) PlatformInfo() (*PlatformInfo, error) {
+	for _, s := range m.Structures {
+		if s.GUID != PlatformInfoGUID {
+			continue
+		}
+		if len(s.Data) < platformInstanceIDSize {
+			return nil, fmt.Errorf("%w: PlatformInfo too short (%d bytes)", ErrInvalidManifest, len(s.Data))
+		}
+		info := &PlatformInfo{Data: s.Data}
+		copy(info.PlatformInstanceID[:], s.Data)
+		return info, nil
+	}
+	return nil, fmt.Errorf("%w: no PlatformInfo structure", ErrInvalidManifest)
+}
+
+// Parse decodes and validates a PlatformManifest or AddRequest
+func Parse(data []byte) (*Manifest, error) {
+	if len(data) > MaxSize {
+		return nil, fmt.Errorf("%w: size %d exceeds %d bytes", ErrInvalidManifest, len(data), MaxSize)
+	}
+
+	header, content, rest, err := parseStructure(data)
+	if err != nil {
+		return nil, err
+	}
+	if header.GUID != PlatformManifestGUID && header.GUID != AddRequestGUID {
+		return nil, fmt.Errorf("%w: unexpected GUID %s", ErrInvalidManifest, header.GUID)
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes after the structure", ErrInvalidManifest, len(rest))
+	}
+
+	structures, err := ParseStructures(content)
+	if err != nil {
+		return nil, err
+	}
+	return &Manifest{Header: header, Structures: structures}, nil
+}
+
+// ParseStructures decodes and validates a sequence of StructureHeader-framed structures of any type,
+// such as the content of the SgxRegistrationPackageInfo UEFI variable
+func ParseStructures(data []byte) ([]Structure, error) {
+	var structures []Structure
+	for len(data) > 0 {
+		header, content, rest, err := parseStructure(data)
+		if err != nil {
+			return nil, err
+		}
+		structures = append(structures, Structure{GUID: header.GUID, Version: header.Version, Data: content})
+		data = rest
+	}
+	return structures, nil
+}
+
+// parseStructure decodes the StructureHeader at the start of data and validates its size and version.
+// It returns the header, the structure content and the remaining data.
+func parseStructure(data []byte) (Header, []byte, []byte, error) {
+	if len(data) < HeaderSize {
+		return Header{}, nil, nil, fmt.Errorf("%w: %d bytes left, too short for a structure header", ErrInvalidManifest, len(data))
+	}
+
+	var header Header
+	copy(header.GUID[:], data[:GUIDSize])
+	header.Size = binary.LittleEndian.Uint16(data[GUIDSize:])
+	header.Version = binary.LittleEndian.Uint16(data[GUIDSize+2:])
+
+	if header.Version != StructureVersion {
+		return Header{}, nil, nil, fmt.Errorf("%w: structure %s has unsupported version %d",
+			ErrInvalidManifest, header.GUID, header.Version)
+	}
+	for _, b := range data[GUIDSize+4 : HeaderSize] {
+		if b != 0 {
+			return Header{}, nil, nil, fmt.Errorf("%w: structure %s has non-zero reserved bytes", ErrInvalidManifest, header.GUID)
+		}
+	}
+
+	end := HeaderSize + int(header.Size)
+	if end > len(data) {
+		return Header{}, nil, nil, fmt.Errorf("%w: structure %s size %d exceeds the %d available bytes",
+			ErrInvalidManifest, header.GUID, header.Size, len(data)-HeaderSize)
+	}
+	return header, data[HeaderSize:end], data[end:], nil
+}
+
+// Marshal encodes the manifest, computing the structure sizes
+func (m *Manifest) Marshal() ([]byte, error) {
+	var content []byte
+	for _, s := range m.Structures {
+		var err error
+		content, err = appendStructure(content, s.GUID, s.Version, s.Data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := appendStructure(nil, m.Header.GUID, m.Header.Version, content)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxSize {
+		return nil, fmt.Errorf("%w: size %d exceeds %d bytes", ErrInvalidManifest, len(data), MaxSize)
+	}
+	return data, nil
+}
+
+func appendStructure(data []byte, guid GUID, version uint16, content []byte) ([]byte, error) {
+	if len(content) > 0xFFFF {
+		return nil, fmt.Errorf("%w: structure %s content of %d bytes does not fit a structure header",
+			ErrInvalidManifest, guid, len(content))
+	}
+	data = append(data, guid[:]...)
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(content)))
+	data = binary.LittleEndian.AppendUint16(data, version)
+	data = append(data, make([]byte, reservedSize)...)
+	return append(data, content...), nil
+}
@@ -0,0 +1,113 @@
+package platformmanifest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestManifest(t testing.TB, packages int) []byte {
+	t.Helper()
+	platformInfo := bytes.Repeat([]byte{0xAB}, 64)
+	structures := []Structure{NewStructure(PlatformInfoGUID, platformInfo)}
+	for i := 0; i < packages; i++ {
+		structures = append(structures,
+			NewStructure(PairingReceiptGUID, bytes.Repeat([]byte{byte(i)}, 48)),
+			NewStructure(KeyBlobGUID, bytes.Repeat([]byte{byte(i)}, 96)))
+	}
+	data, err := New(PlatformManifestGUID, structures...).Marshal()
+	if err != nil {
+		t.Fatalf("failed to build test manifest: %v", err)
+	}
+	return data
+}
+
+func TestParse(t *testing.T) {
+	data := newTestManifest(t, 4)
+
+	manifest, err := Parse(data)
+	assert.NoError(t, err)
+	assert.False(t, manifest.IsAddRequest())
+	assert.Equal(t, 4, manifest.PackageCount())
+	assert.Equal(t, 4, manifest.PairingReceiptCount())
+	assert.Equal(t, 9, len(manifest.Structures))
+
+	info, err := manifest.PlatformInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte{0xAB}, platformInstanceIDSize), info.PlatformInstanceID[:])
+
+	marshalled, err := manifest.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, data, marshalled)
+}
+
+func TestParseAddRequest(t *testing.T) {
+	data, err := New(AddRequestGUID,
+		NewStructure(PlatformInfoGUID, make([]byte, 32)),
+		NewStructure(KeyBlobGUID, make([]byte, 96)),
+	).Marshal()
+	assert.NoError(t, err)
+
+	manifest, err := Parse(data)
+	assert.NoError(t, err)
+	assert.True(t, manifest.IsAddRequest())
+	assert.Equal(t, 1, manifest.PackageCount())
+}
+
+func TestParseInvalid(t *testing.T) {
+	valid := newTestManifest(t, 2)
+
+	corrupt := func(mutate func([]byte) []byte) []byte {
+		return mutate(append([]byte(nil), valid...))
+	}
+
+	cases := []struct {
+		msg  string
+		data []byte
+	}{
+		{msg: "empty", data: nil},
+		{msg: "truncated header", data: valid[:HeaderSize-1]},
+		{msg: "truncated content", data: valid[:len(valid)-1]},
+		{msg: "trailing bytes", data: append(append([]byte(nil), valid...), 0x00)},
+		{msg: "unknown top-level GUID", data: corrupt(func(d []byte) []byte { d[0] ^= 0xFF; return d })},
+		{msg: "unsupported version", data: corrupt(func(d []byte) []byte { d[GUIDSize+2] = 2; return d })},
+		{msg: "non-zero reserved bytes", data: corrupt(func(d []byte) []byte { d[HeaderSize-1] = 1; return d })},
+		{msg: "nested size overflow", data: corrupt(func(d []byte) []byte { d[HeaderSize+GUIDSize+1] = 0xFF; return d })},
+		{msg: "nested unsupported version", data: corrupt(func(d []byte) []byte { d[HeaderSize+GUIDSize+2] = 0; return d })},
+	}
+
+	for _, c := range cases {
+		_, err := Parse(c.data)
+		assert.ErrorIs(t, err, ErrInvalidManifest, c.msg)
+	}
+}
+
+func TestPlatformInfoMissing(t *testing.T) {
+	manifest := New(PlatformManifestGUID, NewStructure(KeyBlobGUID, make([]byte, 8)))
+	_, err := manifest.PlatformInfo()
+	assert.ErrorIs(t, err, ErrInvalidManifest)
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add(newTestManifest(f, 1))
+	f.Add(newTestManifest(f, 2))
+	f.Add([]byte{})
+	f.Add(make([]byte, HeaderSize))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		manifest, err := Parse(data)
+		if err != nil {
+			return
+		}
+		// a successfully parsed manifest must encode back to the same bytes
+		marshalled, err := manifest.Marshal()
+		if err != nil {
+			t.Fatalf("failed to marshal parsed manifest: %v", err)
+		}
+		if !bytes.Equal(data, marshalled) {
+			t.Fatalf("round trip mismatch: %x != %x", data, marshalled)
+		}
+		_, _ = manifest.PlatformInfo()
+	})
+}
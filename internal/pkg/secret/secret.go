@@ -0,0 +1,85 @@
+// Package secret reads credentials from files, such as the keys of a mounted Kubernetes Secret,
+// and reads them again when the files are rotated.
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Redacted replaces the value of a secret wherever it is printed
+const Redacted = "[REDACTED]"
+
+// ErrEmpty is returned when a secret file holds no value
+var ErrEmpty = errors.New("secret file is empty")
+
+// File is a secret read from a file. The file is read again when its modification time or size
+// changes, which is the case when the kubelet swaps the ..data symlink of a rotated Secret volume.
+// Its value is only returned by Value and is redacted when a File is printed.
+type File struct {
+	path string
+
+	mu      sync.Mutex
+	value   string
+	modTime time.Time
+	size    int64
+}
+
+// Load reads the secret of the file at path, so that a missing or empty file fails at startup
+func Load(path string) (*File, error) {
+	f := &File{path: path}
+	if _, err := f.Value(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Path returns the path of the secret file
+func (f *File) Path() string {
+	return f.path
+}
+
+// Value returns the secret with its surrounding whitespace trimmed, reading the file again
+// when it changed since the last read
+func (f *File) Value() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to access secret file %s: %w", f.path, err)
+	}
+	if f.value != "" && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.value, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file %s: %w", f.path, err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", fmt.Errorf("%w: %s", ErrEmpty, f.path)
+	}
+	f.value, f.modTime, f.size = value, info.ModTime(), info.Size()
+	return f.value, nil
+}
+
+// String redacts the secret, e.g. when a configuration holding it is logged
+func (f *File) String() string {
+	return Redacted
+}
+
+// GoString redacts the secret when printed with %#v
+func (f *File) GoString() string {
+	return Redacted
+}
+
+// MarshalJSON redacts the secret when encoded to JSON
+func (f *File) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + Redacted + `"`), nil
+}
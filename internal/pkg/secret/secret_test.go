@@ -0,0 +1,109 @@
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		msg         string
+		content     *string
+		wantedValue string
+		wantedErr   error
+	}{
+		{
+			msg:         "value is read from the file",
+			content:     new("api-key"),
+			wantedValue: "api-key",
+		},
+		{
+			msg:         "trailing newline is trimmed",
+			content:     new("api-key\n"),
+			wantedValue: "api-key",
+		},
+		{
+			msg:       "empty file is rejected",
+			content:   new(" \n"),
+			wantedErr: ErrEmpty,
+		},
+		{
+			msg:       "missing file is rejected",
+			wantedErr: os.ErrNotExist,
+		},
+	}
+
+	for i, c := range cases {
+		path := filepath.Join(dir, fmt.Sprintf("secret-%d", i))
+		if c.content != nil {
+			assert.NoError(t, os.WriteFile(path, []byte(*c.content), 0o600), c.msg)
+		}
+
+		f, err := Load(path)
+		if c.wantedErr != nil {
+			assert.ErrorIs(t, err, c.wantedErr, c.msg)
+			continue
+		}
+		assert.NoError(t, err, c.msg)
+		value, err := f.Value()
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, c.wantedValue, value, c.msg)
+	}
+}
+
+func TestFileRotation(t *testing.T) {
+	dir := t.TempDir()
+	// a Secret volume points the key to the current ..data directory, which the kubelet swaps on rotation
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "v1"), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "v1", "token"), []byte("old-token"), 0o600))
+	assert.NoError(t, os.Symlink("v1", filepath.Join(dir, "..data")))
+	assert.NoError(t, os.Symlink(filepath.Join("..data", "token"), filepath.Join(dir, "token")))
+
+	f, err := Load(filepath.Join(dir, "token"))
+	assert.NoError(t, err)
+	value, err := f.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "old-token", value)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "v2"), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "v2", "token"), []byte("new-token"), 0o600))
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, "v2", "token"), time.Time{}, time.Now().Add(time.Second)))
+	assert.NoError(t, os.Symlink("v2", filepath.Join(dir, "..data_tmp")))
+	assert.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+
+	value, err = f.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "new-token", value)
+
+	// the last value is not served once the key is removed from the Secret
+	assert.NoError(t, os.Remove(filepath.Join(dir, "v2", "token")))
+	_, err = f.Value()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFileRedacted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(path, []byte("admin-token"), 0o600))
+	f, err := Load(path)
+	assert.NoError(t, err)
+
+	holder := struct{ Token *File }{f}
+	for _, printed := range []string{
+		fmt.Sprint(f),
+		fmt.Sprintf("%+v", holder),
+		fmt.Sprintf("%#v", holder),
+	} {
+		assert.NotContains(t, printed, "admin-token")
+		assert.Contains(t, printed, Redacted)
+	}
+	encoded, err := json.Marshal(holder)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"Token":"[REDACTED]"}`, string(encoded))
+}
@@ -0,0 +1,15 @@
+//go:build !sgx
+
+package sgxplatforminfo
+
+import (
+	"errors"
+)
+
+// ErrSgxSupportNotCompiled is returned when the binary was built without the sgx build tag
+var ErrSgxSupportNotCompiled = errors.New("sgx_platform_info: binary built without SGX support (sgx build tag)")
+
+// GetSgxPlatformInfo always fails without SGX support
+func GetSgxPlatformInfo() (*SgxPlatformInfo, error) {
+	return nil, ErrSgxSupportNotCompiled
+}
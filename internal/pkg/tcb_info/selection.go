@@ -0,0 +1,86 @@
+package tcbinfo
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	pckcert "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/pck_cert"
+)
+
+// ErrNoMatchingPCK is returned when none of the PCK certificates can be used at the raw TCB of the platform
+var ErrNoMatchingPCK = errors.New("no PCK certificate matches the raw TCB of the platform")
+
+// rankedPCK is a PCK certificate, its SGX extensions and the index of the TCB level it matches
+type rankedPCK struct {
+	cert  *pckcert.Certificate
+	ext   *pckcert.SGXExtensions
+	level int
+}
+
+// SelectPCK picks the PCK certificate to use at the raw TCB of the platform, like the Intel PCK Cert
+// Selection library: among the certificates of the platform PCEID whose CPUSVN components and PCESVN
+// do not exceed the raw ones, it returns the one matching the highest TCB level of info.
+// Certificates of the same TCB level, or all of them without TCB Info, are ranked by PCESVN and then
+// by CPUSVN components.
+func SelectPCK(certs []*pckcert.Certificate, platform pckcert.Platform, info *TCBInfo) (*pckcert.Certificate, error) {
+	rawCPUSVN, err := hex.DecodeString(platform.CPUSVN)
+	if err != nil || len(rawCPUSVN) != cpusvnComponentCount {
+		return nil, fmt.Errorf("invalid raw CPUSVN '%s'", platform.CPUSVN)
+	}
+	pceid, err := hex.DecodeString(platform.PCEID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PCEID '%s'", platform.PCEID)
+	}
+
+	var best *rankedPCK
+	for _, cert := range certs {
+		ext, err := pckcert.ParseSGXExtensions(cert.Leaf)
+		if err != nil || !bytes.Equal(ext.PCEID[:], pceid) || !lowerOrEqual(ext.CPUSVN[:], rawCPUSVN) || ext.PCESVN > platform.PCESVN {
+			continue
+		}
+		candidate := &rankedPCK{cert: cert, ext: ext, level: levelIndex(info, ext)}
+		if best == nil || candidate.higherThan(best) {
+			best = candidate
+		}
+	}
+	if best == nil {
+		return nil, ErrNoMatchingPCK
+	}
+	return best.cert, nil
+}
+
+// levelIndex returns the index of the highest TCB level of info matched by the certificate TCB,
+// or the number of TCB levels when it matches none
+func levelIndex(info *TCBInfo, ext *pckcert.SGXExtensions) int {
+	if info == nil {
+		return 0
+	}
+	for i, level := range info.TCBLevels {
+		if matches(level.TCB, ext.CPUSVN, ext.PCESVN) {
+			return i
+		}
+	}
+	return len(info.TCBLevels)
+}
+
+func (p *rankedPCK) higherThan(other *rankedPCK) bool {
+	if p.level != other.level {
+		return p.level < other.level
+	}
+	if p.ext.PCESVN != other.ext.PCESVN {
+		return p.ext.PCESVN > other.ext.PCESVN
+	}
+	return bytes.Compare(p.ext.CPUSVN[:], other.ext.CPUSVN[:]) > 0
+}
+
+// lowerOrEqual reports whether each component of a is lower than or equal to the one of b
+func lowerOrEqual(a, b []byte) bool {
+	for i := range a {
+		if a[i] > b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+// Package tcbinfo decodes the SGX TCB Info returned by the Intel PCS and PCCS and evaluates the
+// TCB status of a platform against it (see the Intel SGX and TDX PCS API Specification, version 4).
+package tcbinfo
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	pckcert "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/pck_cert"
+)
+
+// IssuerChainHeader holds the TCB Signing and root CA certificates of a TCB Info response
+const IssuerChainHeader = "TCB-Info-Issuer-Chain"
+
+// TCB statuses of the TCB levels
+const (
+	StatusUpToDate                          = "UpToDate"
+	StatusSWHardeningNeeded                 = "SWHardeningNeeded"
+	StatusConfigurationNeeded               = "ConfigurationNeeded"
+	StatusConfigurationAndSWHardeningNeeded = "ConfigurationAndSWHardeningNeeded"
+	StatusOutOfDate                         = "OutOfDate"
+	StatusOutOfDateConfigurationNeeded      = "OutOfDateConfigurationNeeded"
+	StatusRevoked                           = "Revoked"
+	// StatusUnrecognized is reported when the platform TCB is lower than all the TCB levels
+	StatusUnrecognized = "Unrecognized"
+)
+
+const (
+	// SGX TCB Info of the version 4 API
+	tcbInfoID      = "SGX"
+	tcbInfoVersion = 3
+
+	cpusvnComponentCount = 16
+	// the signature is the concatenation of the 32 bytes r and s ECDSA P-256 values
+	signatureSize = 64
+)
+
+var (
+	// ErrInvalidResponse is returned when a TCB Info response cannot be decoded
+	ErrInvalidResponse = errors.New("invalid TCB Info response")
+	// ErrVerificationFailed is returned when a TCB Info is not signed by a certificate issued by the root CA
+	ErrVerificationFailed = errors.New("TCB Info verification failed")
+)
+
+// TCBInfo is the TCB Info of an FMSPC
+type TCBInfo struct {
+	ID                      string     `json:"id"`
+	Version                 int        `json:"version"`
+	IssueDate               time.Time  `json:"issueDate"`
+	NextUpdate              time.Time  `json:"nextUpdate"`
+	FMSPC                   string     `json:"fmspc"`
+	PCEID                   string     `json:"pceId"`
+	TCBType                 int        `json:"tcbType"`
+	TCBEvaluationDataNumber int        `json:"tcbEvaluationDataNumber"`
+	TCBLevels               []TCBLevel `json:"tcbLevels"`
+}
+
+// TCBLevel is a TCB level of the TCB Info, sorted from the highest to the lowest
+type TCBLevel struct {
+	TCB         TCB       `json:"tcb"`
+	TCBDate     time.Time `json:"tcbDate"`
+	TCBStatus   string    `json:"tcbStatus"`
+	AdvisoryIDs []string  `json:"advisoryIDs,omitempty"`
+}
+
+// TCB holds the minimum CPUSVN components and PCESVN of a TCB level
+type TCB struct {
+	SGXTCBComponents []TCBComponent `json:"sgxtcbcomponents"`
+	PCESVN           uint16         `json:"pcesvn"`
+}
+
+// TCBComponent is a CPUSVN component of a TCB level
+type TCBComponent struct {
+	SVN      uint8  `json:"svn"`
+	Category string `json:"category,omitempty"`
+	Type     string `json:"type,omitempty"`
+}
+
+// Response is a TCB Info and the signature returned along with it
+type Response struct {
+	TCBInfo TCBInfo
+	// Signature is the ECDSA signature of the JSON encoded TCB Info, as returned in the response body
+	Signature []byte
+	// IssuerChain holds the TCB Signing and root CA certificates
+	IssuerChain []*x509.Certificate
+
+	rawTCBInfo []byte
+}
+
+// ParseResponse decodes the body and headers of a successful TCB Info response
+func ParseResponse(body []byte, header http.Header) (*Response, error) {
+	var envelope struct {
+		TCBInfo   json.RawMessage `json:"tcbInfo"`
+		Signature string          `json:"signature"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	if len(envelope.TCBInfo) == 0 {
+		return nil, fmt.Errorf("%w: missing tcbInfo", ErrInvalidResponse)
+	}
+
+	signature, err := hex.DecodeString(envelope.Signature)
+	if err != nil || len(signature) != signatureSize {
+		return nil, fmt.Errorf("%w: signature must be %d hex encoded bytes", ErrInvalidResponse, signatureSize)
+	}
+
+	issuerChain, err := pckcert.ParseIssuerChain(header.Get(IssuerChainHeader))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+
+	var tcbInfo TCBInfo
+	if err := json.Unmarshal(envelope.TCBInfo, &tcbInfo); err != nil {
+		return nil, fmt.Errorf("%w: tcbInfo: %w", ErrInvalidResponse, err)
+	}
+	if tcbInfo.ID != tcbInfoID || tcbInfo.Version != tcbInfoVersion {
+		return nil, fmt.Errorf("%w: unsupported TCB Info %s version %d", ErrInvalidResponse, tcbInfo.ID, tcbInfo.Version)
+	}
+	for i, level := range tcbInfo.TCBLevels {
+		if len(level.TCB.SGXTCBComponents) != cpusvnComponentCount {
+			return nil, fmt.Errorf("%w: TCB level %d has %d components, expected %d",
+				ErrInvalidResponse, i, len(level.TCB.SGXTCBComponents), cpusvnComponentCount)
+		}
+	}
+
+	return &Response{
+		TCBInfo:     tcbInfo,
+		Signature:   signature,
+		IssuerChain: issuerChain,
+		rawTCBInfo:  envelope.TCBInfo,
+	}, nil
+}
+
+// Verify checks that the TCB Info is signed by the first certificate of the issuer chain,
+// which must chain up to root at the given time
+func (r *Response) Verify(root *x509.Certificate, now time.Time) error {
+	if err := pckcert.VerifySignature(r.IssuerChain, root, r.rawTCBInfo, r.Signature, now); err != nil {
+		return fmt.Errorf("%w: %w", ErrVerificationFailed, err)
+	}
+	return nil
+}
+
+// Evaluate returns the highest TCB level whose CPUSVN components and PCESVN are all lower than or
+// equal to those of the platform, or false if the platform TCB is lower than all the TCB levels
+func (t *TCBInfo) Evaluate(cpusvn [16]byte, pcesvn uint16) (TCBLevel, bool) {
+	for _, level := range t.TCBLevels {
+		if matches(level.TCB, cpusvn, pcesvn) {
+			return level, true
+		}
+	}
+	return TCBLevel{}, false
+}
+
+// MatchesPlatform reports whether the TCB Info was issued for the hex encoded FMSPC and PCEID
+func (t *TCBInfo) MatchesPlatform(fmspc, pceid string) bool {
+	return strings.EqualFold(t.FMSPC, fmspc) && strings.EqualFold(t.PCEID, pceid)
+}
+
+func matches(tcb TCB, cpusvn [16]byte, pcesvn uint16) bool {
+	for i, component := range tcb.SGXTCBComponents {
+		if cpusvn[i] < component.SVN {
+			return false
+		}
+	}
+	return pcesvn >= tcb.PCESVN
+}
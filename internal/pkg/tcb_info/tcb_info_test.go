@@ -0,0 +1,261 @@
+package tcbinfo_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	fakepcs "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/fake_pcs"
+	pckcert "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/pck_cert"
+	tcbinfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/tcb_info"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTCBInfo = fakepcs.TCBInfo("00906ED50000", "0000",
+	fakepcs.TCBLevel("0f0f0303ff8003000000000000000000", 13, tcbinfo.StatusUpToDate),
+	fakepcs.TCBLevel("0f0f0202ff8003000000000000000000", 13, tcbinfo.StatusSWHardeningNeeded, "INTEL-SA-00615"),
+	fakepcs.TCBLevel("07070202ff8003000000000000000000", 10, tcbinfo.StatusOutOfDate, "INTEL-SA-00615", "INTEL-SA-00828"),
+)
+
+func TestParseResponse(t *testing.T) {
+	ca, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	ca.WriteTCBInfoResponse(recorder, testTCBInfo)
+
+	response, err := tcbinfo.ParseResponse(recorder.Body.Bytes(), recorder.Header())
+	assert.NoError(t, err)
+	assert.Equal(t, testTCBInfo, response.TCBInfo)
+	assert.Len(t, response.IssuerChain, 2)
+	assert.Equal(t, ca.TCBSigning.Raw, response.IssuerChain[0].Raw)
+	assert.True(t, response.TCBInfo.MatchesPlatform("00906ed50000", "0000"))
+	assert.False(t, response.TCBInfo.MatchesPlatform("00606a000000", "0000"))
+	assert.NoError(t, response.Verify(ca.Root, time.Now()))
+}
+
+func TestParseResponseInvalid(t *testing.T) {
+	ca, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	valid := httptest.NewRecorder()
+	ca.WriteTCBInfoResponse(valid, testTCBInfo)
+
+	unsupported := testTCBInfo
+	unsupported.Version = 2
+	unsupportedResponse := httptest.NewRecorder()
+	ca.WriteTCBInfoResponse(unsupportedResponse, unsupported)
+
+	cases := []struct {
+		msg    string
+		body   []byte
+		header string
+	}{
+		{
+			msg:    "body is not JSON",
+			body:   []byte("not json"),
+			header: valid.Header().Get(tcbinfo.IssuerChainHeader),
+		},
+		{
+			msg:    "signature is truncated",
+			body:   bytes.Replace(valid.Body.Bytes(), []byte(`"signature":"`), []byte(`"signature":"00`), 1),
+			header: valid.Header().Get(tcbinfo.IssuerChainHeader),
+		},
+		{
+			msg:  "issuer chain is missing",
+			body: valid.Body.Bytes(),
+		},
+		{
+			msg:    "TCB Info version is not supported",
+			body:   unsupportedResponse.Body.Bytes(),
+			header: unsupportedResponse.Header().Get(tcbinfo.IssuerChainHeader),
+		},
+	}
+
+	for _, c := range cases {
+		header := valid.Header().Clone()
+		header.Set(tcbinfo.IssuerChainHeader, c.header)
+		_, err := tcbinfo.ParseResponse(c.body, header)
+		assert.ErrorIs(t, err, tcbinfo.ErrInvalidResponse, c.msg)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ca, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	untrustedCA, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	ca.WriteTCBInfoResponse(recorder, testTCBInfo)
+
+	cases := []struct {
+		msg     string
+		body    []byte
+		root    *fakepcs.CA
+		now     time.Time
+		wantErr bool
+	}{
+		{
+			msg:  "signed TCB Info is accepted",
+			body: recorder.Body.Bytes(),
+			root: ca,
+			now:  time.Now(),
+		},
+		{
+			msg:     "TCB Info of an untrusted root is rejected",
+			body:    recorder.Body.Bytes(),
+			root:    untrustedCA,
+			now:     time.Now(),
+			wantErr: true,
+		},
+		{
+			msg:     "TCB Info with an expired signing certificate is rejected",
+			body:    recorder.Body.Bytes(),
+			root:    ca,
+			now:     time.Now().Add(20 * 365 * 24 * time.Hour),
+			wantErr: true,
+		},
+		{
+			msg:     "modified TCB Info is rejected",
+			body:    bytes.Replace(recorder.Body.Bytes(), []byte(`"OutOfDate"`), []byte(`"UpToDate"`), 1),
+			root:    ca,
+			now:     time.Now(),
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		response, err := tcbinfo.ParseResponse(c.body, recorder.Header())
+		assert.NoError(t, err, c.msg)
+		err = response.Verify(c.root.Root, c.now)
+		if c.wantErr {
+			assert.ErrorIs(t, err, tcbinfo.ErrVerificationFailed, c.msg)
+		} else {
+			assert.NoError(t, err, c.msg)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	cases := []struct {
+		msg            string
+		cpusvn         [16]byte
+		pcesvn         uint16
+		wantedFound    bool
+		wantedStatus   string
+		wantedAdvisory []string
+	}{
+		{
+			msg:          "platform at the highest TCB level is up to date",
+			cpusvn:       [16]byte{0x0f, 0x0f, 0x03, 0x03, 0xff, 0x80, 0x03},
+			pcesvn:       13,
+			wantedFound:  true,
+			wantedStatus: tcbinfo.StatusUpToDate,
+		},
+		{
+			msg:          "platform above the highest TCB level is up to date",
+			cpusvn:       [16]byte{0x10, 0x0f, 0x03, 0x03, 0xff, 0x80, 0x03},
+			pcesvn:       14,
+			wantedFound:  true,
+			wantedStatus: tcbinfo.StatusUpToDate,
+		},
+		{
+			msg:            "a single lower component selects the next level",
+			cpusvn:         [16]byte{0x0f, 0x0f, 0x03, 0x02, 0xff, 0x80, 0x03},
+			pcesvn:         13,
+			wantedFound:    true,
+			wantedStatus:   tcbinfo.StatusSWHardeningNeeded,
+			wantedAdvisory: []string{"INTEL-SA-00615"},
+		},
+		{
+			msg:            "a lower PCESVN selects the next level",
+			cpusvn:         [16]byte{0x0f, 0x0f, 0x03, 0x03, 0xff, 0x80, 0x03},
+			pcesvn:         11,
+			wantedFound:    true,
+			wantedStatus:   tcbinfo.StatusOutOfDate,
+			wantedAdvisory: []string{"INTEL-SA-00615", "INTEL-SA-00828"},
+		},
+		{
+			msg:    "platform below all the TCB levels is not found",
+			cpusvn: [16]byte{0x0f, 0x0f, 0x03, 0x03, 0xff, 0x80, 0x03},
+			pcesvn: 9,
+		},
+	}
+
+	for _, c := range cases {
+		level, found := testTCBInfo.Evaluate(c.cpusvn, c.pcesvn)
+		assert.Equal(t, c.wantedFound, found, c.msg)
+		assert.Equal(t, c.wantedStatus, level.TCBStatus, c.msg)
+		assert.Equal(t, c.wantedAdvisory, level.AdvisoryIDs, c.msg)
+	}
+}
+
+func TestSelectPCK(t *testing.T) {
+	ca, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	pck := func(cpusvn, pcesvn string) fakepcs.PCK {
+		return fakepcs.PCK{FMSPC: "00906ed50000", CPUSVN: cpusvn, PCESVN: pcesvn, PCEID: "0000"}
+	}
+	recorder := httptest.NewRecorder()
+	ca.WritePCKCertsResponse(recorder,
+		pck("0f0f0303ff8003000000000000000000", "0d00"),
+		pck("0f0f0202ff8003000000000000000000", "0d00"),
+		pck("0f0f0202ff8003000000000000000000", "0b00"),
+		pck("07070202ff8003000000000000000000", "0a00"),
+	)
+	certs, err := pckcert.ParseCertsResponse(recorder.Body.Bytes(), recorder.Header())
+	assert.NoError(t, err)
+
+	cases := []struct {
+		msg        string
+		platform   pckcert.Platform
+		tcbInfo    *tcbinfo.TCBInfo
+		wantedTCBm string
+		wantErr    bool
+	}{
+		{
+			msg:        "certificate of the raw TCB is selected",
+			platform:   pckcert.Platform{PCEID: "0000", CPUSVN: "0f0f0303ff8003000000000000000000", PCESVN: 13},
+			tcbInfo:    &testTCBInfo,
+			wantedTCBm: "0f0f0303ff80030000000000000000000d00",
+		},
+		{
+			msg:        "certificates of the same TCB level are ranked by PCESVN",
+			platform:   pckcert.Platform{PCEID: "0000", CPUSVN: "0f0f0303ff8003000000000000000000", PCESVN: 12},
+			tcbInfo:    &testTCBInfo,
+			wantedTCBm: "0f0f0202ff80030000000000000000000b00",
+		},
+		{
+			msg:        "a lower CPUSVN component selects the next TCB level",
+			platform:   pckcert.Platform{PCEID: "0000", CPUSVN: "0f0f0203ff8003000000000000000000", PCESVN: 14},
+			tcbInfo:    &testTCBInfo,
+			wantedTCBm: "0f0f0202ff80030000000000000000000d00",
+		},
+		{
+			msg:        "certificates are ranked without TCB Info",
+			platform:   pckcert.Platform{PCEID: "0000", CPUSVN: "0f0f0303ff8003000000000000000000", PCESVN: 13},
+			wantedTCBm: "0f0f0303ff80030000000000000000000d00",
+		},
+		{
+			msg:      "raw TCB below all the certificates",
+			platform: pckcert.Platform{PCEID: "0000", CPUSVN: "0f0f0303ff8003000000000000000000", PCESVN: 9},
+			tcbInfo:  &testTCBInfo,
+			wantErr:  true,
+		},
+		{
+			msg:      "certificates of another PCEID",
+			platform: pckcert.Platform{PCEID: "0001", CPUSVN: "0f0f0303ff8003000000000000000000", PCESVN: 13},
+			tcbInfo:  &testTCBInfo,
+			wantErr:  true,
+		},
+	}
+
+	for _, c := range cases {
+		cert, err := tcbinfo.SelectPCK(certs, c.platform, c.tcbInfo)
+		if c.wantErr {
+			assert.ErrorIs(t, err, tcbinfo.ErrNoMatchingPCK, c.msg)
+			continue
+		}
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, c.wantedTCBm, cert.TCBm, c.msg)
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"syscall"
 	"time"
 
+	mpmanagement "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_management"
+	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
 	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/config"
 	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/constants"
 	"github.com/spf13/pflag"
@@ -145,6 +147,16 @@ func createLogger(level string, encoder string, timeEncoding string) (*zap.Logge
 	return cfg.Build()
 }
 
+// newPlatformManifestSource creates the UEFI backend selected in the configuration
+func newPlatformManifestSource(cfg *config.RegistrationServiceConfig) (registration.PlatformManifestSource, func()) {
+	if cfg.UEFIBackend == constants.UEFIBackendEfivarfs {
+		mp := mpmanagement.NewEfivarfsMPManagement(cfg.EfivarsPath)
+		return mp, mp.Close
+	}
+	mp := mpmanagement.NewMPManagement()
+	return mp, mp.Close
+}
+
 // runService starts the registration service and HTTP server
 func runService(ctx context.Context, logger *zap.Logger) error {
 	// Log application startup information
@@ -162,15 +174,31 @@ func runService(ctx context.Context, logger *zap.Logger) error {
 
 	// Log configuration (without sensitive data)
 	logger.Info("Configuration loaded",
+		zap.String("environment", cfg.Environment),
+		zap.String("registrationURL", cfg.IntelRegistrationURL),
+		zap.String("pcsAPIVersion", cfg.PCSAPIVersion),
 		zap.Int("pccsURLCount", len(cfg.PCCSURLs)),
+		zap.String("pccsOrder", cfg.PCCSOrder),
+		zap.Int("pccsBreakerFailures", cfg.PCCSBreakerFailures),
+		zap.Bool("pccsHedgePCK", cfg.PCCSHedgePCK),
 		zap.Bool("customCACert", cfg.PCCSCACertPath != ""),
 		zap.Duration("registrationInterval", cfg.RegistrationInterval),
-		zap.Int("servicePort", cfg.ServicePort))
+		zap.Duration("checkTimeout", cfg.CheckTimeout),
+		zap.Int("retryMaxAttempts", cfg.RetryMaxAttempts),
+		zap.Int("servicePort", cfg.ServicePort),
+		zap.String("uefiBackend", cfg.UEFIBackend),
+		zap.String("efivarsPath", cfg.EfivarsPath),
+		zap.String("proxyMode", cfg.ProxyMode))
 
 	signalCtx, signalCancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer signalCancel()
 
-	registrationService := registration.NewRegistrationService(logger, cfg, cfg.RegistrationInterval)
+	// SGX hardware access (cgo implementations when built with the sgx build tag)
+	manifestSource, closeManifestSource := newPlatformManifestSource(cfg)
+	defer closeManifestSource()
+
+	registrationService := registration.NewRegistrationService(logger, cfg, cfg.RegistrationInterval,
+		manifestSource, sgxplatforminfo.NewProvider())
 
 	// Create a context with cancel function for shutdown
 	g, gCtx := errgroup.WithContext(signalCtx)
@@ -193,6 +221,7 @@ func runService(ctx context.Context, logger *zap.Logger) error {
 	// Setup HTTP server
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/status", registrationService.StatusHandler())
 	mux.HandleFunc("/live", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, "Service is healthy")
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/secret"
 	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/constants"
 )
 
@@ -17,12 +19,96 @@ type RegistrationServiceConfig struct {
 	PCCSURLs       []string // Parsed from CC_PCCS_URLS
 	PCCSCACertPath string   // From CC_PCCS_CA_CERT_PATH (directory with custom CA certificates)
 
-	// Intel fallback endpoints
+	// PrefetchCollateral requests the TCB Info, QE/QvE identities and CRLs of the platform from each PCCS
+	// after the PCK certificate retrieval, so that they are cached before the first quote verification.
+	// From CC_PCCS_PREFETCH_COLLATERAL
+	PrefetchCollateral bool
+
+	// PCCSAddPlatform adds the registered platform to each PCCS with the PCCS user token, so that a PCCS
+	// in REQ mode fetches and caches its PCK certificates. From CC_PCCS_ADD_PLATFORM
+	PCCSAddPlatform bool
+
+	// PCCSUploadPCKCerts uploads the PCK certificates retrieved from Intel to each PCCS with the PCCS admin
+	// token, so that a PCCS in OFFLINE mode serves them. From CC_PCCS_UPLOAD_PCK_CERTS
+	PCCSUploadPCKCerts bool
+
+	// PCCSConsistencyInterval is the interval at which the PCK certificate served by each PCCS is compared
+	// with the one of Intel, disabled when 0. From CC_PCCS_CONSISTENCY_INTERVAL_MINUTES
+	PCCSConsistencyInterval time.Duration
+	// PCCSConsistencyRefresh asks a diverging PCCS to refresh its PCK certificates cache with the PCCS
+	// admin token. From CC_PCCS_CONSISTENCY_REFRESH
+	PCCSConsistencyRefresh bool
+
+	// PCCSBreakerFailures is the number of consecutive failures after which a PCCS is skipped for
+	// PCCSBreakerCoolDown, disabled when 0. From CC_PCCS_BREAKER_FAILURES and CC_PCCS_BREAKER_COOLDOWN_MINUTES
+	PCCSBreakerFailures int
+	PCCSBreakerCoolDown time.Duration
+	// PCCSOrder is the order in which the PCCS are tried before Intel, "config", "sticky", "weighted" or
+	// "random". From CC_PCCS_ORDER
+	PCCSOrder string
+	// PCCSWeights are the weights of the PCCSURLs of the "weighted" order. From CC_PCCS_WEIGHTS
+	PCCSWeights []int
+	// PCCSHedgePCK requests the PCK certificate from the next PCCS after PCCSHedgeDelay without waiting for
+	// the previous ones, all at once when the delay is 0. From CC_PCCS_HEDGE_PCK and CC_PCCS_HEDGE_DELAY_MILLISECONDS
+	PCCSHedgePCK   bool
+	PCCSHedgeDelay time.Duration
+
+	// PCCS credentials, read from files so that mounted Secrets are rotated without restart
+	PCCSUserToken  *secret.File // From CC_PCCS_USER_TOKEN_FILE, sent to the PCCS user endpoints only
+	PCCSAdminToken *secret.File // From CC_PCCS_ADMIN_TOKEN_FILE, sent to the PCCS admin endpoints only
+
+	// Environment is the Intel environment of the registration and PCS endpoints, "production", "sandbox"
+	// or "custom". From CC_IPR_ENVIRONMENT
+	Environment string
+	// PCSAPIVersion is the version of the PCS API of Intel and the PCCS. From CC_IPR_PCS_API_VERSION
+	PCSAPIVersion string
+
+	// Intel fallback endpoints, set by the environment
 	IntelRegistrationURL string
+	IntelAddPackageURL   string
 	IntelPCKRetrievalURL string
+	IntelPCKCRLURL       string
+	IntelTCBInfoURL      string
+	IntelQEIdentityURL   string
+	IntelQvEIdentityURL  string
+	IntelRootCACRLURL    string
+	IntelPCKCertsURL     string
+
+	// IntelAPIKey is the Intel PCS API subscription key, sent to the Intel PCK certificate endpoints only.
+	// The /pckcerts requests are only sent when it is set. From CC_IPR_INTEL_API_KEY_FILE
+	IntelAPIKey *secret.File
+
+	// SGXRootCAPath overrides the embedded Intel SGX Root CA that PCK certificates are verified against.
+	// From CC_IPR_SGX_ROOT_CA_PATH
+	SGXRootCAPath string
+
+	// UEFI settings
+	UEFIBackend string // From CC_IPR_UEFI_BACKEND
+	EfivarsPath string // From CC_IPR_EFIVARS_PATH (efivarfs backend only)
+
+	// FollowUEFIRegistrationURL sends registration requests to the registration server of the
+	// SgxRegistrationConfiguration UEFI variable when it differs from IntelRegistrationURL.
+	// From CC_IPR_FOLLOW_UEFI_REGISTRATION_URL
+	FollowUEFIRegistrationURL bool
+
+	// Registration state
+	StateFile     string // From CC_IPR_STATE_FILE, kept in memory only when empty
+	ForceResubmit bool   // From CC_IPR_FORCE_RESUBMIT
+
+	// Proxy settings of the outbound HTTP traffic
+	ProxyMode string // From CC_IPR_PROXY_MODE
+	ProxyURL  string // From CC_IPR_PROXY_URL (manual mode only)
 
 	// HTTP client settings
 	RequestTimeout time.Duration
+	// CheckTimeout is the deadline of each registration check, including all its requests; disabled when 0.
+	// From CC_IPR_CHECK_TIMEOUT_MINUTES
+	CheckTimeout time.Duration
+	// Retry policy of the requests to Intel and the PCCS; a single attempt when RetryMaxAttempts is below 2.
+	// From CC_IPR_RETRY_MAX_ATTEMPTS, CC_IPR_RETRY_INITIAL_BACKOFF_SECONDS and CC_IPR_RETRY_MAX_BACKOFF_SECONDS
+	RetryMaxAttempts    int
+	RetryInitialBackoff time.Duration
+	RetryMaxBackoff     time.Duration
 
 	// Service settings
 	RegistrationInterval time.Duration
@@ -32,9 +118,26 @@ type RegistrationServiceConfig struct {
 // LoadRegistrationServiceConfig loads configuration from environment variables
 func LoadRegistrationServiceConfig() (*RegistrationServiceConfig, error) {
 	config := &RegistrationServiceConfig{
-		IntelRegistrationURL: constants.IntelPlatformRegistrationEndpoint,
-		IntelPCKRetrievalURL: constants.IntelPckRetrievalEndpoint,
-		RequestTimeout:       constants.IntelRequestTimeout,
+		Environment:         constants.EnvironmentProduction,
+		PCSAPIVersion:       constants.PCSAPIVersionV4,
+		RequestTimeout:      constants.IntelRequestTimeout,
+		CheckTimeout:        constants.DefaultCheckTimeout,
+		RetryMaxAttempts:    constants.DefaultRetryMaxAttempts,
+		RetryInitialBackoff: constants.DefaultRetryInitialBackoff,
+		RetryMaxBackoff:     constants.DefaultRetryMaxBackoff,
+		UEFIBackend:         constants.UEFIBackendMPManagement,
+		EfivarsPath:         constants.DefaultEfivarsPath,
+		ProxyMode:           constants.ProxyModeUEFI,
+		StateFile:           constants.DefaultStateFile,
+		PCCSBreakerFailures: constants.DefaultPCCSBreakerFailures,
+		PCCSBreakerCoolDown: constants.DefaultPCCSBreakerCoolDown,
+		PCCSOrder:           constants.PCCSOrderConfig,
+		PCCSHedgeDelay:      constants.DefaultPCCSHedgeDelay,
+	}
+
+	// Load the Intel environment, which sets all the Intel endpoints
+	if err := config.loadEnvironment(); err != nil {
+		return nil, err
 	}
 
 	// Parse PCCS URLs (optional)
@@ -62,9 +165,136 @@ func LoadRegistrationServiceConfig() (*RegistrationServiceConfig, error) {
 		}
 	}
 
+	// Load PCCS health tracking and selection
+	if err := config.loadPCCSSelection(); err != nil {
+		return nil, err
+	}
+
 	// Load CA cert path (optional - directory containing custom CA certificates for PCCS)
 	config.PCCSCACertPath = os.Getenv(constants.PCCSCACertPathEnv)
 
+	if prefetchEnv := os.Getenv(constants.PCCSPrefetchCollateralEnv); prefetchEnv != "" {
+		prefetch, err := strconv.ParseBool(prefetchEnv)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value '%s': %w", constants.PCCSPrefetchCollateralEnv, prefetchEnv, err)
+		}
+		config.PrefetchCollateral = prefetch
+	}
+
+	// Load credentials (optional)
+	var err error
+	if config.PCCSUserToken, err = loadSecret(constants.PCCSUserTokenFileEnv); err != nil {
+		return nil, err
+	}
+	if config.PCCSAdminToken, err = loadSecret(constants.PCCSAdminTokenFileEnv); err != nil {
+		return nil, err
+	}
+	if config.IntelAPIKey, err = loadSecret(constants.IntelAPIKeyFileEnv); err != nil {
+		return nil, err
+	}
+
+	if addPlatformEnv := os.Getenv(constants.PCCSAddPlatformEnv); addPlatformEnv != "" {
+		addPlatform, err := strconv.ParseBool(addPlatformEnv)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value '%s': %w", constants.PCCSAddPlatformEnv, addPlatformEnv, err)
+		}
+		if addPlatform && config.PCCSUserToken == nil {
+			return nil, fmt.Errorf("%s requires %s", constants.PCCSAddPlatformEnv, constants.PCCSUserTokenFileEnv)
+		}
+		config.PCCSAddPlatform = addPlatform
+	}
+
+	if uploadEnv := os.Getenv(constants.PCCSUploadPCKCertsEnv); uploadEnv != "" {
+		upload, err := strconv.ParseBool(uploadEnv)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value '%s': %w", constants.PCCSUploadPCKCertsEnv, uploadEnv, err)
+		}
+		// the PCK certificates of all TCB levels are retrieved from Intel with the API key
+		if upload && (config.PCCSAdminToken == nil || config.IntelAPIKey == nil) {
+			return nil, fmt.Errorf("%s requires %s and %s", constants.PCCSUploadPCKCertsEnv,
+				constants.PCCSAdminTokenFileEnv, constants.IntelAPIKeyFileEnv)
+		}
+		config.PCCSUploadPCKCerts = upload
+	}
+
+	if intervalEnv := os.Getenv(constants.PCCSConsistencyIntervalEnv); intervalEnv != "" {
+		minutes, err := strconv.Atoi(intervalEnv)
+		if err != nil || minutes < 0 {
+			return nil, fmt.Errorf("invalid %s value '%s': must be a non-negative number of minutes", constants.PCCSConsistencyIntervalEnv, intervalEnv)
+		}
+		config.PCCSConsistencyInterval = time.Duration(minutes) * time.Minute
+	}
+	if refreshEnv := os.Getenv(constants.PCCSConsistencyRefreshEnv); refreshEnv != "" {
+		refresh, err := strconv.ParseBool(refreshEnv)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value '%s': %w", constants.PCCSConsistencyRefreshEnv, refreshEnv, err)
+		}
+		if refresh && config.PCCSAdminToken == nil {
+			return nil, fmt.Errorf("%s requires %s", constants.PCCSConsistencyRefreshEnv, constants.PCCSAdminTokenFileEnv)
+		}
+		config.PCCSConsistencyRefresh = refresh
+	}
+
+	// Load SGX root CA override (optional)
+	config.SGXRootCAPath = os.Getenv(constants.SGXRootCAPathEnv)
+
+	// Load UEFI backend (optional)
+	if backendEnv := os.Getenv(constants.UEFIBackendEnv); backendEnv != "" {
+		switch backendEnv {
+		case constants.UEFIBackendEfivarfs, constants.UEFIBackendMPManagement:
+			config.UEFIBackend = backendEnv
+		default:
+			return nil, fmt.Errorf("invalid UEFI backend '%s': must be '%s' or '%s'",
+				backendEnv, constants.UEFIBackendEfivarfs, constants.UEFIBackendMPManagement)
+		}
+	}
+	if efivarsPathEnv := os.Getenv(constants.EfivarsPathEnv); efivarsPathEnv != "" {
+		config.EfivarsPath = efivarsPathEnv
+	}
+
+	if followEnv := os.Getenv(constants.FollowUEFIRegistrationURLEnv); followEnv != "" {
+		follow, err := strconv.ParseBool(followEnv)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value '%s': %w", constants.FollowUEFIRegistrationURLEnv, followEnv, err)
+		}
+		config.FollowUEFIRegistrationURL = follow
+	}
+
+	// Load registration state settings (optional)
+	if stateFileEnv, ok := os.LookupEnv(constants.StateFileEnv); ok {
+		config.StateFile = stateFileEnv
+	}
+	if forceEnv := os.Getenv(constants.ForceResubmitEnv); forceEnv != "" {
+		force, err := strconv.ParseBool(forceEnv)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value '%s': %w", constants.ForceResubmitEnv, forceEnv, err)
+		}
+		config.ForceResubmit = force
+	}
+
+	// Load proxy settings (optional)
+	config.ProxyURL = os.Getenv(constants.ProxyURLEnv)
+	if config.ProxyURL != "" {
+		config.ProxyMode = constants.ProxyModeManual
+	}
+	if modeEnv := os.Getenv(constants.ProxyModeEnv); modeEnv != "" {
+		switch modeEnv {
+		case constants.ProxyModeUEFI, constants.ProxyModeEnvironment, constants.ProxyModeDirect, constants.ProxyModeManual:
+			config.ProxyMode = modeEnv
+		default:
+			return nil, fmt.Errorf("invalid proxy mode '%s': must be '%s', '%s', '%s' or '%s'", modeEnv,
+				constants.ProxyModeUEFI, constants.ProxyModeEnvironment, constants.ProxyModeDirect, constants.ProxyModeManual)
+		}
+	}
+	if config.ProxyMode == constants.ProxyModeManual {
+		if config.ProxyURL == "" {
+			return nil, fmt.Errorf("%s is required by the '%s' proxy mode", constants.ProxyURLEnv, constants.ProxyModeManual)
+		}
+		if _, err := ParseProxyURL(config.ProxyURL); err != nil {
+			return nil, err
+		}
+	}
+
 	// Load registration interval
 	intervalMinutes := constants.DefaultRegistrationServiceIntervalInMinutes
 	if intervalEnv := os.Getenv(constants.DefaultRegistrationServiceIntervalInMinutesEnv); intervalEnv != "" {
@@ -76,6 +306,39 @@ func LoadRegistrationServiceConfig() (*RegistrationServiceConfig, error) {
 	}
 	config.RegistrationInterval = time.Duration(intervalMinutes) * time.Minute
 
+	// Load check timeout
+	if timeoutEnv := os.Getenv(constants.CheckTimeoutEnv); timeoutEnv != "" {
+		minutes, err := strconv.Atoi(timeoutEnv)
+		if err != nil || minutes < 0 {
+			return nil, fmt.Errorf("invalid %s value '%s': must be a non-negative number of minutes", constants.CheckTimeoutEnv, timeoutEnv)
+		}
+		config.CheckTimeout = time.Duration(minutes) * time.Minute
+	}
+
+	// Load retry policy
+	if attemptsEnv := os.Getenv(constants.RetryMaxAttemptsEnv); attemptsEnv != "" {
+		attempts, err := strconv.Atoi(attemptsEnv)
+		if err != nil || attempts < 1 {
+			return nil, fmt.Errorf("invalid %s value '%s': must be a positive number of attempts", constants.RetryMaxAttemptsEnv, attemptsEnv)
+		}
+		config.RetryMaxAttempts = attempts
+	}
+	for env, backoff := range map[string]*time.Duration{
+		constants.RetryInitialBackoffEnv: &config.RetryInitialBackoff,
+		constants.RetryMaxBackoffEnv:     &config.RetryMaxBackoff,
+	} {
+		if backoffEnv := os.Getenv(env); backoffEnv != "" {
+			seconds, err := strconv.Atoi(backoffEnv)
+			if err != nil || seconds < 1 {
+				return nil, fmt.Errorf("invalid %s value '%s': must be a positive number of seconds", env, backoffEnv)
+			}
+			*backoff = time.Duration(seconds) * time.Second
+		}
+	}
+	if config.RetryMaxBackoff < config.RetryInitialBackoff {
+		return nil, fmt.Errorf("%s must not be lower than %s", constants.RetryMaxBackoffEnv, constants.RetryInitialBackoffEnv)
+	}
+
 	// Load service port
 	servicePort := constants.DefaultRegistrationServicePort
 	if portEnv := os.Getenv(constants.RegistrationServicePortEnv); portEnv != "" {
@@ -89,3 +352,196 @@ func LoadRegistrationServiceConfig() (*RegistrationServiceConfig, error) {
 
 	return config, nil
 }
+
+// ParseProxyURL parses a proxy URL, defaulting to the http scheme when it has none.
+// Errors never include the URL, which may hold credentials.
+func ParseProxyURL(rawURL string) (*url.URL, error) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch parsedURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy URL scheme '%s'", parsedURL.Scheme)
+	}
+	if parsedURL.Host == "" {
+		return nil, fmt.Errorf("proxy URL has no host")
+	}
+	return parsedURL, nil
+}
+
+// loadPCCSSelection loads the circuit breaker of the PCCS, the order in which they are tried and the
+// hedging of the PCK certificate requests
+func (c *RegistrationServiceConfig) loadPCCSSelection() error {
+	if failuresEnv := os.Getenv(constants.PCCSBreakerFailuresEnv); failuresEnv != "" {
+		failures, err := strconv.Atoi(failuresEnv)
+		if err != nil || failures < 0 {
+			return fmt.Errorf("invalid %s value '%s': must be a non-negative number of failures", constants.PCCSBreakerFailuresEnv, failuresEnv)
+		}
+		c.PCCSBreakerFailures = failures
+	}
+	if coolDownEnv := os.Getenv(constants.PCCSBreakerCoolDownEnv); coolDownEnv != "" {
+		minutes, err := strconv.Atoi(coolDownEnv)
+		if err != nil || minutes < 1 {
+			return fmt.Errorf("invalid %s value '%s': must be a positive number of minutes", constants.PCCSBreakerCoolDownEnv, coolDownEnv)
+		}
+		c.PCCSBreakerCoolDown = time.Duration(minutes) * time.Minute
+	}
+
+	if orderEnv := os.Getenv(constants.PCCSOrderEnv); orderEnv != "" {
+		switch orderEnv {
+		case constants.PCCSOrderConfig, constants.PCCSOrderSticky, constants.PCCSOrderWeighted, constants.PCCSOrderRandom:
+			c.PCCSOrder = orderEnv
+		default:
+			return fmt.Errorf("invalid PCCS order '%s': must be '%s', '%s', '%s' or '%s'", orderEnv,
+				constants.PCCSOrderConfig, constants.PCCSOrderSticky, constants.PCCSOrderWeighted, constants.PCCSOrderRandom)
+		}
+	}
+
+	if hedgeEnv := os.Getenv(constants.PCCSHedgePCKEnv); hedgeEnv != "" {
+		hedge, err := strconv.ParseBool(hedgeEnv)
+		if err != nil {
+			return fmt.Errorf("invalid %s value '%s': %w", constants.PCCSHedgePCKEnv, hedgeEnv, err)
+		}
+		c.PCCSHedgePCK = hedge
+	}
+	if delayEnv := os.Getenv(constants.PCCSHedgeDelayEnv); delayEnv != "" {
+		if !c.PCCSHedgePCK {
+			return fmt.Errorf("%s requires %s=true", constants.PCCSHedgeDelayEnv, constants.PCCSHedgePCKEnv)
+		}
+		milliseconds, err := strconv.Atoi(delayEnv)
+		if err != nil || milliseconds < 0 {
+			return fmt.Errorf("invalid %s value '%s': must be a non-negative number of milliseconds", constants.PCCSHedgeDelayEnv, delayEnv)
+		}
+		c.PCCSHedgeDelay = time.Duration(milliseconds) * time.Millisecond
+	}
+
+	weightsEnv := os.Getenv(constants.PCCSWeightsEnv)
+	if c.PCCSOrder != constants.PCCSOrderWeighted {
+		if weightsEnv != "" {
+			return fmt.Errorf("%s requires %s=%s", constants.PCCSWeightsEnv, constants.PCCSOrderEnv, constants.PCCSOrderWeighted)
+		}
+		return nil
+	}
+	if weightsEnv == "" {
+		return fmt.Errorf("%s=%s requires %s", constants.PCCSOrderEnv, constants.PCCSOrderWeighted, constants.PCCSWeightsEnv)
+	}
+	for rawWeight := range strings.SplitSeq(weightsEnv, ",") {
+		weight, err := strconv.Atoi(strings.TrimSpace(rawWeight))
+		if err != nil || weight < 1 {
+			return fmt.Errorf("invalid PCCS weight '%s': must be a positive number", rawWeight)
+		}
+		c.PCCSWeights = append(c.PCCSWeights, weight)
+	}
+	if len(c.PCCSWeights) != len(c.PCCSURLs) {
+		return fmt.Errorf("%s has %d weights for %d PCCS URLs", constants.PCCSWeightsEnv, len(c.PCCSWeights), len(c.PCCSURLs))
+	}
+	return nil
+}
+
+// loadEnvironment sets the Intel endpoints of the environment. Outside of production, no endpoint may point
+// to the production Intel services, so that pre-production platforms are never registered there by accident.
+func (c *RegistrationServiceConfig) loadEnvironment() error {
+	if environmentEnv := os.Getenv(constants.EnvironmentEnv); environmentEnv != "" {
+		c.Environment = environmentEnv
+	}
+	if versionEnv := os.Getenv(constants.PCSAPIVersionEnv); versionEnv != "" {
+		// the TCB Info of earlier versions has another format and issuer chain header
+		if versionEnv != constants.PCSAPIVersionV4 {
+			return fmt.Errorf("invalid PCS API version '%s': must be '%s'", versionEnv, constants.PCSAPIVersionV4)
+		}
+		c.PCSAPIVersion = versionEnv
+	}
+
+	registrationBaseURL := os.Getenv(constants.RegistrationBaseURLEnv)
+	pcsBaseURL := os.Getenv(constants.PCSBaseURLEnv)
+	if c.Environment != constants.EnvironmentCustom && (registrationBaseURL != "" || pcsBaseURL != "") {
+		return fmt.Errorf("%s and %s are only used by the '%s' environment",
+			constants.RegistrationBaseURLEnv, constants.PCSBaseURLEnv, constants.EnvironmentCustom)
+	}
+
+	switch c.Environment {
+	case constants.EnvironmentProduction:
+		registrationBaseURL, pcsBaseURL = constants.IntelProductionBaseURL, constants.IntelProductionBaseURL
+	case constants.EnvironmentSandbox:
+		registrationBaseURL, pcsBaseURL = constants.IntelSandboxBaseURL, constants.IntelSandboxBaseURL
+	case constants.EnvironmentCustom:
+		for env, baseURL := range map[string]*string{constants.RegistrationBaseURLEnv: &registrationBaseURL, constants.PCSBaseURLEnv: &pcsBaseURL} {
+			if *baseURL == "" {
+				return fmt.Errorf("%s is required by the '%s' environment", env, constants.EnvironmentCustom)
+			}
+			parsedURL, err := url.Parse(*baseURL)
+			if err != nil {
+				return fmt.Errorf("invalid %s '%s': %w", env, *baseURL, err)
+			}
+			if parsedURL.Scheme != "https" {
+				return fmt.Errorf("%s must use HTTPS: '%s'", env, *baseURL)
+			}
+			*baseURL = strings.TrimSuffix(*baseURL, "/")
+		}
+	default:
+		return fmt.Errorf("invalid environment '%s': must be '%s', '%s' or '%s'", c.Environment,
+			constants.EnvironmentProduction, constants.EnvironmentSandbox, constants.EnvironmentCustom)
+	}
+
+	if !c.IsProduction() {
+		for _, baseURL := range []string{registrationBaseURL, pcsBaseURL} {
+			if IsIntelProductionURL(baseURL) {
+				return fmt.Errorf("the '%s' environment must not use the production Intel services: '%s'", c.Environment, baseURL)
+			}
+		}
+	}
+
+	pcsURL := pcsBaseURL + constants.PCSCertificationPath + c.PCSAPIVersion
+	c.IntelRegistrationURL = registrationBaseURL + constants.IntelPlatformRegistrationPath
+	c.IntelAddPackageURL = registrationBaseURL + constants.IntelAddPackagePath
+	c.IntelPCKRetrievalURL = pcsURL + "/pckcert"
+	c.IntelPCKCRLURL = pcsURL + "/pckcrl"
+	c.IntelTCBInfoURL = pcsURL + "/tcb"
+	c.IntelQEIdentityURL = pcsURL + "/qe/identity"
+	c.IntelQvEIdentityURL = pcsURL + "/qve/identity"
+	c.IntelRootCACRLURL = constants.IntelSGXRootCACRLURL
+	c.IntelPCKCertsURL = pcsURL + "/pckcerts"
+	return nil
+}
+
+// IsProduction reports whether the Intel endpoints are the production ones. A configuration without
+// environment is treated as production.
+func (c *RegistrationServiceConfig) IsProduction() bool {
+	return c.Environment == "" || c.Environment == constants.EnvironmentProduction
+}
+
+// IsIntelProductionURL reports whether rawURL points to the production Intel services
+func IsIntelProductionURL(rawURL string) bool {
+	production, _ := url.Parse(constants.IntelProductionBaseURL)
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(parsedURL.Hostname(), production.Hostname())
+}
+
+// loadSecret loads the secret of the file named by the env variable, or returns nil when it is not set.
+// Errors only include the file path, never its content.
+func loadSecret(env string) (*secret.File, error) {
+	path := os.Getenv(env)
+	if path == "" {
+		return nil, nil
+	}
+	f, err := secret.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", env, err)
+	}
+	return f, nil
+}
@@ -1,9 +1,15 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/secret"
 	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/constants"
 )
 
@@ -70,3 +76,1031 @@ func TestLoadRegistrationServiceConfig_NoCACertPath(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadRegistrationServiceConfig_UEFIBackend(t *testing.T) {
+	tests := []struct {
+		name           string
+		backend        string
+		efivarsPath    string
+		expectError    bool
+		wantedBackend  string
+		wantedEfivarfs string
+	}{
+		{
+			name:           "Defaults to the mp_management backend",
+			wantedBackend:  constants.UEFIBackendMPManagement,
+			wantedEfivarfs: constants.DefaultEfivarsPath,
+		},
+		{
+			name:           "efivarfs backend with default path",
+			backend:        constants.UEFIBackendEfivarfs,
+			wantedBackend:  constants.UEFIBackendEfivarfs,
+			wantedEfivarfs: constants.DefaultEfivarsPath,
+		},
+		{
+			name:           "efivarfs backend with custom path",
+			backend:        constants.UEFIBackendEfivarfs,
+			efivarsPath:    "/tmp/efivars",
+			wantedBackend:  constants.UEFIBackendEfivarfs,
+			wantedEfivarfs: "/tmp/efivars",
+		},
+		{
+			name:        "Unknown backend - invalid",
+			backend:     "bios",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			if tt.backend != "" {
+				os.Setenv(constants.UEFIBackendEnv, tt.backend)
+			}
+			if tt.efivarsPath != "" {
+				os.Setenv(constants.EfivarsPathEnv, tt.efivarsPath)
+			}
+
+			cfg, err := LoadRegistrationServiceConfig()
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if cfg.UEFIBackend != tt.wantedBackend {
+				t.Errorf("Expected backend %s, got %s", tt.wantedBackend, cfg.UEFIBackend)
+			}
+			if cfg.EfivarsPath != tt.wantedEfivarfs {
+				t.Errorf("Expected efivars path %s, got %s", tt.wantedEfivarfs, cfg.EfivarsPath)
+			}
+		})
+	}
+}
+
+func TestLoadRegistrationServiceConfig_FollowUEFIRegistrationURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		follow      string
+		expectError bool
+		wanted      bool
+	}{
+		{
+			name:   "Defaults to warning only",
+			wanted: false,
+		},
+		{
+			name:   "Follow the UEFI registration URL",
+			follow: "true",
+			wanted: true,
+		},
+		{
+			name:        "Not a boolean - invalid",
+			follow:      "sometimes",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			if tt.follow != "" {
+				os.Setenv(constants.FollowUEFIRegistrationURLEnv, tt.follow)
+			}
+
+			cfg, err := LoadRegistrationServiceConfig()
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if cfg.FollowUEFIRegistrationURL != tt.wanted {
+				t.Errorf("Expected FollowUEFIRegistrationURL %v, got %v", tt.wanted, cfg.FollowUEFIRegistrationURL)
+			}
+		})
+	}
+}
+
+func TestLoadRegistrationServiceConfig_Proxy(t *testing.T) {
+	tests := []struct {
+		name         string
+		proxyMode    string
+		proxyURL     string
+		expectError  bool
+		wantedMode   string
+		wantedURLSet bool
+	}{
+		{
+			name:       "Defaults to the UEFI proxy configuration",
+			wantedMode: constants.ProxyModeUEFI,
+		},
+		{
+			name:         "Proxy URL implies the manual mode",
+			proxyURL:     "http://proxy.example.com:3128",
+			wantedMode:   constants.ProxyModeManual,
+			wantedURLSet: true,
+		},
+		{
+			name:       "Environment mode",
+			proxyMode:  "environment",
+			wantedMode: constants.ProxyModeEnvironment,
+		},
+		{
+			name:         "Direct mode takes precedence over the proxy URL",
+			proxyMode:    "direct",
+			proxyURL:     "http://proxy.example.com:3128",
+			wantedMode:   constants.ProxyModeDirect,
+			wantedURLSet: true,
+		},
+		{
+			name:        "Manual mode without URL - invalid",
+			proxyMode:   "manual",
+			expectError: true,
+		},
+		{
+			name:        "Unsupported proxy URL scheme - invalid",
+			proxyURL:    "ftp://proxy.example.com",
+			expectError: true,
+		},
+		{
+			name:        "Unknown mode - invalid",
+			proxyMode:   "auto",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			if tt.proxyMode != "" {
+				os.Setenv(constants.ProxyModeEnv, tt.proxyMode)
+			}
+			if tt.proxyURL != "" {
+				os.Setenv(constants.ProxyURLEnv, tt.proxyURL)
+			}
+
+			cfg, err := LoadRegistrationServiceConfig()
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if cfg.ProxyMode != tt.wantedMode {
+				t.Errorf("Expected ProxyMode %s, got %s", tt.wantedMode, cfg.ProxyMode)
+			}
+			if (cfg.ProxyURL != "") != tt.wantedURLSet {
+				t.Errorf("Expected ProxyURL set %v, got %q", tt.wantedURLSet, cfg.ProxyURL)
+			}
+		})
+	}
+}
+
+func TestLoadRegistrationServiceConfig_State(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		expectError  bool
+		wantedFile   string
+		wantedForced bool
+	}{
+		{
+			name:       "Defaults to the state file under /var/lib",
+			wantedFile: constants.DefaultStateFile,
+		},
+		{
+			name:       "Empty state file keeps the state in memory",
+			env:        map[string]string{constants.StateFileEnv: ""},
+			wantedFile: "",
+		},
+		{
+			name:         "Forced resubmission",
+			env:          map[string]string{constants.StateFileEnv: "/tmp/state.json", constants.ForceResubmitEnv: "true"},
+			wantedFile:   "/tmp/state.json",
+			wantedForced: true,
+		},
+		{
+			name:        "Force not a boolean - invalid",
+			env:         map[string]string{constants.ForceResubmitEnv: "always"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			for key, value := range tt.env {
+				os.Setenv(key, value)
+			}
+
+			cfg, err := LoadRegistrationServiceConfig()
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if cfg.StateFile != tt.wantedFile {
+				t.Errorf("Expected StateFile %q, got %q", tt.wantedFile, cfg.StateFile)
+			}
+			if cfg.ForceResubmit != tt.wantedForced {
+				t.Errorf("Expected ForceResubmit %v, got %v", tt.wantedForced, cfg.ForceResubmit)
+			}
+		})
+	}
+}
+
+func TestLoadRegistrationServiceConfig_PrefetchCollateral(t *testing.T) {
+	tests := []struct {
+		name           string
+		prefetch       string
+		expectError    bool
+		wantedPrefetch bool
+	}{
+		{
+			name:           "Disabled by default",
+			wantedPrefetch: false,
+		},
+		{
+			name:           "Enabled",
+			prefetch:       "true",
+			wantedPrefetch: true,
+		},
+		{
+			name:        "Not a boolean - invalid",
+			prefetch:    "yes please",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			if tt.prefetch != "" {
+				os.Setenv(constants.PCCSPrefetchCollateralEnv, tt.prefetch)
+			}
+
+			cfg, err := LoadRegistrationServiceConfig()
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if cfg.PrefetchCollateral != tt.wantedPrefetch {
+				t.Errorf("Expected PrefetchCollateral %v, got %v", tt.wantedPrefetch, cfg.PrefetchCollateral)
+			}
+		})
+	}
+}
+
+func TestLoadRegistrationServiceConfig_Credentials(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "api-key")
+	if err := os.WriteFile(keyPath, []byte("subscription-key\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write API key: %v", err)
+	}
+	emptyPath := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyPath, nil, 0o600); err != nil {
+		t.Fatalf("Failed to write empty file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		env         string
+		path        string
+		expectError bool
+		credential  func(*RegistrationServiceConfig) *secret.File
+	}{
+		{
+			name:       "Intel API key file",
+			env:        constants.IntelAPIKeyFileEnv,
+			path:       keyPath,
+			credential: func(cfg *RegistrationServiceConfig) *secret.File { return cfg.IntelAPIKey },
+		},
+		{
+			name:       "PCCS user token file",
+			env:        constants.PCCSUserTokenFileEnv,
+			path:       keyPath,
+			credential: func(cfg *RegistrationServiceConfig) *secret.File { return cfg.PCCSUserToken },
+		},
+		{
+			name:       "PCCS admin token file",
+			env:        constants.PCCSAdminTokenFileEnv,
+			path:       keyPath,
+			credential: func(cfg *RegistrationServiceConfig) *secret.File { return cfg.PCCSAdminToken },
+		},
+		{
+			name:        "Missing file - invalid",
+			env:         constants.IntelAPIKeyFileEnv,
+			path:        filepath.Join(dir, "missing"),
+			expectError: true,
+		},
+		{
+			name:        "Empty file - invalid",
+			env:         constants.PCCSAdminTokenFileEnv,
+			path:        emptyPath,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			os.Setenv(tt.env, tt.path)
+
+			cfg, err := LoadRegistrationServiceConfig()
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			credential := tt.credential(cfg)
+			if credential == nil {
+				t.Fatalf("Expected credential to be loaded")
+			}
+			if value, err := credential.Value(); err != nil || value != "subscription-key" {
+				t.Errorf("Expected credential 'subscription-key', got '%s' (%v)", value, err)
+			}
+			if printed := fmt.Sprintf("%+v", *cfg); strings.Contains(printed, "subscription-key") {
+				t.Errorf("Expected credential to be redacted, got %s", printed)
+			}
+		})
+	}
+
+	t.Run("No credentials by default", func(t *testing.T) {
+		os.Clearenv()
+		cfg, err := LoadRegistrationServiceConfig()
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if cfg.IntelAPIKey != nil || cfg.PCCSUserToken != nil || cfg.PCCSAdminToken != nil {
+			t.Errorf("Expected no credentials, got %+v", *cfg)
+		}
+	})
+}
+
+func TestLoadRegistrationServiceConfig_PCCSAddPlatform(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "user-token")
+	if err := os.WriteFile(tokenPath, []byte("user-token"), 0o600); err != nil {
+		t.Fatalf("Failed to write user token: %v", err)
+	}
+
+	tests := []struct {
+		name              string
+		addPlatform       string
+		userTokenFile     string
+		expectError       bool
+		wantedAddPlatform bool
+	}{
+		{
+			name:              "Disabled by default",
+			wantedAddPlatform: false,
+		},
+		{
+			name:              "Enabled with user token",
+			addPlatform:       "true",
+			userTokenFile:     tokenPath,
+			wantedAddPlatform: true,
+		},
+		{
+			name:        "Enabled without user token - invalid",
+			addPlatform: "true",
+			expectError: true,
+		},
+		{
+			name:          "Not a boolean - invalid",
+			addPlatform:   "sure",
+			userTokenFile: tokenPath,
+			expectError:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			if tt.addPlatform != "" {
+				os.Setenv(constants.PCCSAddPlatformEnv, tt.addPlatform)
+			}
+			if tt.userTokenFile != "" {
+				os.Setenv(constants.PCCSUserTokenFileEnv, tt.userTokenFile)
+			}
+
+			cfg, err := LoadRegistrationServiceConfig()
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if cfg.PCCSAddPlatform != tt.wantedAddPlatform {
+				t.Errorf("Expected PCCSAddPlatform %v, got %v", tt.wantedAddPlatform, cfg.PCCSAddPlatform)
+			}
+		})
+	}
+}
+
+func TestLoadRegistrationServiceConfig_PCCSUploadPCKCerts(t *testing.T) {
+	secretPath := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretPath, []byte("secret"), 0o600); err != nil {
+		t.Fatalf("Failed to write secret: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		upload       string
+		adminToken   string
+		apiKey       string
+		expectError  bool
+		wantedUpload bool
+	}{
+		{
+			name:         "Disabled by default",
+			wantedUpload: false,
+		},
+		{
+			name:         "Enabled with admin token and API key",
+			upload:       "true",
+			adminToken:   secretPath,
+			apiKey:       secretPath,
+			wantedUpload: true,
+		},
+		{
+			name:        "Enabled without admin token - invalid",
+			upload:      "true",
+			apiKey:      secretPath,
+			expectError: true,
+		},
+		{
+			name:        "Enabled without API key - invalid",
+			upload:      "true",
+			adminToken:  secretPath,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			if tt.upload != "" {
+				os.Setenv(constants.PCCSUploadPCKCertsEnv, tt.upload)
+			}
+			if tt.adminToken != "" {
+				os.Setenv(constants.PCCSAdminTokenFileEnv, tt.adminToken)
+			}
+			if tt.apiKey != "" {
+				os.Setenv(constants.IntelAPIKeyFileEnv, tt.apiKey)
+			}
+
+			cfg, err := LoadRegistrationServiceConfig()
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if cfg.PCCSUploadPCKCerts != tt.wantedUpload {
+				t.Errorf("Expected PCCSUploadPCKCerts %v, got %v", tt.wantedUpload, cfg.PCCSUploadPCKCerts)
+			}
+		})
+	}
+}
+
+func TestLoadRegistrationServiceConfig_PCCSConsistency(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "admin-token")
+	if err := os.WriteFile(tokenPath, []byte("admin-token"), 0o600); err != nil {
+		t.Fatalf("Failed to write admin token: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		interval       string
+		refresh        string
+		adminToken     string
+		expectError    bool
+		wantedInterval time.Duration
+		wantedRefresh  bool
+	}{
+		{
+			name:           "Disabled by default",
+			wantedInterval: 0,
+		},
+		{
+			name:           "Interval in minutes",
+			interval:       "360",
+			wantedInterval: 6 * time.Hour,
+		},
+		{
+			name:           "Refresh with admin token",
+			interval:       "60",
+			refresh:        "true",
+			adminToken:     tokenPath,
+			wantedInterval: time.Hour,
+			wantedRefresh:  true,
+		},
+		{
+			name:        "Refresh without admin token - invalid",
+			interval:    "60",
+			refresh:     "true",
+			expectError: true,
+		},
+		{
+			name:        "Negative interval - invalid",
+			interval:    "-1",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			if tt.interval != "" {
+				os.Setenv(constants.PCCSConsistencyIntervalEnv, tt.interval)
+			}
+			if tt.refresh != "" {
+				os.Setenv(constants.PCCSConsistencyRefreshEnv, tt.refresh)
+			}
+			if tt.adminToken != "" {
+				os.Setenv(constants.PCCSAdminTokenFileEnv, tt.adminToken)
+			}
+
+			cfg, err := LoadRegistrationServiceConfig()
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if cfg.PCCSConsistencyInterval != tt.wantedInterval {
+				t.Errorf("Expected PCCSConsistencyInterval %v, got %v", tt.wantedInterval, cfg.PCCSConsistencyInterval)
+			}
+			if cfg.PCCSConsistencyRefresh != tt.wantedRefresh {
+				t.Errorf("Expected PCCSConsistencyRefresh %v, got %v", tt.wantedRefresh, cfg.PCCSConsistencyRefresh)
+			}
+		})
+	}
+}
+
+func TestLoadRegistrationServiceConfig_Environment(t *testing.T) {
+	tests := []struct {
+		name                    string
+		env                     map[string]string
+		expectError             bool
+		expectedEnvironment     string
+		expectedRegistrationURL string
+		expectedPCKRetrievalURL string
+	}{
+		{
+			name:                    "Default - production",
+			expectedEnvironment:     constants.EnvironmentProduction,
+			expectedRegistrationURL: "https://api.trustedservices.intel.com/sgx/registration/v1/platform",
+			expectedPCKRetrievalURL: "https://api.trustedservices.intel.com/sgx/certification/v4/pckcert",
+		},
+		{
+			name:                    "Sandbox",
+			env:                     map[string]string{constants.EnvironmentEnv: constants.EnvironmentSandbox},
+			expectedEnvironment:     constants.EnvironmentSandbox,
+			expectedRegistrationURL: "https://sbx.api.trustedservices.intel.com/sgx/registration/v1/platform",
+			expectedPCKRetrievalURL: "https://sbx.api.trustedservices.intel.com/sgx/certification/v4/pckcert",
+		},
+		{
+			name: "Sandbox with PCS API v4",
+			env: map[string]string{
+				constants.EnvironmentEnv:   constants.EnvironmentSandbox,
+				constants.PCSAPIVersionEnv: constants.PCSAPIVersionV4,
+			},
+			expectedEnvironment:     constants.EnvironmentSandbox,
+			expectedRegistrationURL: "https://sbx.api.trustedservices.intel.com/sgx/registration/v1/platform",
+			expectedPCKRetrievalURL: "https://sbx.api.trustedservices.intel.com/sgx/certification/v4/pckcert",
+		},
+		{
+			name: "Custom",
+			env: map[string]string{
+				constants.EnvironmentEnv:         constants.EnvironmentCustom,
+				constants.RegistrationBaseURLEnv: "https://registration.example.com/",
+				constants.PCSBaseURLEnv:          "https://pcs.example.com",
+			},
+			expectedEnvironment:     constants.EnvironmentCustom,
+			expectedRegistrationURL: "https://registration.example.com/sgx/registration/v1/platform",
+			expectedPCKRetrievalURL: "https://pcs.example.com/sgx/certification/v4/pckcert",
+		},
+		{
+			name: "Custom without PCS base URL - invalid",
+			env: map[string]string{
+				constants.EnvironmentEnv:         constants.EnvironmentCustom,
+				constants.RegistrationBaseURLEnv: "https://registration.example.com",
+			},
+			expectError: true,
+		},
+		{
+			name: "Custom with HTTP base URL - invalid",
+			env: map[string]string{
+				constants.EnvironmentEnv:         constants.EnvironmentCustom,
+				constants.RegistrationBaseURLEnv: "http://registration.example.com",
+				constants.PCSBaseURLEnv:          "https://pcs.example.com",
+			},
+			expectError: true,
+		},
+		{
+			name: "Custom pointing to production - invalid",
+			env: map[string]string{
+				constants.EnvironmentEnv:         constants.EnvironmentCustom,
+				constants.RegistrationBaseURLEnv: "https://API.trustedservices.intel.com:443",
+				constants.PCSBaseURLEnv:          "https://pcs.example.com",
+			},
+			expectError: true,
+		},
+		{
+			name:        "Base URL outside of custom - invalid",
+			env:         map[string]string{constants.RegistrationBaseURLEnv: "https://registration.example.com"},
+			expectError: true,
+		},
+		{
+			name:        "Unknown environment - invalid",
+			env:         map[string]string{constants.EnvironmentEnv: "staging"},
+			expectError: true,
+		},
+		{
+			name:        "PCS API v3 - invalid",
+			env:         map[string]string{constants.PCSAPIVersionEnv: "v3"},
+			expectError: true,
+		},
+		{
+			name:        "Unknown PCS API version - invalid",
+			env:         map[string]string{constants.PCSAPIVersionEnv: "v5"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			for key, value := range tt.env {
+				os.Setenv(key, value)
+			}
+
+			cfg, err := LoadRegistrationServiceConfig()
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if cfg.Environment != tt.expectedEnvironment {
+				t.Errorf("Expected environment '%s', got '%s'", tt.expectedEnvironment, cfg.Environment)
+			}
+			if cfg.IntelRegistrationURL != tt.expectedRegistrationURL {
+				t.Errorf("Expected registration URL '%s', got '%s'", tt.expectedRegistrationURL, cfg.IntelRegistrationURL)
+			}
+			if cfg.IntelPCKRetrievalURL != tt.expectedPCKRetrievalURL {
+				t.Errorf("Expected PCK retrieval URL '%s', got '%s'", tt.expectedPCKRetrievalURL, cfg.IntelPCKRetrievalURL)
+			}
+			if cfg.IntelRootCACRLURL != constants.IntelSGXRootCACRLURL {
+				t.Errorf("Expected root CA CRL URL '%s', got '%s'", constants.IntelSGXRootCACRLURL, cfg.IntelRootCACRLURL)
+			}
+		})
+	}
+}
+
+func TestLoadRegistrationServiceConfig_CheckTimeout(t *testing.T) {
+	tests := []struct {
+		name            string
+		timeoutEnv      string
+		expectError     bool
+		expectedTimeout time.Duration
+	}{
+		{
+			name:            "Default",
+			expectedTimeout: constants.DefaultCheckTimeout,
+		},
+		{
+			name:            "Custom timeout",
+			timeoutEnv:      "3",
+			expectedTimeout: 3 * time.Minute,
+		},
+		{
+			name:            "Disabled",
+			timeoutEnv:      "0",
+			expectedTimeout: 0,
+		},
+		{
+			name:        "Negative - invalid",
+			timeoutEnv:  "-1",
+			expectError: true,
+		},
+		{
+			name:        "Not a number - invalid",
+			timeoutEnv:  "soon",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			if tt.timeoutEnv != "" {
+				os.Setenv(constants.CheckTimeoutEnv, tt.timeoutEnv)
+			}
+
+			cfg, err := LoadRegistrationServiceConfig()
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if cfg.CheckTimeout != tt.expectedTimeout {
+				t.Errorf("Expected check timeout %s, got %s", tt.expectedTimeout, cfg.CheckTimeout)
+			}
+		})
+	}
+}
+
+func TestLoadRegistrationServiceConfig_Retry(t *testing.T) {
+	tests := []struct {
+		name                   string
+		env                    map[string]string
+		expectError            bool
+		expectedMaxAttempts    int
+		expectedInitialBackoff time.Duration
+		expectedMaxBackoff     time.Duration
+	}{
+		{
+			name:                   "Default",
+			expectedMaxAttempts:    constants.DefaultRetryMaxAttempts,
+			expectedInitialBackoff: constants.DefaultRetryInitialBackoff,
+			expectedMaxBackoff:     constants.DefaultRetryMaxBackoff,
+		},
+		{
+			name: "Custom policy",
+			env: map[string]string{
+				constants.RetryMaxAttemptsEnv:    "5",
+				constants.RetryInitialBackoffEnv: "2",
+				constants.RetryMaxBackoffEnv:     "60",
+			},
+			expectedMaxAttempts:    5,
+			expectedInitialBackoff: 2 * time.Second,
+			expectedMaxBackoff:     time.Minute,
+		},
+		{
+			name:                   "Retries disabled",
+			env:                    map[string]string{constants.RetryMaxAttemptsEnv: "1"},
+			expectedMaxAttempts:    1,
+			expectedInitialBackoff: constants.DefaultRetryInitialBackoff,
+			expectedMaxBackoff:     constants.DefaultRetryMaxBackoff,
+		},
+		{
+			name:        "No attempt - invalid",
+			env:         map[string]string{constants.RetryMaxAttemptsEnv: "0"},
+			expectError: true,
+		},
+		{
+			name:        "Zero backoff - invalid",
+			env:         map[string]string{constants.RetryInitialBackoffEnv: "0"},
+			expectError: true,
+		},
+		{
+			name:        "Maximum backoff lower than the initial one - invalid",
+			env:         map[string]string{constants.RetryInitialBackoffEnv: "10", constants.RetryMaxBackoffEnv: "5"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			for key, value := range tt.env {
+				os.Setenv(key, value)
+			}
+
+			cfg, err := LoadRegistrationServiceConfig()
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if cfg.RetryMaxAttempts != tt.expectedMaxAttempts {
+				t.Errorf("Expected %d attempts, got %d", tt.expectedMaxAttempts, cfg.RetryMaxAttempts)
+			}
+			if cfg.RetryInitialBackoff != tt.expectedInitialBackoff {
+				t.Errorf("Expected initial backoff %s, got %s", tt.expectedInitialBackoff, cfg.RetryInitialBackoff)
+			}
+			if cfg.RetryMaxBackoff != tt.expectedMaxBackoff {
+				t.Errorf("Expected maximum backoff %s, got %s", tt.expectedMaxBackoff, cfg.RetryMaxBackoff)
+			}
+		})
+	}
+}
+
+func TestLoadRegistrationServiceConfig_PCCSSelection(t *testing.T) {
+	tests := []struct {
+		name                    string
+		env                     map[string]string
+		expectError             bool
+		expectedBreakerFailures int
+		expectedCoolDown        time.Duration
+		expectedOrder           string
+		expectedWeights         []int
+		expectedHedgePCK        bool
+		expectedHedgeDelay      time.Duration
+	}{
+		{
+			name:                    "Default",
+			expectedBreakerFailures: constants.DefaultPCCSBreakerFailures,
+			expectedCoolDown:        constants.DefaultPCCSBreakerCoolDown,
+			expectedOrder:           constants.PCCSOrderConfig,
+		},
+		{
+			name: "Custom breaker",
+			env: map[string]string{
+				constants.PCCSBreakerFailuresEnv: "5",
+				constants.PCCSBreakerCoolDownEnv: "30",
+			},
+			expectedBreakerFailures: 5,
+			expectedCoolDown:        30 * time.Minute,
+			expectedOrder:           constants.PCCSOrderConfig,
+		},
+		{
+			name:                    "Breaker disabled",
+			env:                     map[string]string{constants.PCCSBreakerFailuresEnv: "0"},
+			expectedBreakerFailures: 0,
+			expectedCoolDown:        constants.DefaultPCCSBreakerCoolDown,
+			expectedOrder:           constants.PCCSOrderConfig,
+		},
+		{
+			name:                    "Sticky order",
+			env:                     map[string]string{constants.PCCSOrderEnv: constants.PCCSOrderSticky},
+			expectedBreakerFailures: constants.DefaultPCCSBreakerFailures,
+			expectedCoolDown:        constants.DefaultPCCSBreakerCoolDown,
+			expectedOrder:           constants.PCCSOrderSticky,
+		},
+		{
+			name: "Weighted order",
+			env: map[string]string{
+				constants.PCCSURLsEnv:    "https://pccs1.example.com,https://pccs2.example.com",
+				constants.PCCSOrderEnv:   constants.PCCSOrderWeighted,
+				constants.PCCSWeightsEnv: "3, 1",
+			},
+			expectedBreakerFailures: constants.DefaultPCCSBreakerFailures,
+			expectedCoolDown:        constants.DefaultPCCSBreakerCoolDown,
+			expectedOrder:           constants.PCCSOrderWeighted,
+			expectedWeights:         []int{3, 1},
+		},
+		{
+			name:                    "Hedged PCK requests",
+			env:                     map[string]string{constants.PCCSHedgePCKEnv: "true"},
+			expectedBreakerFailures: constants.DefaultPCCSBreakerFailures,
+			expectedCoolDown:        constants.DefaultPCCSBreakerCoolDown,
+			expectedOrder:           constants.PCCSOrderConfig,
+			expectedHedgePCK:        true,
+			expectedHedgeDelay:      constants.DefaultPCCSHedgeDelay,
+		},
+		{
+			name:                    "Hedged PCK requests all at once",
+			env:                     map[string]string{constants.PCCSHedgePCKEnv: "true", constants.PCCSHedgeDelayEnv: "0"},
+			expectedBreakerFailures: constants.DefaultPCCSBreakerFailures,
+			expectedCoolDown:        constants.DefaultPCCSBreakerCoolDown,
+			expectedOrder:           constants.PCCSOrderConfig,
+			expectedHedgePCK:        true,
+			expectedHedgeDelay:      0,
+		},
+		{
+			name:        "Invalid hedging flag - invalid",
+			env:         map[string]string{constants.PCCSHedgePCKEnv: "sometimes"},
+			expectError: true,
+		},
+		{
+			name:        "Hedge delay without hedging - invalid",
+			env:         map[string]string{constants.PCCSHedgeDelayEnv: "100"},
+			expectError: true,
+		},
+		{
+			name:        "Negative hedge delay - invalid",
+			env:         map[string]string{constants.PCCSHedgePCKEnv: "true", constants.PCCSHedgeDelayEnv: "-1"},
+			expectError: true,
+		},
+		{
+			name:        "Negative breaker failures - invalid",
+			env:         map[string]string{constants.PCCSBreakerFailuresEnv: "-1"},
+			expectError: true,
+		},
+		{
+			name:        "Zero cool-down - invalid",
+			env:         map[string]string{constants.PCCSBreakerCoolDownEnv: "0"},
+			expectError: true,
+		},
+		{
+			name:        "Unknown order - invalid",
+			env:         map[string]string{constants.PCCSOrderEnv: "fastest"},
+			expectError: true,
+		},
+		{
+			name:        "Weighted order without weights - invalid",
+			env:         map[string]string{constants.PCCSOrderEnv: constants.PCCSOrderWeighted},
+			expectError: true,
+		},
+		{
+			name: "Weights without weighted order - invalid",
+			env: map[string]string{
+				constants.PCCSURLsEnv:    "https://pccs1.example.com",
+				constants.PCCSWeightsEnv: "1",
+			},
+			expectError: true,
+		},
+		{
+			name: "Weight count not matching the PCCS URLs - invalid",
+			env: map[string]string{
+				constants.PCCSURLsEnv:    "https://pccs1.example.com,https://pccs2.example.com",
+				constants.PCCSOrderEnv:   constants.PCCSOrderWeighted,
+				constants.PCCSWeightsEnv: "1",
+			},
+			expectError: true,
+		},
+		{
+			name: "Zero weight - invalid",
+			env: map[string]string{
+				constants.PCCSURLsEnv:    "https://pccs1.example.com",
+				constants.PCCSOrderEnv:   constants.PCCSOrderWeighted,
+				constants.PCCSWeightsEnv: "0",
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			for key, value := range tt.env {
+				os.Setenv(key, value)
+			}
+
+			cfg, err := LoadRegistrationServiceConfig()
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if cfg.PCCSBreakerFailures != tt.expectedBreakerFailures {
+				t.Errorf("Expected %d breaker failures, got %d", tt.expectedBreakerFailures, cfg.PCCSBreakerFailures)
+			}
+			if cfg.PCCSBreakerCoolDown != tt.expectedCoolDown {
+				t.Errorf("Expected cool-down %s, got %s", tt.expectedCoolDown, cfg.PCCSBreakerCoolDown)
+			}
+			if cfg.PCCSOrder != tt.expectedOrder {
+				t.Errorf("Expected order '%s', got '%s'", tt.expectedOrder, cfg.PCCSOrder)
+			}
+			if !slices.Equal(cfg.PCCSWeights, tt.expectedWeights) {
+				t.Errorf("Expected weights %v, got %v", tt.expectedWeights, cfg.PCCSWeights)
+			}
+			if cfg.PCCSHedgePCK != tt.expectedHedgePCK {
+				t.Errorf("Expected hedging %v, got %v", tt.expectedHedgePCK, cfg.PCCSHedgePCK)
+			}
+			if tt.expectedHedgePCK && cfg.PCCSHedgeDelay != tt.expectedHedgeDelay {
+				t.Errorf("Expected hedge delay %s, got %s", tt.expectedHedgeDelay, cfg.PCCSHedgeDelay)
+			}
+		})
+	}
+}
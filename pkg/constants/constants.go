@@ -10,9 +10,84 @@ const RegistrationServicePortEnv = "CC_IPR_REGISTRATION_SERVICE_PORT"
 
 // PCCS configuration
 const PCCSURLsEnv = "CC_PCCS_URLS"
-const PCCSCACertPathEnv = "CC_PCCS_CA_CERT_PATH" // Directory path for custom CA certificates
+const PCCSCACertPathEnv = "CC_PCCS_CA_CERT_PATH"                          // Directory path for custom CA certificates
+const PCCSPrefetchCollateralEnv = "CC_PCCS_PREFETCH_COLLATERAL"           // Request the attestation collateral of the platform from each PCCS to warm their caches
+const PCCSAddPlatformEnv = "CC_PCCS_ADD_PLATFORM"                         // POST the registered platform to each PCCS, e.g. when they run in REQ mode
+const PCCSUploadPCKCertsEnv = "CC_PCCS_UPLOAD_PCK_CERTS"                  // PUT the PCK certificates retrieved from Intel to each PCCS, e.g. when they run in OFFLINE mode
+const PCCSConsistencyIntervalEnv = "CC_PCCS_CONSISTENCY_INTERVAL_MINUTES" // Compare the PCK certificate of each PCCS with Intel's at this interval, disabled when 0
+const PCCSConsistencyRefreshEnv = "CC_PCCS_CONSISTENCY_REFRESH"           // Ask a diverging PCCS to refresh its PCK certificates cache
+const PCCSUserTokenFileEnv = "CC_PCCS_USER_TOKEN_FILE"                    // File with the user token of the PCCS user endpoints, e.g. a mounted Secret key
+const PCCSAdminTokenFileEnv = "CC_PCCS_ADMIN_TOKEN_FILE"                  // File with the admin token of the PCCS admin endpoints, e.g. a mounted Secret key
+
+// PCCS health tracking and selection
+const PCCSBreakerFailuresEnv = "CC_PCCS_BREAKER_FAILURES"         // Consecutive failures after which a PCCS is skipped, disabled when 0
+const PCCSBreakerCoolDownEnv = "CC_PCCS_BREAKER_COOLDOWN_MINUTES" // Time a failing PCCS is skipped before it is tried again
+const PCCSOrderEnv = "CC_PCCS_ORDER"                              // "config" (default), "sticky", "weighted" or "random"
+const PCCSWeightsEnv = "CC_PCCS_WEIGHTS"                          // Comma-separated weights of the PCCS URLs of the "weighted" order
+const PCCSHedgePCKEnv = "CC_PCCS_HEDGE_PCK"                       // Request the PCK certificate from several PCCS at once and keep the first valid one
+const PCCSHedgeDelayEnv = "CC_PCCS_HEDGE_DELAY_MILLISECONDS"      // Delay before the next PCCS is requested, all at once when 0
+const DefaultPCCSBreakerFailures = 3
+const DefaultPCCSBreakerCoolDown = 15 * time.Minute
+const DefaultPCCSHedgeDelay = 500 * time.Millisecond
+const PCCSOrderConfig = "config"     // Order of CC_PCCS_URLS
+const PCCSOrderSticky = "sticky"     // PCCS that answered last first, then the order of CC_PCCS_URLS
+const PCCSOrderWeighted = "weighted" // Random order weighted by CC_PCCS_WEIGHTS
+const PCCSOrderRandom = "random"     // Uniformly random order
+
+// UEFI configuration
+const UEFIBackendEnv = "CC_IPR_UEFI_BACKEND" // "mp_management" (default, cgo library, requires the sgx build tag) or "efivarfs" (native Go)
+const UEFIBackendEfivarfs = "efivarfs"
+const UEFIBackendMPManagement = "mp_management"
+const EfivarsPathEnv = "CC_IPR_EFIVARS_PATH"
+const DefaultEfivarsPath = "/sys/firmware/efi/efivars"
+const FollowUEFIRegistrationURLEnv = "CC_IPR_FOLLOW_UEFI_REGISTRATION_URL" // Use the registration server of SgxRegistrationConfiguration instead of warning about a mismatch
+
+// Proxy configuration
+const ProxyModeEnv = "CC_IPR_PROXY_MODE" // "uefi" (default), "environment", "direct" or "manual"
+const ProxyURLEnv = "CC_IPR_PROXY_URL"   // Proxy URL of the "manual" mode, implies it when the mode is not set
+const ProxyModeUEFI = "uefi"             // ProxyConf of SgxRegistrationConfiguration, then the proxy environment variables
+const ProxyModeEnvironment = "environment"
+const ProxyModeDirect = "direct"
+const ProxyModeManual = "manual"
+
+// PCK certificate verification
+const SGXRootCAPathEnv = "CC_IPR_SGX_ROOT_CA_PATH" // PEM root CA overriding the embedded Intel SGX Root CA
+
+// Registration state
+const StateFileEnv = "CC_IPR_STATE_FILE" // Hashes of the requests accepted by Intel, to never send a request twice
+const DefaultStateFile = "/var/lib/cc-intel-platform-registration/state.json"
+const ForceResubmitEnv = "CC_IPR_FORCE_RESUBMIT" // Send the pending request again although it was already accepted by Intel
+
+// Intel PCS API subscription
+const IntelAPIKeyFileEnv = "CC_IPR_INTEL_API_KEY_FILE" // File with the Ocp-Apim-Subscription-Key of the Intel PCS, e.g. a mounted Secret key; /pckcerts is only requested when it is set
+
+// Intel environments, setting the registration and PCS endpoints together
+const EnvironmentEnv = "CC_IPR_ENVIRONMENT" // "production" (default), "sandbox" or "custom"
+const EnvironmentProduction = "production"
+const EnvironmentSandbox = "sandbox"                          // Intel sandbox, e.g. for pre-production platforms
+const EnvironmentCustom = "custom"                            // Base URLs of CC_IPR_REGISTRATION_BASE_URL and CC_IPR_PCS_BASE_URL
+const RegistrationBaseURLEnv = "CC_IPR_REGISTRATION_BASE_URL" // Registration service base URL of the "custom" environment
+const PCSBaseURLEnv = "CC_IPR_PCS_BASE_URL"                   // PCS base URL of the "custom" environment
+const PCSAPIVersionEnv = "CC_IPR_PCS_API_VERSION"             // "v4", the only supported version, also used for the PCCS endpoints
+const PCSAPIVersionV4 = "v4"
 
 // Intel endpoint constants (used as fallback)
-const IntelPlatformRegistrationEndpoint = "https://api.trustedservices.intel.com/sgx/registration/v1/platform"
-const IntelPckRetrievalEndpoint = "https://api.trustedservices.intel.com/sgx/certification/v4/pckcert"
+const IntelProductionBaseURL = "https://api.trustedservices.intel.com"
+const IntelSandboxBaseURL = "https://sbx.api.trustedservices.intel.com"
+const IntelSGXRootCACRLURL = "https://certificates.trustedservices.intel.com/IntelSGXRootCA.der" // CRL of the Intel SGX Root CA, shared by all the environments
+const IntelPlatformRegistrationPath = "/sgx/registration/v1/platform"
+const IntelAddPackagePath = "/sgx/registration/v1/package"
+const PCSCertificationPath = "/sgx/certification/" // Followed by the PCS API version and the resource, e.g. /sgx/certification/v4/pckcert
 const IntelRequestTimeout = 2 * time.Minute
+
+// Registration check deadline
+const CheckTimeoutEnv = "CC_IPR_CHECK_TIMEOUT_MINUTES" // Deadline of each registration check, disabled when 0
+const DefaultCheckTimeout = 10 * time.Minute
+
+// Retry of the requests to Intel and the PCCS
+const RetryMaxAttemptsEnv = "CC_IPR_RETRY_MAX_ATTEMPTS"               // Attempts of each request, including the first one; 1 disables the retries
+const RetryInitialBackoffEnv = "CC_IPR_RETRY_INITIAL_BACKOFF_SECONDS" // Backoff before the first retry, doubled on each further retry
+const RetryMaxBackoffEnv = "CC_IPR_RETRY_MAX_BACKOFF_SECONDS"         // Upper bound of the backoff and of the Retry-After delay waited for
+const DefaultRetryMaxAttempts = 3
+const DefaultRetryInitialBackoff = time.Second
+const DefaultRetryMaxBackoff = 30 * time.Second
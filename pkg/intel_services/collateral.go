@@ -0,0 +1,82 @@
+package intelservices
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"go.uber.org/zap"
+)
+
+// Attestation collateral prefetched from the PCCS
+const (
+	CollateralTCBInfo     = "tcb_info"
+	CollateralQEIdentity  = "qe_identity"
+	CollateralQvEIdentity = "qve_identity"
+	CollateralPCKCRL      = "pck_crl"
+	CollateralRootCACRL   = "root_ca_crl"
+)
+
+// CollateralPrefetchResult is the outcome of a collateral request to a PCCS
+type CollateralPrefetchResult struct {
+	PCCSURL    string
+	Collateral string
+	Err        error
+}
+
+// collateralPaths returns the PCCS paths of the collateral needed to verify the quotes of a platform
+func collateralPaths(certificationPath, fmspc, caType string) []struct{ collateral, path string } {
+	return []struct{ collateral, path string }{
+		{CollateralTCBInfo, certificationPath + "/tcb?fmspc=" + url.QueryEscape(fmspc)},
+		{CollateralQEIdentity, certificationPath + "/qe/identity"},
+		{CollateralQvEIdentity, certificationPath + "/qve/identity"},
+		{CollateralPCKCRL, certificationPath + "/pckcrl?ca=" + url.QueryEscape(caType)},
+		{CollateralRootCACRL, certificationPath + "/rootcacrl"},
+	}
+}
+
+// PrefetchCollateral requests the attestation collateral of the platform from each PCCS, so that a PCCS
+// in LAZY mode fetches it from Intel and caches it before the first quote verification.
+// The responses are discarded; only their status matters.
+func (r *IntelService) PrefetchCollateral(ctx context.Context, fmspc, caType string) []CollateralPrefetchResult {
+	var results []CollateralPrefetchResult
+	for _, baseURL := range r.endpoints.pccsURLs {
+		for _, c := range collateralPaths(r.endpoints.certificationPath, fmspc, caType) {
+			if ctx.Err() != nil {
+				return results
+			}
+			err := r.prefetchFromEndpoint(ctx, baseURL+c.path)
+			if err != nil {
+				r.log.Warn("Collateral prefetch failed",
+					zap.String("url", baseURL),
+					zap.String("collateral", c.collateral),
+					zap.Error(err))
+			}
+			results = append(results, CollateralPrefetchResult{PCCSURL: baseURL, Collateral: c.collateral, Err: err})
+		}
+	}
+	return results
+}
+
+// prefetchFromEndpoint requests a collateral from a single endpoint
+func (r *IntelService) prefetchFromEndpoint(ctx context.Context, requestURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := r.do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// drain the body to reuse the connection for the next collateral
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxCRLResponseSize))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d (Error-Code: %s)", resp.StatusCode, resp.Header.Get("Error-Code"))
+	}
+	return nil
+}
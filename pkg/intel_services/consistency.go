@@ -0,0 +1,136 @@
+package intelservices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	pckcert "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/pck_cert"
+	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// ErrPCKCertificateDiverges is returned when a PCCS serves another PCK certificate than Intel
+var ErrPCKCertificateDiverges = errors.New("PCCS PCK certificate diverges from Intel")
+
+// PCKIdentity identifies a PCK certificate by its TCB level and serial number
+type PCKIdentity struct {
+	TCBm   string
+	Serial string
+}
+
+func newPCKIdentity(cert *pckcert.Certificate) PCKIdentity {
+	return PCKIdentity{TCBm: cert.TCBm, Serial: cert.Leaf.SerialNumber.Text(16)}
+}
+
+// ConsistencyResult compares the PCK certificate a PCCS serves with the one of Intel
+type ConsistencyResult struct {
+	PCCSURL string
+	// PCCS is the certificate served by the PCCS, empty when it served none
+	PCCS  PCKIdentity
+	Intel PCKIdentity
+	// Err is ErrPCKCertificateDiverges when the certificates differ, or the error of the PCCS request
+	Err error
+	// Refreshed reports whether the PCCS was asked to refresh its PCK certificates cache
+	Refreshed bool
+}
+
+// CheckPCCSConsistency requests the PCK certificate of the platform from Intel and from each PCCS and
+// compares their TCBm and serial number, as a PCCS serving a certificate of an outdated TCB level would
+// otherwise look healthy. With refresh, a diverging PCCS is asked to refresh the PCK certificates of the
+// platform FMSPC with the PCCS admin token. No result is returned when Intel cannot be queried.
+func (r *IntelService) CheckPCCSConsistency(ctx context.Context, platformInfo *sgxplatforminfo.SgxPlatformInfo, refresh bool) ([]ConsistencyResult, error) {
+	platform, err := queriedPlatform(platformInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	intelURL := r.endpoints.pckRetrievalURLs[len(r.endpoints.pckRetrievalURLs)-1]
+	metric, intelCert, err := r.retrievePCKFromEndpoint(ctx, pckRequestURL(intelURL, platformInfo, false), intelPCKEndpoint, platform)
+	if err == nil && intelCert == nil {
+		err = pckStatusError(metric)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve the PCK certificate from Intel: %w", err)
+	}
+	intel := newPCKIdentity(intelCert)
+
+	var results []ConsistencyResult
+	for i, baseURL := range r.endpoints.pccsURLs {
+		if ctx.Err() != nil {
+			break
+		}
+		result := ConsistencyResult{PCCSURL: baseURL, Intel: intel}
+		requestURL := pckRequestURL(r.endpoints.pckRetrievalURLs[i], platformInfo, true)
+		metric, cert, err := r.retrievePCKFromEndpoint(ctx, requestURL, pccsEndpoint, platform)
+		switch {
+		case err != nil:
+			result.Err = err
+		case cert == nil:
+			result.Err = pckStatusError(metric)
+		default:
+			result.PCCS = newPCKIdentity(cert)
+			if result.PCCS != intel {
+				result.Err = ErrPCKCertificateDiverges
+			}
+		}
+
+		if result.Err != nil {
+			r.log.Warn("PCCS PCK certificate is not consistent with Intel",
+				zap.String("url", baseURL),
+				zap.String("pccsTcbm", result.PCCS.TCBm),
+				zap.String("pccsSerial", result.PCCS.Serial),
+				zap.String("intelTcbm", intel.TCBm),
+				zap.String("intelSerial", intel.Serial),
+				zap.Error(result.Err))
+		}
+		if errors.Is(result.Err, ErrPCKCertificateDiverges) && refresh {
+			if err := r.refreshPCKCerts(ctx, r.endpoints.refreshURLs[i], intelCert.FMSPC); err != nil {
+				r.log.Warn("PCCS refresh failed",
+					zap.String("url", baseURL),
+					zap.Error(err))
+			} else {
+				r.log.Info("PCCS asked to refresh its PCK certificates",
+					zap.String("url", baseURL),
+					zap.String("fmspc", intelCert.FMSPC))
+				result.Refreshed = true
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// refreshPCKCerts asks a PCCS to fetch the PCK certificates of the platforms of the FMSPC from Intel again
+func (r *IntelService) refreshPCKCerts(ctx context.Context, refreshURL, fmspc string) error {
+	requestURL := fmt.Sprintf("%s?type=certs&fmspc=%s", refreshURL, url.QueryEscape(fmspc))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := r.credentials.authenticate(req, pccsAdminEndpoint); err != nil {
+		return fmt.Errorf("failed to authenticate request: %w", err)
+	}
+
+	resp, err := r.do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxPCKResponseSize))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d (Error-Code: %s)", resp.StatusCode, resp.Header.Get("Error-Code"))
+	}
+	return nil
+}
+
+// pckStatusError describes a PCK certificate request answered without certificate
+func pckStatusError(metric metrics.StatusCodeMetric) error {
+	return fmt.Errorf("no PCK certificate returned (HTTP status code: %s, Error-Code: %s)",
+		metric.HttpStatusCode, metric.IntelError)
+}
@@ -0,0 +1,85 @@
+package intelservices
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/secret"
+)
+
+// Headers of the PCCS tokens
+const (
+	UserTokenHeader  = "user-token"
+	AdminTokenHeader = "admin-token"
+)
+
+// maxRedirects matches the redirect limit of the default HTTP client policy
+const maxRedirects = 10
+
+// credentialHeaders are removed from requests redirected to another host or scheme
+var credentialHeaders = []string{SubscriptionKeyHeader, UserTokenHeader, AdminTokenHeader}
+
+// ErrCredentialMissing is returned when the credential of an endpoint class is not configured
+var ErrCredentialMissing = errors.New("credential not configured")
+
+// endpointClass is the kind of endpoint a request is sent to. Each credential is only attached to
+// the requests of its own class, so that e.g. a PCCS never receives the Intel API key.
+type endpointClass int
+
+const (
+	pccsEndpoint      endpointClass = iota // PCCS PCK certificate and collateral endpoints, no credential
+	intelPCKEndpoint                       // Intel PCS PCK certificate endpoints, API key when configured
+	pccsUserEndpoint                       // PCCS user endpoints, user token
+	pccsAdminEndpoint                      // PCCS admin endpoints, admin token
+)
+
+// credentials holds the secrets of the endpoint classes, read again from their files on each request
+type credentials struct {
+	intelAPIKey    *secret.File
+	pccsUserToken  *secret.File
+	pccsAdminToken *secret.File
+}
+
+// authenticate sets the credential of the endpoint class on the request. The Intel API key is optional,
+// the PCCS tokens are required by their endpoints.
+func (c credentials) authenticate(req *http.Request, class endpointClass) error {
+	var header string
+	var file *secret.File
+	switch class {
+	case intelPCKEndpoint:
+		if c.intelAPIKey == nil {
+			return nil
+		}
+		header, file = SubscriptionKeyHeader, c.intelAPIKey
+	case pccsUserEndpoint:
+		header, file = UserTokenHeader, c.pccsUserToken
+	case pccsAdminEndpoint:
+		header, file = AdminTokenHeader, c.pccsAdminToken
+	default:
+		return nil
+	}
+	if file == nil {
+		return ErrCredentialMissing
+	}
+
+	value, err := file.Value()
+	if err != nil {
+		return err
+	}
+	req.Header.Set(header, value)
+	return nil
+}
+
+// checkRedirect follows redirects like the default policy, but does not forward the credentials
+// to another host, nor over another scheme, e.g. in cleartext after a redirect from HTTPS to HTTP
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.New("stopped after 10 redirects")
+	}
+	if req.URL.Host != via[0].URL.Host || req.URL.Scheme != via[0].URL.Scheme {
+		for _, header := range credentialHeaders {
+			req.Header.Del(header)
+		}
+	}
+	return nil
+}
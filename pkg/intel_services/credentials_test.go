@@ -0,0 +1,49 @@
+package intelservices
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRedirect(t *testing.T) {
+	cases := []struct {
+		msg               string
+		location          string
+		wantedCredentials bool
+	}{
+		{
+			msg:               "credentials are kept on the same host and scheme",
+			location:          "https://api.example.com/mirror",
+			wantedCredentials: true,
+		},
+		{
+			msg:      "credentials are not forwarded to another host",
+			location: "https://mirror.example.com/pckcert",
+		},
+		{
+			msg:      "credentials are not sent in cleartext after a redirect to HTTP",
+			location: "http://api.example.com/pckcert",
+		},
+	}
+
+	for _, c := range cases {
+		original, err := http.NewRequest(http.MethodGet, "https://api.example.com/pckcert", http.NoBody)
+		assert.NoError(t, err, c.msg)
+		redirected, err := http.NewRequest(http.MethodGet, c.location, http.NoBody)
+		assert.NoError(t, err, c.msg)
+		for _, header := range credentialHeaders {
+			redirected.Header.Set(header, "secret")
+		}
+
+		assert.NoError(t, checkRedirect(redirected, []*http.Request{original}), c.msg)
+		for _, header := range credentialHeaders {
+			if c.wantedCredentials {
+				assert.Equal(t, "secret", redirected.Header.Get(header), c.msg)
+			} else {
+				assert.Empty(t, redirected.Header.Get(header), c.msg)
+			}
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package intelservices
+
+import (
+	"cmp"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/constants"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// BreakerState is the circuit breaker state of a PCCS
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // the PCCS is tried
+	BreakerOpen                         // the PCCS failed repeatedly and is skipped until the cool-down elapsed
+	BreakerHalfOpen                     // the cool-down elapsed, the PCCS is tried again and skipped on the next failure
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// EndpointHealth tracks the failures of the PCCS across checks, so that a PCCS failing repeatedly is
+// skipped for a cool-down period instead of delaying every check by its timeout. The consecutive failures
+// are also counted per request path, so that e.g. valid CRLs do not hide the invalid PCK certificates
+// of a stale cache.
+type EndpointHealth struct {
+	mu               sync.Mutex
+	metrics          *metrics.RegistrationServiceMetricsRegistry
+	failureThreshold int // consecutive failures opening the breaker, disabled when 0
+	coolDown         time.Duration
+	endpoints        map[string]*endpointHealth
+	lastGood         string // PCCS that last returned a valid answer
+}
+
+type endpointHealth struct {
+	state        BreakerState
+	failures     int            // consecutive failures
+	pathFailures map[string]int // consecutive failures of each request path, reset by its successes only
+	openedAt     time.Time
+}
+
+// NewEndpointHealth returns the health of the PCCS, whose breaker opens after failureThreshold consecutive
+// failures and is half-open after coolDown. The breaker is disabled when failureThreshold is 0.
+// The breaker states are exported to metricsRegistry.
+func NewEndpointHealth(failureThreshold int, coolDown time.Duration, metricsRegistry *metrics.RegistrationServiceMetricsRegistry) *EndpointHealth {
+	return &EndpointHealth{
+		metrics:          metricsRegistry,
+		failureThreshold: failureThreshold,
+		coolDown:         coolDown,
+		endpoints:        make(map[string]*endpointHealth),
+	}
+}
+
+// endpoint returns the health of a PCCS, exporting its closed breaker when first seen
+func (h *EndpointHealth) endpoint(pccsURL string) *endpointHealth {
+	endpoint, ok := h.endpoints[pccsURL]
+	if !ok {
+		endpoint = &endpointHealth{pathFailures: make(map[string]int)}
+		h.endpoints[pccsURL] = endpoint
+		h.metrics.UpdatePCCSBreakerMetric(pccsURL, int(BreakerClosed))
+	}
+	return endpoint
+}
+
+// allow reports whether the PCCS may be tried, turning an open breaker half-open once the cool-down elapsed
+func (h *EndpointHealth) allow(pccsURL string, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	endpoint := h.endpoint(pccsURL)
+	if endpoint.state != BreakerOpen {
+		return true
+	}
+	if now.Sub(endpoint.openedAt) < h.coolDown {
+		return false
+	}
+	endpoint.state = BreakerHalfOpen
+	h.metrics.UpdatePCCSBreakerMetric(pccsURL, int(BreakerHalfOpen))
+	return true
+}
+
+// record records the outcome of a request to the path of the PCCS and returns the breaker state before and
+// after it. A half-open breaker opens again on the first failure and closes on the first success.
+func (h *EndpointHealth) record(pccsURL, path string, ok bool, now time.Time) (BreakerState, BreakerState) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	endpoint := h.endpoint(pccsURL)
+	from := endpoint.state
+	switch {
+	case ok && endpoint.state == BreakerClosed:
+		endpoint.failures = 0
+		delete(endpoint.pathFailures, path)
+	case ok:
+		endpoint.failures = 0
+		clear(endpoint.pathFailures)
+		endpoint.state = BreakerClosed
+	case h.failureThreshold > 0:
+		endpoint.failures++
+		endpoint.pathFailures[path]++
+		if endpoint.state == BreakerHalfOpen || endpoint.failures >= h.failureThreshold ||
+			endpoint.pathFailures[path] >= h.failureThreshold {
+			endpoint.state = BreakerOpen
+			endpoint.openedAt = now
+		}
+	}
+	if endpoint.state != from {
+		h.metrics.UpdatePCCSBreakerMetric(pccsURL, int(endpoint.state))
+	}
+	return from, endpoint.state
+}
+
+// succeeded records the PCCS that last returned a valid answer, tried first by the sticky order
+func (h *EndpointHealth) succeeded(pccsURL string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastGood = pccsURL
+}
+
+func (h *EndpointHealth) lastGoodURL() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastGood
+}
+
+// recordOutcome records the outcome of the last attempt of a request to a PCCS. A PCCS that did not answer,
+// answered with a server error or rate limited the request failed; any other answer shows it is available.
+func (r *IntelService) recordOutcome(req *http.Request, resp *http.Response, err error) {
+	r.recordHealth(req, err == nil && resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests)
+}
+
+// recordHealth records whether a PCCS request succeeded in the health of the PCCS.
+// Requests of a cancelled check tell nothing about the PCCS.
+func (r *IntelService) recordHealth(req *http.Request, healthy bool) {
+	pccsURL, ok := r.pccsOf(req)
+	if !ok || req.Context().Err() != nil {
+		return
+	}
+
+	from, to := r.health.record(pccsURL, req.URL.Path, healthy, time.Now())
+	switch {
+	case to == BreakerOpen && from != BreakerOpen:
+		r.log.Warn("PCCS circuit breaker opened, skipping the PCCS",
+			zap.String("url", pccsURL),
+			zap.Duration("coolDown", r.health.coolDown))
+	case to == BreakerClosed && from != BreakerClosed:
+		r.log.Info("PCCS circuit breaker closed",
+			zap.String("url", pccsURL))
+	}
+}
+
+// pccsOf returns the PCCS base URL the request is sent to, if any
+func (r *IntelService) pccsOf(req *http.Request) (string, bool) {
+	requestURL := requestEndpoint(req)
+	for _, baseURL := range r.endpoints.pccsURLs {
+		if strings.HasPrefix(requestURL, baseURL+"/") {
+			return baseURL, true
+		}
+	}
+	return "", false
+}
+
+// fallbackOrder returns the indices of the endpoints of the PCCS-then-Intel fallback lists in the order
+// they are tried: the PCCS whose breaker is not open in the configured order, then Intel, always last
+func (r *IntelService) fallbackOrder() []int {
+	now := time.Now()
+	var order []int
+	for i, baseURL := range r.endpoints.pccsURLs {
+		if !r.health.allow(baseURL, now) {
+			r.log.Debug("Skipping PCCS, its circuit breaker is open",
+				zap.String("url", baseURL))
+			continue
+		}
+		order = append(order, i)
+	}
+
+	switch r.order {
+	case constants.PCCSOrderSticky:
+		lastGood := r.health.lastGoodURL()
+		if i := slices.IndexFunc(order, func(i int) bool { return r.endpoints.pccsURLs[i] == lastGood }); i > 0 {
+			sticky := order[i]
+			order = slices.Insert(slices.Delete(order, i, i+1), 0, sticky)
+		}
+	case constants.PCCSOrderWeighted:
+		// weighted random order without replacement: each PCCS gets the key u^(1/weight) and the PCCS
+		// are tried by decreasing key
+		keys := make(map[int]float64, len(order))
+		for _, i := range order {
+			weight := 1
+			if i < len(r.weights) {
+				weight = r.weights[i]
+			}
+			keys[i] = math.Pow(rand.Float64(), 1/float64(weight))
+		}
+		slices.SortFunc(order, func(a, b int) int { return cmp.Compare(keys[b], keys[a]) })
+	case constants.PCCSOrderRandom:
+		rand.Shuffle(len(order), func(a, b int) { order[a], order[b] = order[b], order[a] })
+	}
+
+	return append(order, len(r.endpoints.pccsURLs))
+}
@@ -0,0 +1,115 @@
+package intelservices
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestEndpointHealth(t *testing.T) {
+	const pccsURL = "https://pccs.example.com"
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	coolDown := time.Minute
+
+	// step is a request to the PCCS at an offset from start, or only the check whether it may be tried
+	type step struct {
+		at          time.Duration
+		path        string // /pckcert when empty
+		ok          *bool
+		wantedAllow bool
+		wantedState BreakerState
+	}
+	cases := []struct {
+		msg              string
+		failureThreshold int
+		steps            []step
+	}{
+		{
+			msg: "breaker disabled never opens",
+			steps: []step{
+				{ok: new(false), wantedAllow: true, wantedState: BreakerClosed},
+				{ok: new(false), wantedAllow: true, wantedState: BreakerClosed},
+				{ok: new(false), wantedAllow: true, wantedState: BreakerClosed},
+			},
+		},
+		{
+			msg:              "breaker opens after the consecutive failures",
+			failureThreshold: 2,
+			steps: []step{
+				{ok: new(false), wantedAllow: true, wantedState: BreakerClosed},
+				{ok: new(false), wantedAllow: true, wantedState: BreakerOpen},
+				{at: coolDown - time.Second, wantedAllow: false, wantedState: BreakerOpen},
+			},
+		},
+		{
+			msg:              "success resets the consecutive failures",
+			failureThreshold: 2,
+			steps: []step{
+				{ok: new(false), wantedAllow: true, wantedState: BreakerClosed},
+				{ok: new(true), wantedAllow: true, wantedState: BreakerClosed},
+				{ok: new(false), wantedAllow: true, wantedState: BreakerClosed},
+			},
+		},
+		{
+			msg:              "success of another endpoint does not reset the consecutive failures",
+			failureThreshold: 2,
+			steps: []step{
+				{ok: new(false), wantedAllow: true, wantedState: BreakerClosed},
+				{path: "/pckcrl", ok: new(true), wantedAllow: true, wantedState: BreakerClosed},
+				{ok: new(false), wantedAllow: true, wantedState: BreakerOpen},
+			},
+		},
+		{
+			msg:              "failures of different endpoints open the breaker",
+			failureThreshold: 2,
+			steps: []step{
+				{ok: new(false), wantedAllow: true, wantedState: BreakerClosed},
+				{path: "/pckcrl", ok: new(false), wantedAllow: true, wantedState: BreakerOpen},
+			},
+		},
+		{
+			msg:              "half-open breaker opens again on the first failure",
+			failureThreshold: 2,
+			steps: []step{
+				{ok: new(false), wantedAllow: true, wantedState: BreakerClosed},
+				{ok: new(false), wantedAllow: true, wantedState: BreakerOpen},
+				{at: coolDown, wantedAllow: true, wantedState: BreakerHalfOpen},
+				{at: coolDown, ok: new(false), wantedAllow: true, wantedState: BreakerOpen},
+				{at: 2*coolDown - time.Second, wantedAllow: false, wantedState: BreakerOpen},
+			},
+		},
+		{
+			msg:              "half-open breaker closes on success",
+			failureThreshold: 2,
+			steps: []step{
+				{ok: new(false), wantedAllow: true, wantedState: BreakerClosed},
+				{ok: new(false), wantedAllow: true, wantedState: BreakerOpen},
+				{at: coolDown, ok: new(true), wantedAllow: true, wantedState: BreakerClosed},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		health := NewEndpointHealth(c.failureThreshold, coolDown, metrics.NewRegistrationServiceMetricsRegistry(zap.NewNop()))
+		for i, s := range c.steps {
+			now := start.Add(s.at)
+			allow := health.allow(pccsURL, now)
+			assert.Equal(t, s.wantedAllow, allow, "%s: step %d", c.msg, i)
+			state := health.endpoint(pccsURL).state
+			if s.ok != nil {
+				path := s.path
+				if path == "" {
+					path = "/pckcert"
+				}
+				_, state = health.record(pccsURL, path, *s.ok, now)
+			}
+			assert.Equal(t, s.wantedState, state, "%s: step %d", c.msg, i)
+			assert.Equal(t, float64(s.wantedState), testutil.ToFloat64(metrics.PCCSBreakerStateMetric.WithLabelValues(pccsURL)),
+				"%s: step %d", c.msg, i)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package intelservices
+
+import (
+	"context"
+	"time"
+
+	pckcert "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/pck_cert"
+	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// pckResult is the outcome of a PCK certificate request to a PCCS
+type pckResult struct {
+	pccs   int // index of the PCCS
+	number int // order in which the PCCS was requested, from 1
+	metric metrics.StatusCodeMetric
+	cert   *pckcert.Certificate
+	err    error
+}
+
+// retrievePCKHedged requests the PCK certificate from the PCCS in the given order without waiting for the
+// previous ones to answer: the next PCCS is requested after the hedge delay, or at once when a PCCS failed.
+// The first valid certificate is returned and the other requests are cancelled. Without a valid
+// certificate, the metric and error of the last PCCS to answer are returned.
+func (r *IntelService) retrievePCKHedged(ctx context.Context, pccs []int, platformInfo *sgxplatforminfo.SgxPlatformInfo, platform pckcert.Platform) (metrics.StatusCodeMetric, *pckcert.Certificate, error) {
+	hedgeCtx, cancel := context.WithCancel(ctx)
+	// the requests still running once a certificate was returned are cancelled
+	defer cancel()
+
+	// buffered so that the cancelled requests never block
+	results := make(chan pckResult, len(pccs))
+	started := 0
+	start := func() {
+		i := pccs[started]
+		started++
+		number := started
+		baseURL := r.endpoints.pckRetrievalURLs[i]
+		r.log.Debug("Attempting PCK retrieval",
+			zap.String("url", baseURL),
+			zap.String("endpointType", "pccs"),
+			zap.Int("attemptNumber", number),
+			zap.Bool("hedged", true))
+		go func() {
+			metric, cert, err := r.retrievePCKFromEndpoint(hedgeCtx, pckRequestURL(baseURL, platformInfo, true), pccsEndpoint, platform)
+			results <- pckResult{pccs: i, number: number, metric: metric, cert: cert, err: err}
+		}()
+	}
+
+	start()
+	for r.hedgeDelay == 0 && started < len(pccs) {
+		start()
+	}
+	delay := time.NewTimer(r.hedgeDelay)
+	defer delay.Stop()
+
+	var last pckResult
+	for answered := 0; answered < started; {
+		// no PCCS is left to start once all were started
+		var next <-chan time.Time
+		if started < len(pccs) {
+			next = delay.C
+		}
+
+		select {
+		case <-next:
+			if ctx.Err() == nil {
+				start()
+				delay.Reset(r.hedgeDelay)
+			}
+		case result := <-results:
+			answered++
+			baseURL := r.endpoints.pckRetrievalURLs[result.pccs]
+			if result.err == nil && result.metric.Status == metrics.PlatformDirectlyRegistered {
+				r.health.succeeded(r.endpoints.pccsURLs[result.pccs])
+				r.logPCKRetrieved(baseURL, "pccs", result.number, result.cert)
+				return result.metric, result.cert, nil
+			}
+
+			last = result
+			r.log.Warn("PCK retrieval failed, trying next endpoint",
+				zap.String("url", baseURL),
+				zap.String("endpointType", "pccs"),
+				zap.Bool("hedged", true),
+				zap.Error(result.err))
+			// the next PCCS does not wait for the delay once a PCCS failed
+			if started < len(pccs) && ctx.Err() == nil {
+				start()
+				delay.Reset(r.hedgeDelay)
+			}
+		}
+	}
+	return last.metric, nil, last.err
+}
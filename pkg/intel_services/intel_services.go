@@ -7,14 +7,19 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	mpmanagement "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_management"
+	pckcert "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/pck_cert"
 	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
+	tcbinfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/tcb_info"
 
 	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/config"
 	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/constants"
@@ -22,31 +27,72 @@ import (
 	"go.uber.org/zap"
 )
 
+// maxPCKResponseSize bounds the size of a PCK certificate response body
+const maxPCKResponseSize = 64 * 1024
+
+// maxCRLResponseSize bounds the size of a PCK CRL response body
+const maxCRLResponseSize = 4 * 1024 * 1024
+
+// maxTCBInfoResponseSize bounds the size of a TCB Info response body
+const maxTCBInfoResponseSize = 1024 * 1024
+
+// maxEnclaveIdentityResponseSize bounds the size of a QE or QvE identity response body
+const maxEnclaveIdentityResponseSize = 1024 * 1024
+
 // RegServiceEndpoints holds the list of registration and PCK retrieval URLs
 type RegServiceEndpoints struct {
-	registrationURL  string
-	pckRetrievalURLs []string // PCCS URLs + Intel fallback
+	registrationURL        string
+	addPackageURL          string
+	pckRetrievalURLs       []string // PCCS URLs + Intel fallback
+	pckCRLURLs             []string // PCCS URLs + Intel fallback
+	tcbInfoURLs            []string // PCCS URLs + Intel fallback
+	pccsURLs               []string // PCCS base URLs
+	platformCollateralURLs []string // PCCS admin endpoints, in the order of pccsURLs
+	refreshURLs            []string // PCCS admin endpoints, in the order of pccsURLs
+	pckCertsURL            string   // Intel only, requires the API subscription key
+	qeIdentityURL          string   // Intel only, uploaded to the PCCS with the PCK certificates
+	qveIdentityURL         string   // Intel only, uploaded to the PCCS with the PCK certificates
+	rootCACRLURL           string   // Intel only, uploaded to the PCCS with the PCK certificates
+	certificationPath      string   // PCCS path of the PCS API version, e.g. /sgx/certification/v4
 }
 
 type IntelService struct {
-	log        *zap.Logger
-	httpClient *http.Client         // Reusable HTTP client with TLS config
-	endpoints  *RegServiceEndpoints // URL configuration
+	log         *zap.Logger
+	metrics     *metrics.RegistrationServiceMetricsRegistry
+	httpClient  *http.Client         // Reusable HTTP client with TLS config
+	endpoints   *RegServiceEndpoints // URL configuration
+	sgxRootCA   *x509.Certificate    // Root CA the PCK certificates are verified against
+	credentials credentials          // Intel API key and PCCS tokens, never logged
+	retry       retryPolicy          // Retries of the requests to Intel and the PCCS
+	health      *EndpointHealth      // Circuit breakers of the PCCS, kept across checks
+	order       string               // Order in which the PCCS are tried before Intel
+	weights     []int                // Weights of the PCCS of the weighted order
+	hedgePCK    bool                 // Request the PCK certificate from the PCCS concurrently
+	hedgeDelay  time.Duration        // Delay before the next PCCS is requested, all at once when 0
 }
 
-// NewIntelService creates a new IntelService with configured HTTP client and endpoints
-func NewIntelService(logger *zap.Logger, cfg *config.RegistrationServiceConfig) (*IntelService, error) {
+// NewIntelService creates a new IntelService with configured HTTP client and endpoints. The health of the
+// PCCS is shared by the services of successive checks; a new one is created when nil.
+func NewIntelService(logger *zap.Logger, cfg *config.RegistrationServiceConfig,
+	metricsRegistry *metrics.RegistrationServiceMetricsRegistry, health *EndpointHealth) (*IntelService, error) {
 	// Build TLS config (always uses system CA + optional custom CA for PCCS)
 	tlsConfig, err := buildTLSConfig(cfg.PCCSCACertPath, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build TLS config: %w", err)
 	}
 
+	proxy, err := buildProxy(cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build proxy config: %w", err)
+	}
+
 	// Create HTTP client with TLS config and connection pooling
 	httpClient := &http.Client{
-		Timeout: cfg.RequestTimeout,
+		Timeout:       cfg.RequestTimeout,
+		CheckRedirect: checkRedirect,
 		Transport: &http.Transport{
 			TLSClientConfig: tlsConfig,
+			Proxy:           proxy,
 			// Enable connection pooling for better performance
 			MaxIdleConns:        10,
 			MaxIdleConnsPerHost: 2,
@@ -57,13 +103,56 @@ func NewIntelService(logger *zap.Logger, cfg *config.RegistrationServiceConfig)
 	// Build endpoint lists (PCCS URLs + Intel fallback)
 	endpoints := buildEndpoints(cfg, logger)
 
+	sgxRootCA, err := pckcert.LoadRootCA(cfg.SGXRootCAPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SGX root CA: %w", err)
+	}
+
+	if health == nil {
+		health = NewEndpointHealth(cfg.PCCSBreakerFailures, cfg.PCCSBreakerCoolDown, metricsRegistry)
+	}
+
 	return &IntelService{
 		log:        logger,
+		metrics:    metricsRegistry,
 		httpClient: httpClient,
 		endpoints:  endpoints,
+		sgxRootCA:  sgxRootCA,
+		credentials: credentials{
+			intelAPIKey:    cfg.IntelAPIKey,
+			pccsUserToken:  cfg.PCCSUserToken,
+			pccsAdminToken: cfg.PCCSAdminToken,
+		},
+		retry:      newRetryPolicy(cfg),
+		health:     health,
+		order:      cfg.PCCSOrder,
+		weights:    cfg.PCCSWeights,
+		hedgePCK:   cfg.PCCSHedgePCK,
+		hedgeDelay: cfg.PCCSHedgeDelay,
 	}, nil
 }
 
+// buildProxy returns the proxy function of the HTTP transport for the configured proxy mode.
+// The "uefi" mode ends up here when the UEFI ProxyConf defers to the default proxy.
+func buildProxy(cfg *config.RegistrationServiceConfig, logger *zap.Logger) (func(*http.Request) (*url.URL, error), error) {
+	switch cfg.ProxyMode {
+	case constants.ProxyModeDirect:
+		logger.Debug("Connecting without proxy")
+		return nil, nil
+	case constants.ProxyModeManual:
+		proxyURL, err := config.ParseProxyURL(cfg.ProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		// Redacted hides the proxy password
+		logger.Debug("Using manual proxy", zap.String("proxy", proxyURL.Redacted()))
+		return http.ProxyURL(proxyURL), nil
+	default:
+		logger.Debug("Using the proxy environment variables")
+		return http.ProxyFromEnvironment, nil
+	}
+}
+
 func buildTLSConfig(caCertPath string, logger *zap.Logger) (*tls.Config, error) {
 	// Base TLS config - always require TLS 1.2+
 	tlsConfig := &tls.Config{
@@ -150,22 +239,47 @@ func buildTLSConfig(caCertPath string, logger *zap.Logger) (*tls.Config, error)
 func buildEndpoints(cfg *config.RegistrationServiceConfig, logger *zap.Logger) *RegServiceEndpoints {
 	endpoints := &RegServiceEndpoints{}
 
-	// Platform registration always goes directly to Intel API
+	// Platform registration and package addition always go directly to Intel API
 	endpoints.registrationURL = cfg.IntelRegistrationURL
+	endpoints.addPackageURL = cfg.IntelAddPackageURL
+	endpoints.pckCertsURL = cfg.IntelPCKCertsURL
+	endpoints.qeIdentityURL = cfg.IntelQEIdentityURL
+	endpoints.qveIdentityURL = cfg.IntelQvEIdentityURL
+	endpoints.rootCACRLURL = cfg.IntelRootCACRLURL
+
+	// The PCCS serve the same PCS API version as Intel
+	apiVersion := cfg.PCSAPIVersion
+	if apiVersion == "" {
+		apiVersion = constants.PCSAPIVersionV4
+	}
+	endpoints.certificationPath = constants.PCSCertificationPath + apiVersion
 
 	// PCK certificate retrieval: try PCCS first (if configured), then Intel as fallback
 	if len(cfg.PCCSURLs) > 0 {
 		logger.Info("Configuring PCCS endpoints for PCK retrieval",
 			zap.Int("count", len(cfg.PCCSURLs)))
+		endpoints.pccsURLs = cfg.PCCSURLs
 		for _, baseURL := range cfg.PCCSURLs {
 			endpoints.pckRetrievalURLs = append(endpoints.pckRetrievalURLs,
-				baseURL+"/sgx/certification/v4/pckcert")
+				baseURL+endpoints.certificationPath+"/pckcert")
+			endpoints.pckCRLURLs = append(endpoints.pckCRLURLs,
+				baseURL+endpoints.certificationPath+"/pckcrl")
+			endpoints.tcbInfoURLs = append(endpoints.tcbInfoURLs,
+				baseURL+endpoints.certificationPath+"/tcb")
+			endpoints.platformCollateralURLs = append(endpoints.platformCollateralURLs,
+				baseURL+endpoints.certificationPath+"/platformcollateral")
+			endpoints.refreshURLs = append(endpoints.refreshURLs,
+				baseURL+endpoints.certificationPath+"/refresh")
 		}
 	}
 
 	// Always add Intel PCK retrieval URL as final fallback
 	endpoints.pckRetrievalURLs = append(endpoints.pckRetrievalURLs,
 		cfg.IntelPCKRetrievalURL)
+	endpoints.pckCRLURLs = append(endpoints.pckCRLURLs,
+		cfg.IntelPCKCRLURL)
+	endpoints.tcbInfoURLs = append(endpoints.tcbInfoURLs,
+		cfg.IntelTCBInfoURL)
 
 	return endpoints
 }
@@ -184,6 +298,20 @@ func createIntelStatusCodeMetricForPlatformRegistration(httpStatusCode int, inte
 	}
 }
 
+func createIntelStatusCodeMetricForAddPackage(httpStatusCode int, intelErrorCode string) metrics.StatusCodeMetric {
+	var Status metrics.StatusCode
+	if httpStatusCode >= http.StatusBadRequest && httpStatusCode < http.StatusInternalServerError {
+		Status = metrics.InvalidAddPackageRequest
+	} else {
+		Status = metrics.IntelAddPackageRequestFailed
+	}
+	return metrics.StatusCodeMetric{
+		Status:         Status,
+		HttpStatusCode: strconv.Itoa(httpStatusCode),
+		IntelError:     intelErrorCode,
+	}
+}
+
 func createIntelStatusCodeMetricForDirectRegistration(httpStatusCode int, intelErrorCode string) metrics.StatusCodeMetric {
 
 	var Status metrics.StatusCode
@@ -200,14 +328,14 @@ func createIntelStatusCodeMetricForDirectRegistration(httpStatusCode int, intelE
 	}
 }
 
-func (r *IntelService) RegisterPlatform(platformManifest mpmanagement.PlatformManifest, metricsRegistry *metrics.RegistrationServiceMetricsRegistry) (metrics.StatusCodeMetric, error) {
+func (r *IntelService) RegisterPlatform(ctx context.Context, platformManifest mpmanagement.PlatformManifest, metricsRegistry *metrics.RegistrationServiceMetricsRegistry) (metrics.StatusCodeMetric, error) {
 	// Platform registration only goes to Intel API (there should be exactly 1 URL)
 	url := r.endpoints.registrationURL
 
 	r.log.Debug("Attempting platform registration to Intel API",
 		zap.String("url", url))
 
-	metric, err := r.registerPlatformToEndpoint(url, platformManifest)
+	metric, err := r.registerPlatformToEndpoint(ctx, url, platformManifest)
 
 	if err == nil && metric.Status == metrics.PlatformRebootNeeded {
 		r.log.Info("Platform registration successful",
@@ -221,15 +349,15 @@ func (r *IntelService) RegisterPlatform(platformManifest mpmanagement.PlatformMa
 	return metric, err
 }
 
-func (r *IntelService) registerPlatformToEndpoint(url string, platformManifest mpmanagement.PlatformManifest) (metrics.StatusCodeMetric, error) {
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(platformManifest))
+func (r *IntelService) registerPlatformToEndpoint(ctx context.Context, url string, platformManifest mpmanagement.PlatformManifest) (metrics.StatusCodeMetric, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(platformManifest))
 	if err != nil {
 		return metrics.CreateUnknownErrorStatusCodeMetric(), fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/octet-stream")
 
 	// Execute request
-	resp, err := r.httpClient.Do(req)
+	resp, err := r.do(req)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
 			return metrics.StatusCodeMetric{Status: metrics.IntelConnectFailed}, fmt.Errorf("connection timeout: %w", err)
@@ -245,41 +373,145 @@ func (r *IntelService) registerPlatformToEndpoint(url string, platformManifest m
 	return createIntelStatusCodeMetricForPlatformRegistration(resp.StatusCode, errorCode), nil
 }
 
+// AddPackage sends the AddPackage request to the Intel API and returns the membership certificates
+// that must be written back to the SgxRegistrationServerResponse UEFI variable
+func (r *IntelService) AddPackage(ctx context.Context, addPackageRequest mpmanagement.AddPackageRequest, metricsRegistry *metrics.RegistrationServiceMetricsRegistry) (metrics.StatusCodeMetric, []byte, error) {
+	// Package addition only goes to Intel API
+	url := r.endpoints.addPackageURL
+
+	r.log.Debug("Attempting package addition to Intel API",
+		zap.String("url", url))
+
+	metric, response, err := r.addPackageToEndpoint(ctx, url, addPackageRequest)
+
+	if err == nil && metric.Status == metrics.PackageAddedRebootNeeded {
+		r.log.Info("Package addition successful",
+			zap.String("url", url),
+			zap.Int("responseSize", len(response)))
+		return metric, response, nil
+	}
+
+	r.log.Error("Package addition failed",
+		zap.String("url", url),
+		zap.Error(err))
+	return metric, nil, err
+}
+
+func (r *IntelService) addPackageToEndpoint(ctx context.Context, url string, addPackageRequest mpmanagement.AddPackageRequest) (metrics.StatusCodeMetric, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(addPackageRequest))
+	if err != nil {
+		return metrics.CreateUnknownErrorStatusCodeMetric(), nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	// Execute request
+	resp, err := r.do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return metrics.StatusCodeMetric{Status: metrics.IntelConnectFailed}, nil, fmt.Errorf("connection timeout: %w", err)
+		}
+		return metrics.CreateUnknownErrorStatusCodeMetric(), nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errorCode := resp.Header.Get("Error-Code")
+		return createIntelStatusCodeMetricForAddPackage(resp.StatusCode, errorCode), nil, nil
+	}
+
+	// The membership certificates must fit in the SgxRegistrationServerResponse UEFI variable
+	response, err := io.ReadAll(io.LimitReader(resp.Body, mpmanagement.MPMaxResponseSize+1))
+	if err != nil {
+		return metrics.CreateUnknownErrorStatusCodeMetric(), nil, fmt.Errorf("failed to read add package response: %w", err)
+	}
+	if len(response) == 0 || len(response) > mpmanagement.MPMaxResponseSize {
+		return metrics.CreateUnknownErrorStatusCodeMetric(), nil,
+			fmt.Errorf("invalid add package response size %d", len(response))
+	}
+	return metrics.StatusCodeMetric{Status: metrics.PackageAddedRebootNeeded}, response, nil
+}
+
+// queriedPlatform returns the PCEID and raw TCB of the platform the PCK certificates are queried for
+func queriedPlatform(platformInfo *sgxplatforminfo.SgxPlatformInfo) (pckcert.Platform, error) {
+	pcesvn, err := strconv.ParseUint(platformInfo.PCEInfo.PCEisvsvn, 16, 16)
+	if err != nil {
+		return pckcert.Platform{}, fmt.Errorf("invalid PCESVN '%s': %w", platformInfo.PCEInfo.PCEisvsvn, err)
+	}
+	return pckcert.Platform{
+		PCEID:  platformInfo.PCEInfo.PCEID,
+		CPUSVN: platformInfo.CpuSvn,
+		PCESVN: uint16(pcesvn),
+	}, nil
+}
+
+// pckRequestURL returns the PCK certificate request URL of the platform. The PCCS also get the QEID,
+// which identifies the platform in their cache.
+func pckRequestURL(baseURL string, platformInfo *sgxplatforminfo.SgxPlatformInfo, isPCCS bool) string {
+	requestURL := fmt.Sprintf("%s?encrypted_ppid=%s&pceid=%s&cpusvn=%s&pcesvn=%s",
+		baseURL, platformInfo.EncryptedPPID, platformInfo.PCEInfo.PCEID, platformInfo.CpuSvn, platformInfo.PCEInfo.PCEisvsvn)
+	if isPCCS {
+		requestURL += fmt.Sprintf("&qeid=%s", platformInfo.QeId)
+	}
+	return requestURL
+}
+
 // RetrievePCK attempts to retrieve PCK certificate
-// It tries each endpoint in order (PCCS first, then Intel) until one succeeds
-func (r *IntelService) RetrievePCK(platformInfo *sgxplatforminfo.SgxPlatformInfo, metricsRegistry *metrics.RegistrationServiceMetricsRegistry) (metrics.StatusCodeMetric, error) {
+// It tries each endpoint in order (PCCS first, then Intel) until one returns a certificate
+// that verifies against the SGX root CA and matches the platform. The PCCS whose circuit breaker
+// is open are skipped and the others are tried in the configured PCCS order. With hedging, the PCCS
+// are requested concurrently and Intel is only requested once none of them returned a certificate.
+func (r *IntelService) RetrievePCK(ctx context.Context, platformInfo *sgxplatforminfo.SgxPlatformInfo, metricsRegistry *metrics.RegistrationServiceMetricsRegistry) (metrics.StatusCodeMetric, *pckcert.Certificate, error) {
 	var lastErr error
 	var lastMetric metrics.StatusCodeMetric
 
+	platform, err := queriedPlatform(platformInfo)
+	if err != nil {
+		return metrics.CreateUnknownErrorStatusCodeMetric(), nil, err
+	}
+
+	order := r.fallbackOrder()
+	next := 0
+	if pccs := order[:len(order)-1]; r.hedgePCK && len(pccs) > 1 {
+		metric, cert, err := r.retrievePCKHedged(ctx, pccs, platformInfo, platform)
+		if cert != nil {
+			return metric, cert, nil
+		}
+		lastErr = err
+		lastMetric = metric
+		next = len(pccs)
+	}
+
 	// Try each PCK retrieval endpoint in order
-	for i, baseURL := range r.endpoints.pckRetrievalURLs {
-		isPCCS := i < len(r.endpoints.pckRetrievalURLs)-1
+	for attempt := next; attempt < len(order); attempt++ {
+		i := order[attempt]
+		// The next endpoint is not tried once the check is cancelled
+		if err := ctx.Err(); err != nil {
+			return metrics.CreateUnknownErrorStatusCodeMetric(), nil, err
+		}
+		baseURL := r.endpoints.pckRetrievalURLs[i]
+		isPCCS := i < len(r.endpoints.pccsURLs)
 		endpointType := "intel"
+		class := intelPCKEndpoint
 		if isPCCS {
 			endpointType = "pccs"
+			class = pccsEndpoint
 		}
-		requestURL := fmt.Sprintf("%s?encrypted_ppid=%s&pceid=%s&cpusvn=%s&pcesvn=%s",
-			baseURL, platformInfo.EncryptedPPID, platformInfo.PCEInfo.PCEID, platformInfo.CpuSvn, platformInfo.PCEInfo.PCEisvsvn)
-
-		if isPCCS {
-			requestURL += fmt.Sprintf("&qeid=%s", platformInfo.QeId)
-		}
+		requestURL := pckRequestURL(baseURL, platformInfo, isPCCS)
 
 		r.log.Debug("Attempting PCK retrieval",
 			zap.String("url", baseURL),
 			zap.String("endpointType", endpointType),
-			zap.Int("attemptNumber", i+1))
+			zap.Int("attemptNumber", attempt+1))
 
-		metric, err := r.retrievePCKFromEndpoint(requestURL)
+		metric, cert, err := r.retrievePCKFromEndpoint(ctx, requestURL, class, platform)
 
 		// Success - return immediately
 		if err == nil && metric.Status == metrics.PlatformDirectlyRegistered {
-			r.log.Info("PCK retrieval successful",
-				zap.String("url", baseURL),
-				zap.String("endpointType", endpointType),
-				zap.Int("attemptNumber", i+1))
-
-			return metric, nil
+			if isPCCS {
+				r.health.succeeded(r.endpoints.pccsURLs[i])
+			}
+			r.logPCKRetrieved(baseURL, endpointType, attempt+1, cert)
+			return metric, cert, nil
 		}
 
 		// Store error and continue
@@ -295,29 +527,208 @@ func (r *IntelService) RetrievePCK(platformInfo *sgxplatforminfo.SgxPlatformInfo
 	// All endpoints failed
 	r.log.Error("PCK retrieval failed on all endpoints",
 		zap.Error(lastErr))
-	return lastMetric, lastErr
+	return lastMetric, nil, lastErr
+}
+
+func (r *IntelService) logPCKRetrieved(baseURL, endpointType string, attemptNumber int, cert *pckcert.Certificate) {
+	r.log.Info("PCK retrieval successful",
+		zap.String("url", baseURL),
+		zap.String("endpointType", endpointType),
+		zap.Int("attemptNumber", attemptNumber),
+		zap.String("fmspc", cert.FMSPC),
+		zap.String("tcbm", cert.TCBm),
+		zap.String("caType", cert.CAType),
+		zap.Time("notAfter", cert.NotAfter))
 }
 
 // retrievePCKFromEndpoint attempts PCK retrieval from a single endpoint
-func (r *IntelService) retrievePCKFromEndpoint(requestURL string) (metrics.StatusCodeMetric, error) {
-	req, err := http.NewRequest(http.MethodGet, requestURL, http.NoBody)
+func (r *IntelService) retrievePCKFromEndpoint(ctx context.Context, requestURL string, class endpointClass, platform pckcert.Platform) (metrics.StatusCodeMetric, *pckcert.Certificate, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, http.NoBody)
 	if err != nil {
-		return metrics.CreateUnknownErrorStatusCodeMetric(), fmt.Errorf("failed to create request: %w", err)
+		return metrics.CreateUnknownErrorStatusCodeMetric(), nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := r.credentials.authenticate(req, class); err != nil {
+		return metrics.CreateUnknownErrorStatusCodeMetric(), nil, fmt.Errorf("failed to authenticate request: %w", err)
 	}
 
-	// Execute request
-	resp, err := r.httpClient.Do(req)
+	// Execute request, the outcome of a successful answer is recorded once the certificate is verified
+	resp, err := r.send(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		r.recordOutcome(req, resp, err)
+	}
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
-			return metrics.CreateUnknownErrorStatusCodeMetric(), fmt.Errorf("connection timeout: %w", err)
+			return metrics.CreateUnknownErrorStatusCodeMetric(), nil, fmt.Errorf("connection timeout: %w", err)
 		}
-		return metrics.CreateUnknownErrorStatusCodeMetric(), fmt.Errorf("request failed: %w", err)
+		return metrics.CreateUnknownErrorStatusCodeMetric(), nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
-		return metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered}, nil
+	if resp.StatusCode != http.StatusOK {
+		errorCode := resp.Header.Get("Error-Code")
+		return createIntelStatusCodeMetricForDirectRegistration(resp.StatusCode, errorCode), nil, nil
 	}
-	errorCode := resp.Header.Get("Error-Code")
-	return createIntelStatusCodeMetricForDirectRegistration(resp.StatusCode, errorCode), nil
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPCKResponseSize))
+	if err != nil {
+		r.recordHealth(req, false)
+		return metrics.CreateUnknownErrorStatusCodeMetric(), nil, fmt.Errorf("failed to read PCK certificate response: %w", err)
+	}
+	cert, err := pckcert.ParseResponse(body, resp.Header)
+	if err == nil {
+		err = cert.Verify(r.sgxRootCA, platform, time.Now())
+	}
+	// a PCCS answering with an invalid certificate, e.g. from a stale cache, fails like an unavailable one
+	r.recordHealth(req, err == nil)
+	if err != nil {
+		return metrics.StatusCodeMetric{Status: metrics.InvalidPCKCertificate}, nil, err
+	}
+	return metrics.StatusCodeMetric{Status: metrics.PlatformDirectlyRegistered}, cert, nil
+}
+
+// RetrievePCKCRL retrieves the CRL of the PCK Processor or Platform CA
+// It tries each endpoint in order (PCCS first, then Intel) until one returns a CRL signed by issuer
+func (r *IntelService) RetrievePCKCRL(ctx context.Context, caType string, issuer *x509.Certificate) (*x509.RevocationList, error) {
+	var lastErr error
+
+	for _, i := range r.fallbackOrder() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		baseURL := r.endpoints.pckCRLURLs[i]
+		endpointType := "intel"
+		if i < len(r.endpoints.pccsURLs) {
+			endpointType = "pccs"
+		}
+		requestURL := fmt.Sprintf("%s?ca=%s", baseURL, caType)
+
+		crl, err := r.retrievePCKCRLFromEndpoint(ctx, requestURL, issuer)
+		if err == nil {
+			if i < len(r.endpoints.pccsURLs) {
+				r.health.succeeded(r.endpoints.pccsURLs[i])
+			}
+			r.log.Debug("PCK CRL retrieval successful",
+				zap.String("url", baseURL),
+				zap.String("endpointType", endpointType),
+				zap.String("ca", caType),
+				zap.Time("nextUpdate", crl.NextUpdate))
+			return crl, nil
+		}
+
+		lastErr = err
+		r.log.Warn("PCK CRL retrieval failed, trying next endpoint",
+			zap.String("url", baseURL),
+			zap.String("endpointType", endpointType),
+			zap.Error(err))
+	}
+
+	return nil, lastErr
+}
+
+// retrievePCKCRLFromEndpoint attempts PCK CRL retrieval from a single endpoint
+func (r *IntelService) retrievePCKCRLFromEndpoint(ctx context.Context, requestURL string, issuer *x509.Certificate) (*x509.RevocationList, error) {
+	resp, err := r.getFromEndpoint(ctx, requestURL, maxCRLResponseSize)
+	if err != nil {
+		return nil, err
+	}
+	crl, err := pckcert.ParseCRL(resp.body)
+	if err != nil {
+		return nil, err
+	}
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		return nil, fmt.Errorf("CRL is not signed by %s: %w", issuer.Subject.CommonName, err)
+	}
+	return crl, nil
+}
+
+// RetrieveTCBInfo retrieves the TCB Info of the FMSPC
+// It tries each endpoint in order (PCCS first, then Intel) until one returns a TCB Info of the platform
+// signed by a certificate issued by the SGX root CA
+func (r *IntelService) RetrieveTCBInfo(ctx context.Context, fmspc, pceid string) (*tcbinfo.TCBInfo, error) {
+	var lastErr error
+
+	for _, i := range r.fallbackOrder() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		baseURL := r.endpoints.tcbInfoURLs[i]
+		endpointType := "intel"
+		if i < len(r.endpoints.pccsURLs) {
+			endpointType = "pccs"
+		}
+		requestURL := fmt.Sprintf("%s?fmspc=%s", baseURL, fmspc)
+
+		tcbInfo, _, err := r.retrieveTCBInfoFromEndpoint(ctx, requestURL, fmspc, pceid)
+		if err == nil {
+			if i < len(r.endpoints.pccsURLs) {
+				r.health.succeeded(r.endpoints.pccsURLs[i])
+			}
+			r.log.Debug("TCB Info retrieval successful",
+				zap.String("url", baseURL),
+				zap.String("endpointType", endpointType),
+				zap.String("fmspc", fmspc),
+				zap.Int("tcbEvaluationDataNumber", tcbInfo.TCBEvaluationDataNumber))
+			return tcbInfo, nil
+		}
+
+		lastErr = err
+		r.log.Warn("TCB Info retrieval failed, trying next endpoint",
+			zap.String("url", baseURL),
+			zap.String("endpointType", endpointType),
+			zap.Error(err))
+	}
+
+	return nil, lastErr
+}
+
+// retrieveTCBInfoFromEndpoint attempts TCB Info retrieval from a single endpoint. The response is returned
+// along with the TCB Info for the upload to the PCCS.
+func (r *IntelService) retrieveTCBInfoFromEndpoint(ctx context.Context, requestURL, fmspc, pceid string) (*tcbinfo.TCBInfo, *collateralResponse, error) {
+	resp, err := r.getFromEndpoint(ctx, requestURL, maxTCBInfoResponseSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	response, err := tcbinfo.ParseResponse(resp.body, resp.header)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := response.Verify(r.sgxRootCA, time.Now()); err != nil {
+		return nil, nil, err
+	}
+	if !response.TCBInfo.MatchesPlatform(fmspc, pceid) {
+		return nil, nil, fmt.Errorf("TCB Info of FMSPC %s and PCEID %s does not match the queried %s and %s",
+			response.TCBInfo.FMSPC, response.TCBInfo.PCEID, fmspc, pceid)
+	}
+	return &response.TCBInfo, resp, nil
+}
+
+// collateralResponse is the body of a successful collateral response along with its headers
+type collateralResponse struct {
+	body   []byte
+	header http.Header
+}
+
+// getFromEndpoint requests a collateral from a single endpoint and reads the body of a successful response
+// up to maxSize bytes
+func (r *IntelService) getFromEndpoint(ctx context.Context, requestURL string, maxSize int64) (*collateralResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := r.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d (Error-Code: %s)", resp.StatusCode, resp.Header.Get("Error-Code"))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return &collateralResponse{body: body, header: resp.Header}, nil
 }
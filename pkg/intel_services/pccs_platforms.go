@@ -0,0 +1,104 @@
+package intelservices
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
+	"go.uber.org/zap"
+)
+
+// pccsPlatformsPath is the PCCS user endpoint adding a platform, whose PCK certificates the PCCS then
+// fetches from Intel and caches. It follows the certification path of the PCS API version.
+const pccsPlatformsPath = "/platforms"
+
+// PCCSPlatform is the platform added to a PCCS, e.g. one in REQ mode that only serves the platforms
+// added explicitly
+type PCCSPlatform struct {
+	EncryptedPPID string `json:"enc_ppid"`
+	CPUSVN        string `json:"cpu_svn"`
+	PCESVN        string `json:"pce_svn"`
+	PCEID         string `json:"pce_id"`
+	QEID          string `json:"qe_id"`
+	// PlatformManifest is only sent for multi-package platforms
+	PlatformManifest string `json:"platform_manifest,omitempty"`
+}
+
+// NewPCCSPlatform returns the platform to add to a PCCS, with the platform manifest of multi-package platforms
+func NewPCCSPlatform(platformInfo *sgxplatforminfo.SgxPlatformInfo, platformManifest []byte) PCCSPlatform {
+	return PCCSPlatform{
+		EncryptedPPID:    platformInfo.EncryptedPPID,
+		CPUSVN:           platformInfo.CpuSvn,
+		PCESVN:           platformInfo.PCEInfo.PCEisvsvn,
+		PCEID:            platformInfo.PCEInfo.PCEID,
+		QEID:             platformInfo.QeId,
+		PlatformManifest: hex.EncodeToString(platformManifest),
+	}
+}
+
+// PCCSResult is the outcome of a request sending the platform data to a PCCS
+type PCCSResult struct {
+	PCCSURL string
+	Err     error
+}
+
+// AddPlatformToPCCS adds the platform to each PCCS for which pending returns true, authenticated with
+// the PCCS user token
+func (r *IntelService) AddPlatformToPCCS(ctx context.Context, platform PCCSPlatform, pending func(pccsURL string) bool) []PCCSResult {
+	body, err := json.Marshal(platform)
+	if err != nil {
+		r.log.Error("Unable to encode the platform", zap.Error(err))
+		return nil
+	}
+
+	var results []PCCSResult
+	for _, baseURL := range r.endpoints.pccsURLs {
+		if ctx.Err() != nil {
+			break
+		}
+		if !pending(baseURL) {
+			continue
+		}
+		err := r.addPlatformToEndpoint(ctx, baseURL+r.endpoints.certificationPath+pccsPlatformsPath, body)
+		if err != nil {
+			r.log.Warn("Adding the platform to the PCCS failed",
+				zap.String("url", baseURL),
+				zap.Error(err))
+		} else {
+			r.log.Info("Platform added to the PCCS",
+				zap.String("url", baseURL),
+				zap.Bool("platformManifest", platform.PlatformManifest != ""))
+		}
+		results = append(results, PCCSResult{PCCSURL: baseURL, Err: err})
+	}
+	return results
+}
+
+// addPlatformToEndpoint adds the platform to a single PCCS
+func (r *IntelService) addPlatformToEndpoint(ctx context.Context, requestURL string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := r.credentials.authenticate(req, pccsUserEndpoint); err != nil {
+		return fmt.Errorf("failed to authenticate request: %w", err)
+	}
+
+	resp, err := r.do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxPCKResponseSize))
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected status code %d (Error-Code: %s)", resp.StatusCode, resp.Header.Get("Error-Code"))
+	}
+	return nil
+}
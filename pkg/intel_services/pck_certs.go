@@ -0,0 +1,132 @@
+package intelservices
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	pckcert "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/pck_cert"
+	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
+	tcbinfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/tcb_info"
+	"go.uber.org/zap"
+)
+
+// SubscriptionKeyHeader carries the Intel PCS API subscription key
+const SubscriptionKeyHeader = "Ocp-Apim-Subscription-Key"
+
+// maxPCKCertsResponseSize bounds the size of a /pckcerts response body
+const maxPCKCertsResponseSize = 1024 * 1024
+
+// Methods of the /pckcerts requests
+const (
+	PCKCertsByEncryptedPPID    = "encrypted_ppid"
+	PCKCertsByPlatformManifest = "platform_manifest"
+)
+
+// ErrAPIKeyMissing is returned when the Intel PCS API subscription key needed by /pckcerts is not configured
+var ErrAPIKeyMissing = errors.New("Intel PCS API subscription key not configured")
+
+// PCKCerts holds the PCK certificates of all the TCB levels of a platform
+type PCKCerts struct {
+	Certificates []*pckcert.Certificate
+	// Selected is the certificate to use at the raw TCB of the platform
+	Selected *pckcert.Certificate
+	// Method is how the certificates were requested, by encrypted PPID or platform manifest
+	Method string
+	// Raw is the /pckcerts response body, with the TCB components of each TCB level
+	Raw json.RawMessage
+	// IssuerChain is the URL-encoded PEM issuer chain of the certificates, as returned by Intel
+	IssuerChain string
+}
+
+// RetrievePCKCerts retrieves the PCK certificates of all the TCB levels of the platform from Intel
+// and selects the one to use at its raw TCB, ranked by the TCB levels of tcbInfo when it is not nil.
+// Without platform manifest, the certificates are requested by encrypted PPID, which requires the platform
+// keys to be cached by Intel; multi-package platforms send their platform manifest instead.
+func (r *IntelService) RetrievePCKCerts(ctx context.Context, platformInfo *sgxplatforminfo.SgxPlatformInfo, platformManifest []byte, tcbInfo *tcbinfo.TCBInfo) (*PCKCerts, error) {
+	if r.credentials.intelAPIKey == nil {
+		return nil, ErrAPIKeyMissing
+	}
+	platform, err := queriedPlatform(platformInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	var req *http.Request
+	method := PCKCertsByEncryptedPPID
+	if len(platformManifest) == 0 {
+		requestURL := fmt.Sprintf("%s?encrypted_ppid=%s&pceid=%s", r.endpoints.pckCertsURL, platformInfo.EncryptedPPID, platformInfo.PCEInfo.PCEID)
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, requestURL, http.NoBody)
+	} else {
+		method = PCKCertsByPlatformManifest
+		var body []byte
+		body, err = json.Marshal(struct {
+			PlatformManifest string `json:"platformManifest"`
+			PCEID            string `json:"pceid"`
+		}{hex.EncodeToString(platformManifest), platformInfo.PCEInfo.PCEID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, r.endpoints.pckCertsURL, bytes.NewReader(body))
+		if req != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := r.credentials.authenticate(req, intelPCKEndpoint); err != nil {
+		return nil, fmt.Errorf("failed to authenticate request: %w", err)
+	}
+
+	resp, err := r.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d (Error-Code: %s)", resp.StatusCode, resp.Header.Get("Error-Code"))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPCKCertsResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PCK certificates response: %w", err)
+	}
+	certs, err := pckcert.ParseCertsResponse(body, resp.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	// every certificate is verified, not only the selected one, since the response is uploaded as is
+	// to the OFFLINE PCCS
+	now := time.Now()
+	if err := pckcert.VerifyCerts(certs, r.sgxRootCA, platform.PCEID, now); err != nil {
+		return nil, err
+	}
+	selected, err := tcbinfo.SelectPCK(certs, platform, tcbInfo)
+	if err != nil {
+		return nil, err
+	}
+	if err := selected.Verify(r.sgxRootCA, platform, now); err != nil {
+		return nil, err
+	}
+
+	r.log.Debug("PCK certificates retrieval successful",
+		zap.String("method", method),
+		zap.Int("certificates", len(certs)),
+		zap.String("selectedTcbm", selected.TCBm))
+	return &PCKCerts{
+		Certificates: certs,
+		Selected:     selected,
+		Method:       method,
+		Raw:          body,
+		IssuerChain:  resp.Header.Get(pckcert.IssuerChainHeader),
+	}, nil
+}
@@ -0,0 +1,201 @@
+package intelservices
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	enclaveidentity "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/enclave_identity"
+	pckcert "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/pck_cert"
+	tcbinfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/tcb_info"
+
+	"go.uber.org/zap"
+)
+
+// PlatformCollateral is the body of the PCCS PUT /platformcollateral request, which fills a PCCS in
+// OFFLINE mode with the PCK certificates of the platform and the collateral to verify its quotes, all
+// retrieved from Intel
+type PlatformCollateral struct {
+	Platforms   []PCCSPlatform            `json:"platforms"`
+	Collaterals PlatformCollateralContent `json:"collaterals"`
+}
+
+// PlatformCollateralContent holds the PCK certificates, the collateral and their issuer chains
+type PlatformCollateralContent struct {
+	PCKCerts []PlatformPCKCerts `json:"pck_certs"`
+	TCBInfos []PlatformTCBInfo  `json:"tcbinfos"`
+	PCKCACRL PCKCACRL           `json:"pckcacrl"`
+	// QEIdentity and QvEIdentity are the enclave identity response bodies
+	QEIdentity  string `json:"qeidentity"`
+	QvEIdentity string `json:"qveidentity"`
+	// RootCACRL is the hex encoded DER CRL of the root CA
+	RootCACRL    string       `json:"rootcacrl"`
+	Certificates IssuerChains `json:"certificates"`
+}
+
+// PlatformPCKCerts holds the PCK certificates of all the TCB levels of a platform, as returned by /pckcerts
+type PlatformPCKCerts struct {
+	QEID  string          `json:"qe_id"`
+	PCEID string          `json:"pce_id"`
+	Certs json.RawMessage `json:"certs"`
+}
+
+// PlatformTCBInfo is the TCB Info response body of an FMSPC
+type PlatformTCBInfo struct {
+	FMSPC      string          `json:"fmspc"`
+	SGXTCBInfo json.RawMessage `json:"sgx_tcbinfo"`
+}
+
+// PCKCACRL holds the hex encoded DER CRLs of the PCK Processor and Platform CA
+type PCKCACRL struct {
+	ProcessorCRL string `json:"processorCrl,omitempty"`
+	PlatformCRL  string `json:"platformCrl,omitempty"`
+}
+
+// IssuerChains holds the URL-encoded issuer chains, as the PCCS returns them in the headers of its responses
+type IssuerChains struct {
+	// PCKCertificate maps the CA type to the issuer chain of the PCK certificates
+	PCKCertificate  map[string]string `json:"sgx-pck-certificate-issuer-chain"`
+	TCBInfo         string            `json:"sgx-tcb-info-issuer-chain"`
+	EnclaveIdentity string            `json:"sgx-enclave-identity-issuer-chain"`
+}
+
+// RetrievePlatformCollateral returns the platform collateral holding the PCK certificates retrieved from
+// Intel along with the TCB Info of their FMSPC, the CRL of their CA, the QE and QvE identities and the
+// root CA CRL, which are retrieved from Intel since the PCCS to fill cannot serve them.
+// Each collateral is verified against the SGX root CA before it is uploaded as returned by Intel.
+func (r *IntelService) RetrievePlatformCollateral(ctx context.Context, platform PCCSPlatform, pckCerts *PCKCerts) (*PlatformCollateral, error) {
+	selected := pckCerts.Selected
+	// Intel is the last endpoint of the PCCS fallback lists
+	intel := len(r.endpoints.pccsURLs)
+
+	tcbInfoURL := fmt.Sprintf("%s?fmspc=%s", r.endpoints.tcbInfoURLs[intel], selected.FMSPC)
+	_, tcbInfo, err := r.retrieveTCBInfoFromEndpoint(ctx, tcbInfoURL, selected.FMSPC, platform.PCEID)
+	if err != nil {
+		return nil, fmt.Errorf("TCB Info: %w", err)
+	}
+
+	pckCRLURL := fmt.Sprintf("%s?ca=%s", r.endpoints.pckCRLURLs[intel], selected.CAType)
+	pckCRL, err := r.retrievePCKCRLFromEndpoint(ctx, pckCRLURL, selected.Issuer())
+	if err != nil {
+		return nil, fmt.Errorf("PCK CRL: %w", err)
+	}
+	var pckCACRL PCKCACRL
+	if selected.CAType == pckcert.CATypePlatform {
+		pckCACRL.PlatformCRL = hex.EncodeToString(pckCRL.Raw)
+	} else {
+		pckCACRL.ProcessorCRL = hex.EncodeToString(pckCRL.Raw)
+	}
+
+	qeIdentity, err := r.retrieveEnclaveIdentityFromEndpoint(ctx, r.endpoints.qeIdentityURL, enclaveidentity.IDQE)
+	if err != nil {
+		return nil, fmt.Errorf("QE identity: %w", err)
+	}
+	qveIdentity, err := r.retrieveEnclaveIdentityFromEndpoint(ctx, r.endpoints.qveIdentityURL, enclaveidentity.IDQVE)
+	if err != nil {
+		return nil, fmt.Errorf("QvE identity: %w", err)
+	}
+
+	rootCACRL, err := r.retrievePCKCRLFromEndpoint(ctx, r.endpoints.rootCACRLURL, r.sgxRootCA)
+	if err != nil {
+		return nil, fmt.Errorf("root CA CRL: %w", err)
+	}
+
+	return &PlatformCollateral{
+		Platforms: []PCCSPlatform{platform},
+		Collaterals: PlatformCollateralContent{
+			PCKCerts:    []PlatformPCKCerts{{QEID: platform.QEID, PCEID: platform.PCEID, Certs: pckCerts.Raw}},
+			TCBInfos:    []PlatformTCBInfo{{FMSPC: strings.ToUpper(selected.FMSPC), SGXTCBInfo: tcbInfo.body}},
+			PCKCACRL:    pckCACRL,
+			QEIdentity:  string(qeIdentity.body),
+			QvEIdentity: string(qveIdentity.body),
+			RootCACRL:   hex.EncodeToString(rootCACRL.Raw),
+			Certificates: IssuerChains{
+				PCKCertificate:  map[string]string{strings.ToUpper(selected.CAType): pckCerts.IssuerChain},
+				TCBInfo:         tcbInfo.header.Get(tcbinfo.IssuerChainHeader),
+				EnclaveIdentity: qeIdentity.header.Get(enclaveidentity.IssuerChainHeader),
+			},
+		},
+	}, nil
+}
+
+// retrieveEnclaveIdentityFromEndpoint attempts the retrieval of the QE or QvE identity from a single endpoint
+func (r *IntelService) retrieveEnclaveIdentityFromEndpoint(ctx context.Context, requestURL, id string) (*collateralResponse, error) {
+	resp, err := r.getFromEndpoint(ctx, requestURL, maxEnclaveIdentityResponseSize)
+	if err != nil {
+		return nil, err
+	}
+	response, err := enclaveidentity.ParseResponse(resp.body, resp.header)
+	if err != nil {
+		return nil, err
+	}
+	if err := response.Verify(r.sgxRootCA, time.Now()); err != nil {
+		return nil, err
+	}
+	if response.EnclaveIdentity.ID != id {
+		return nil, fmt.Errorf("enclave identity %s does not match the queried %s", response.EnclaveIdentity.ID, id)
+	}
+	return resp, nil
+}
+
+// UploadPlatformCollateral uploads the platform collateral to each PCCS for which pending returns true,
+// authenticated with the PCCS admin token
+func (r *IntelService) UploadPlatformCollateral(ctx context.Context, collateral *PlatformCollateral, pending func(pccsURL string) bool) []PCCSResult {
+	body, err := json.Marshal(collateral)
+	if err != nil {
+		r.log.Error("Unable to encode the platform collateral", zap.Error(err))
+		return nil
+	}
+
+	var results []PCCSResult
+	for i, baseURL := range r.endpoints.pccsURLs {
+		if ctx.Err() != nil {
+			break
+		}
+		if !pending(baseURL) {
+			continue
+		}
+		err := r.uploadPlatformCollateralToEndpoint(ctx, r.endpoints.platformCollateralURLs[i], body)
+		if err != nil {
+			r.log.Warn("Platform collateral upload to the PCCS failed",
+				zap.String("url", baseURL),
+				zap.Error(err))
+		} else {
+			r.log.Info("Platform collateral uploaded to the PCCS",
+				zap.String("url", baseURL),
+				zap.Int("size", len(body)))
+		}
+		results = append(results, PCCSResult{PCCSURL: baseURL, Err: err})
+	}
+	return results
+}
+
+// uploadPlatformCollateralToEndpoint uploads the platform collateral to a single PCCS
+func (r *IntelService) uploadPlatformCollateralToEndpoint(ctx context.Context, requestURL string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := r.credentials.authenticate(req, pccsAdminEndpoint); err != nil {
+		return fmt.Errorf("failed to authenticate request: %w", err)
+	}
+
+	resp, err := r.do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxPCKResponseSize))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d (Error-Code: %s)", resp.StatusCode, resp.Header.Get("Error-Code"))
+	}
+	return nil
+}
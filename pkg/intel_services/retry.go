@@ -0,0 +1,204 @@
+package intelservices
+
+import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/config"
+	"go.uber.org/zap"
+)
+
+// retryReasonError is the retry reason of the requests failing without response
+const retryReasonError = "error"
+
+// retryRule tells how the responses of a status code are retried
+type retryRule struct {
+	// idempotentOnly limits the retries to the requests that may be sent twice, as the server may have
+	// processed the request before failing
+	idempotentOnly bool
+	// retryAfter waits for the delay of the Retry-After header of the response, when it has one
+	retryAfter bool
+}
+
+// retryRules are the rules of the retried status codes. Rate limited and unavailable responses are sent
+// before the request is processed, so every request is retried; the other server errors only when idempotent.
+var retryRules = map[int]retryRule{
+	http.StatusTooManyRequests:     {retryAfter: true},
+	http.StatusServiceUnavailable:  {retryAfter: true},
+	http.StatusInternalServerError: {idempotentOnly: true},
+	http.StatusBadGateway:          {idempotentOnly: true},
+	http.StatusGatewayTimeout:      {idempotentOnly: true},
+}
+
+// transportErrorRule is the rule of the requests failing without response, e.g. on a reset connection
+var transportErrorRule = retryRule{idempotentOnly: true}
+
+// retryPolicy bounds the attempts of each request and the exponential backoff between them
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+func newRetryPolicy(cfg *config.RegistrationServiceConfig) retryPolicy {
+	return retryPolicy{
+		maxAttempts:    max(cfg.RetryMaxAttempts, 1),
+		initialBackoff: cfg.RetryInitialBackoff,
+		maxBackoff:     max(cfg.RetryMaxBackoff, cfg.RetryInitialBackoff),
+	}
+}
+
+// backoff returns the wait after a failed attempt: the initial backoff doubled on each attempt up to the
+// maximum backoff, whose upper half is random so that the nodes do not retry in lockstep
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	backoff := p.initialBackoff
+	for i := 1; i < attempt && backoff < p.maxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, p.maxBackoff)
+	if backoff < 2 {
+		return backoff
+	}
+	return backoff/2 + rand.N(backoff/2)
+}
+
+// do sends the request with retries, see send, and records the outcome of the last attempt of a PCCS
+// request in the health of the PCCS
+func (r *IntelService) do(req *http.Request) (*http.Response, error) {
+	resp, err := r.send(req)
+	r.recordOutcome(req, resp, err)
+	return resp, err
+}
+
+// send sends the request and retries it according to the retry rules and policy. The response or error of
+// the last attempt is returned. A retry is not attempted when its wait would exceed the deadline of the
+// request context, e.g. the check timeout, nor when the server asks to wait longer than the maximum
+// backoff, as there may be no deadline.
+func (r *IntelService) send(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 {
+			attemptReq = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+		resp, err := r.httpClient.Do(attemptReq)
+
+		rule, reason, retryable := retryRuleOf(req, resp, err)
+		if !retryable || attempt >= r.retry.maxAttempts {
+			return resp, err
+		}
+
+		wait := r.retry.backoff(attempt)
+		if rule.retryAfter {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				wait = retryAfter
+			}
+		}
+		if wait > r.retry.maxBackoff {
+			r.log.Warn("Not retrying request, the Retry-After delay exceeds the maximum backoff",
+				zap.String("method", req.Method),
+				zap.String("url", requestEndpoint(req)),
+				zap.Int("attempt", attempt),
+				zap.String("reason", reason),
+				zap.Duration("wait", wait),
+				zap.Duration("maxBackoff", r.retry.maxBackoff))
+			return resp, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wait {
+			r.log.Warn("Not retrying request, the wait exceeds the check deadline",
+				zap.String("method", req.Method),
+				zap.String("url", requestEndpoint(req)),
+				zap.Int("attempt", attempt),
+				zap.String("reason", reason),
+				zap.Duration("wait", wait))
+			return resp, err
+		}
+
+		// the response is replaced by the one of the retry
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxPCKResponseSize))
+			resp.Body.Close()
+		}
+		r.log.Warn("Retrying request",
+			zap.String("method", req.Method),
+			zap.String("url", requestEndpoint(req)),
+			zap.Int("attempt", attempt),
+			zap.String("reason", reason),
+			zap.Duration("wait", wait),
+			zap.Error(err))
+		r.metrics.RecordRequestRetry(req.URL.Host, reason, wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryRuleOf returns the retry rule of an attempt and its reason, the status code or "error", and whether
+// the request may be retried
+func retryRuleOf(req *http.Request, resp *http.Response, err error) (retryRule, string, bool) {
+	var rule retryRule
+	var reason string
+	switch {
+	case err != nil:
+		// a cancelled or timed out check is not retried
+		if req.Context().Err() != nil {
+			return retryRule{}, "", false
+		}
+		rule, reason = transportErrorRule, retryReasonError
+	default:
+		var ok bool
+		if rule, ok = retryRules[resp.StatusCode]; !ok {
+			return retryRule{}, "", false
+		}
+		reason = strconv.Itoa(resp.StatusCode)
+	}
+
+	if rule.idempotentOnly && !isIdempotent(req) {
+		return retryRule{}, "", false
+	}
+	// a body that cannot be read again cannot be sent again
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return retryRule{}, "", false
+	}
+	return rule, reason, true
+}
+
+// isIdempotent reports whether sending the request twice has the same effect as sending it once
+func isIdempotent(req *http.Request) bool {
+	return req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodPut
+}
+
+// parseRetryAfter parses the Retry-After header, either a number of seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(date.Sub(now), 0), true
+}
+
+// requestEndpoint returns the URL of the request without its query, which identifies the platform
+func requestEndpoint(req *http.Request) string {
+	return req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+}
@@ -2,6 +2,8 @@ package metrics
 
 import (
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -12,10 +14,62 @@ const (
 	// metrics definitions
 	RegistrationServiceStatusCodeMetricValue  = "service_status_code"
 	RegistrationServicePanicCountsMetricValue = "application_panics_total"
+	RegistrationCompleteMetricValue           = "sgx_registration_complete"
+	PackageInfoCompleteMetricValue            = "sgx_package_info_complete"
+	PackageCountMetricValue                   = "sgx_package_count"
+	PackageKeyConsumedMetricValue             = "sgx_package_key_consumed"
+	SgxBiosStateMetricValue                   = "sgx_bios_state"
+	SgxEpcSizeMetricValue                     = "sgx_epc_size_bytes"
+	SgxPrmrrSizeMetricValue                   = "sgx_prmrr_size_bytes"
+	RegistrationModeMetricValue               = "sgx_registration_mode"
+	RegistrationURLMismatchMetricValue        = "sgx_registration_url_mismatch"
+	PCKCertificateInfoMetricValue             = "sgx_pck_certificate_info"
+	PCKCertificateExpiryMetricValue           = "sgx_pck_certificate_expiry_timestamp_seconds"
+	PCKCertificateRevokedMetricValue          = "sgx_pck_certificate_revoked"
+	PCKCertificatesCountMetricValue           = "sgx_pck_certificates_count"
+	TCBStatusMetricValue                      = "sgx_tcb_status"
+	TCBAdvisoryMetricValue                    = "sgx_tcb_advisory"
+	TCBEvaluationDataNumberMetricValue        = "sgx_tcb_evaluation_data_number"
+	CollateralPrefetchMetricValue             = "sgx_pccs_collateral_prefetch_success"
+	PCCSPlatformAddedMetricValue              = "sgx_pccs_platform_added"
+	PCCSUploadMetricValue                     = "sgx_pccs_pck_certificates_uploaded"
+	PCCSConsistencyMetricValue                = "sgx_pccs_pck_certificate_consistent"
+	RequestRetriesMetricValue                 = "http_request_retries_total"
+	RequestRetryWaitMetricValue               = "http_request_retry_wait_seconds_total"
+	PCCSBreakerStateMetricValue               = "sgx_pccs_breaker_state"
 
 	// label definitions
 	HttpStatusCodeLabel = "http_status_code"
 	IntelErrorCodeLabel = "intel_error_code"
+	PackageLabel        = "package"
+	StateLabel          = "state"
+	SizeKindLabel       = "kind"
+	ModeLabel           = "mode"
+	FMSPCLabel          = "fmspc"
+	CATypeLabel         = "ca_type"
+	TCBStatusLabel      = "status"
+	AdvisoryIDLabel     = "advisory_id"
+	PCCSLabel           = "pccs"
+	CollateralLabel     = "collateral"
+	EndpointLabel       = "endpoint"
+	ReasonLabel         = "reason"
+
+	// SGX BIOS states reported by the sgx_bios_state metric
+	SgxBiosStateEnabled            = "enabled"
+	SgxBiosStateDisabled           = "disabled"
+	SgxBiosStateSoftwareControlled = "software_controlled"
+	SgxBiosStateUnknown            = "unknown"
+	SgxBiosStateEfivarsUnavailable = "efivars_unavailable"
+
+	// registration modes reported by the sgx_registration_mode metric
+	RegistrationModeDirect   = "direct"
+	RegistrationModeIndirect = "indirect"
+	RegistrationModeUnknown  = "unknown"
+
+	// kinds of EPC/PRMRR sizes
+	SizeKindConfigured = "configured"
+	SizeKindMax        = "max"
+	SizeKindRequested  = "requested"
 )
 
 // Define a custom type for status codes
@@ -33,21 +87,30 @@ const (
 	SgxResetNeeded               StatusCode = 3
 	UefiPersistFailed            StatusCode = 4
 	PlatformRebootNeeded         StatusCode = 5
+	PackageAddedRebootNeeded     StatusCode = 6
+	TcbRecoveryPending           StatusCode = 7
+	InvalidPlatformManifest      StatusCode = 8
 	PlatformDirectlyRegistered   StatusCode = 9
 	IntelConnectFailed           StatusCode = 10
 	InvalidRegistrationRequest   StatusCode = 11
 	IntelRegServiceRequestFailed StatusCode = 12
+	InvalidAddPackageRequest     StatusCode = 13
+	IntelAddPackageRequestFailed StatusCode = 14
+	CheckCancelled               StatusCode = 15
+	CheckTimedOut                StatusCode = 16
+	InvalidPCKCertificate        StatusCode = 20
+	PCKCertificateRevoked        StatusCode = 21
 	UnknownError                 StatusCode = 99
 )
 
 func (s StatusCode) GetDetails() StatusCodeDetails {
 	switch s {
-	case InvalidRegistrationRequest:
+	case InvalidRegistrationRequest, InvalidAddPackageRequest:
 		return StatusCodeDetails{
 			RequiresHTTPStatusCode: true,
 			RequiresIntelErrCode:   true,
 		}
-	case IntelRegServiceRequestFailed, SgxResetNeeded:
+	case IntelRegServiceRequestFailed, SgxResetNeeded, IntelAddPackageRequestFailed:
 		return StatusCodeDetails{
 			RequiresHTTPStatusCode: true,
 			RequiresIntelErrCode:   false,
@@ -78,6 +141,12 @@ func (s StatusCode) String() string {
 		return "SgxResetNeeded: impossible to determine the registration status; please reset the SGX"
 	case PlatformRebootNeeded:
 		return "PlatformRebootNeeded: platform registered successfully and a reboot is required"
+	case PackageAddedRebootNeeded:
+		return "PackageAddedRebootNeeded: package added successfully and a reboot is required"
+	case TcbRecoveryPending:
+		return "TcbRecoveryPending: TCB recovery platform manifest registered and a reboot is required"
+	case InvalidPlatformManifest:
+		return "InvalidPlatformManifest: the pending UEFI request is not a valid platform manifest"
 	case UefiPersistFailed:
 		return "UefiPersistFailed: failed to persist the UEFI variable content"
 	case PlatformDirectlyRegistered:
@@ -88,6 +157,18 @@ func (s StatusCode) String() string {
 		return "InvalidRegistrationRequest: invalid registration request"
 	case IntelRegServiceRequestFailed:
 		return "IntelRegServiceRequestFailed: intel RS could not process the request"
+	case InvalidAddPackageRequest:
+		return "InvalidAddPackageRequest: invalid add package request"
+	case IntelAddPackageRequestFailed:
+		return "IntelAddPackageRequestFailed: intel RS could not process the add package request"
+	case CheckCancelled:
+		return "CheckCancelled: the registration check was cancelled before completion, e.g. on shutdown"
+	case CheckTimedOut:
+		return "CheckTimedOut: the registration check did not complete within the check timeout"
+	case InvalidPCKCertificate:
+		return "InvalidPCKCertificate: the retrieved PCK certificate failed verification"
+	case PCKCertificateRevoked:
+		return "PCKCertificateRevoked: the retrieved PCK certificate is revoked"
 	default:
 		return "UnknownError"
 	}
@@ -129,8 +210,192 @@ var (
 		Name: RegistrationServicePanicCountsMetricValue,
 		Help: "Total number of go routines panics",
 	})
+
+	RegistrationCompleteMetric = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: RegistrationCompleteMetricValue,
+		Help: "Registration status bit of the SgxRegistrationStatus UEFI variable (1 when complete)",
+	})
+
+	PackageInfoCompleteMetric = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: PackageInfoCompleteMetricValue,
+		Help: "Package info status bit of the SgxRegistrationStatus UEFI variable (1 when the package info is consumed)",
+	})
+
+	PackageCountMetric = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: PackageCountMetricValue,
+		Help: "Number of CPU packages found in the SgxRegistrationPackageInfo UEFI variable",
+	})
+
+	SgxBiosStateMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: SgxBiosStateMetricValue,
+			Help: "SGX setting of the BIOS read from the SOFTWAREGUARDSTATUS UEFI variable (1 for the current state)",
+		},
+		[]string{StateLabel},
+	)
+
+	SgxEpcSizeMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: SgxEpcSizeMetricValue,
+			Help: "EPC size configured by the BIOS, supported by the platform and requested by the OS",
+		},
+		[]string{SizeKindLabel},
+	)
+
+	SgxPrmrrSizeMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: SgxPrmrrSizeMetricValue,
+			Help: "PRMRR size configured by the BIOS and requested by the OS",
+		},
+		[]string{SizeKindLabel},
+	)
+
+	RegistrationModeMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: RegistrationModeMetricValue,
+			Help: "Registration mode set up in the SgxRegistrationConfiguration UEFI variable (1 for the current mode)",
+		},
+		[]string{ModeLabel},
+	)
+
+	RegistrationURLMismatchMetric = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: RegistrationURLMismatchMetricValue,
+		Help: "1 when the registration server of the SgxRegistrationConfiguration UEFI variable differs from the configured one",
+	})
+
+	PCKCertificateInfoMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: PCKCertificateInfoMetricValue,
+			Help: "FMSPC and issuer CA type of the last retrieved PCK certificate (always 1)",
+		},
+		[]string{FMSPCLabel, CATypeLabel},
+	)
+
+	PCKCertificateExpiryMetric = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: PCKCertificateExpiryMetricValue,
+		Help: "Expiry date of the last retrieved PCK certificate as a Unix timestamp",
+	})
+
+	PCKCertificateRevokedMetric = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: PCKCertificateRevokedMetricValue,
+		Help: "1 when the retrieved PCK certificate is listed in the PCK CRL of its issuer",
+	})
+
+	PCKCertificatesCountMetric = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: PCKCertificatesCountMetricValue,
+		Help: "Number of TCB levels Intel issued a PCK certificate of the platform for",
+	})
+
+	TCBStatusMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TCBStatusMetricValue,
+			Help: "TCB status of the platform evaluated against the TCB Info of its FMSPC (always 1)",
+		},
+		[]string{TCBStatusLabel},
+	)
+
+	TCBAdvisoryMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: TCBAdvisoryMetricValue,
+			Help: "INTEL-SA security advisories affecting the TCB level of the platform (always 1)",
+		},
+		[]string{AdvisoryIDLabel},
+	)
+
+	TCBEvaluationDataNumberMetric = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: TCBEvaluationDataNumberMetricValue,
+		Help: "TCB evaluation data number of the TCB Info the platform TCB status was evaluated against",
+	})
+
+	CollateralPrefetchMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: CollateralPrefetchMetricValue,
+			Help: "Outcome of the last attestation collateral prefetch from each PCCS (1 when successful, 0 when failed)",
+		},
+		[]string{PCCSLabel, CollateralLabel},
+	)
+
+	PCCSPlatformAddedMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: PCCSPlatformAddedMetricValue,
+			Help: "Outcome of the last attempt to add the platform to each PCCS (1 when added, 0 when failed)",
+		},
+		[]string{PCCSLabel},
+	)
+
+	PCCSUploadMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: PCCSUploadMetricValue,
+			Help: "Outcome of the last upload of the PCK certificates retrieved from Intel to each PCCS (1 when uploaded, 0 when failed)",
+		},
+		[]string{PCCSLabel},
+	)
+
+	PCCSConsistencyMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: PCCSConsistencyMetricValue,
+			Help: "Whether each PCCS served the same PCK certificate (TCBm and serial number) as Intel on the last comparison (1 when consistent, 0 when diverging or failed)",
+		},
+		[]string{PCCSLabel},
+	)
+
+	RequestRetriesMetric = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: RequestRetriesMetricValue,
+			Help: "Total number of retried requests to Intel and the PCCS, by endpoint host and HTTP status code or error",
+		},
+		[]string{EndpointLabel, ReasonLabel},
+	)
+
+	RequestRetryWaitMetric = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: RequestRetryWaitMetricValue,
+			Help: "Total time waited before retrying the requests to Intel and the PCCS, by endpoint host",
+		},
+		[]string{EndpointLabel},
+	)
+
+	PCCSBreakerStateMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: PCCSBreakerStateMetricValue,
+			Help: "Circuit breaker state of each PCCS (0 when closed, 1 when open and the PCCS is skipped, 2 when half-open and the PCCS is tried again)",
+		},
+		[]string{PCCSLabel},
+	)
+
+	PackageKeyConsumedMetric = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: PackageKeyConsumedMetricValue,
+			Help: "Key blob state of each CPU package (1 when consumed, 0 when pending)",
+		},
+		[]string{PackageLabel},
+	)
 )
 
+var sgxBiosStates = []string{
+	SgxBiosStateEnabled,
+	SgxBiosStateDisabled,
+	SgxBiosStateSoftwareControlled,
+	SgxBiosStateUnknown,
+	SgxBiosStateEfivarsUnavailable,
+}
+
+var registrationModes = []string{
+	RegistrationModeDirect,
+	RegistrationModeIndirect,
+	RegistrationModeUnknown,
+}
+
+// SgxEnablementMetric holds the SGX BIOS state and the EPC/PRMRR sizes in MB
+type SgxEnablementMetric struct {
+	State              string
+	EpcSize            uint32
+	MaxEpcSize         uint32
+	RequestedEpcSize   uint32
+	PrmrrSize          uint32
+	RequestedPrmrrSize uint32
+}
+
 // helper function to service status code to pending
 func IncrementPanicCounts() {
 	RegistrationServicePanicCountsMetric.Inc()
@@ -146,6 +411,113 @@ func (s *RegistrationServiceMetricsRegistry) SetServiceStatusCodeToPending() err
 	return s.UpdateServiceStatusCodeMetric(metricValue)
 }
 
+// UpdateMultiPackageMetrics exports the SgxRegistrationStatus bits and the key state of each CPU package,
+// keysConsumed being indexed by package
+func (s *RegistrationServiceMetricsRegistry) UpdateMultiPackageMetrics(registrationComplete, packageInfoComplete bool, keysConsumed []bool) {
+	RegistrationCompleteMetric.Set(boolToFloat(registrationComplete))
+	PackageInfoCompleteMetric.Set(boolToFloat(packageInfoComplete))
+	PackageCountMetric.Set(float64(len(keysConsumed)))
+
+	PackageKeyConsumedMetric.Reset()
+	for i, consumed := range keysConsumed {
+		PackageKeyConsumedMetric.With(prometheus.Labels{PackageLabel: strconv.Itoa(i)}).Set(boolToFloat(consumed))
+	}
+}
+
+// UpdateSgxEnablementMetrics exports the SGX BIOS state and the EPC/PRMRR sizes
+func (s *RegistrationServiceMetricsRegistry) UpdateSgxEnablementMetrics(metricValue SgxEnablementMetric) {
+	for _, state := range sgxBiosStates {
+		SgxBiosStateMetric.With(prometheus.Labels{StateLabel: state}).Set(boolToFloat(state == metricValue.State))
+	}
+
+	SgxEpcSizeMetric.With(prometheus.Labels{SizeKindLabel: SizeKindConfigured}).Set(megabytesToBytes(metricValue.EpcSize))
+	SgxEpcSizeMetric.With(prometheus.Labels{SizeKindLabel: SizeKindMax}).Set(megabytesToBytes(metricValue.MaxEpcSize))
+	SgxEpcSizeMetric.With(prometheus.Labels{SizeKindLabel: SizeKindRequested}).Set(megabytesToBytes(metricValue.RequestedEpcSize))
+	SgxPrmrrSizeMetric.With(prometheus.Labels{SizeKindLabel: SizeKindConfigured}).Set(megabytesToBytes(metricValue.PrmrrSize))
+	SgxPrmrrSizeMetric.With(prometheus.Labels{SizeKindLabel: SizeKindRequested}).Set(megabytesToBytes(metricValue.RequestedPrmrrSize))
+}
+
+// UpdateRegistrationConfigurationMetrics exports the registration mode and whether the UEFI
+// registration server differs from the configured one
+func (s *RegistrationServiceMetricsRegistry) UpdateRegistrationConfigurationMetrics(mode string, urlMismatch bool) {
+	for _, m := range registrationModes {
+		RegistrationModeMetric.With(prometheus.Labels{ModeLabel: m}).Set(boolToFloat(m == mode))
+	}
+	RegistrationURLMismatchMetric.Set(boolToFloat(urlMismatch))
+}
+
+// UpdatePCKCertificateMetrics exports the FMSPC, issuer CA type and expiry date of the retrieved PCK certificate
+func (s *RegistrationServiceMetricsRegistry) UpdatePCKCertificateMetrics(fmspc, caType string, notAfter time.Time) {
+	PCKCertificateInfoMetric.Reset()
+	PCKCertificateInfoMetric.With(prometheus.Labels{FMSPCLabel: fmspc, CATypeLabel: caType}).Set(1)
+	PCKCertificateExpiryMetric.Set(float64(notAfter.Unix()))
+}
+
+// UpdatePCKCertificateRevokedMetric exports whether the retrieved PCK certificate is revoked
+func (s *RegistrationServiceMetricsRegistry) UpdatePCKCertificateRevokedMetric(revoked bool) {
+	PCKCertificateRevokedMetric.Set(boolToFloat(revoked))
+}
+
+// UpdatePCKCertificatesMetric exports the number of PCK certificates retrieved for all the TCB levels
+func (s *RegistrationServiceMetricsRegistry) UpdatePCKCertificatesMetric(count int) {
+	PCKCertificatesCountMetric.Set(float64(count))
+}
+
+// UpdateTCBMetrics exports the TCB status and security advisories of the platform,
+// evaluated against the TCB Info with the given evaluation data number
+func (s *RegistrationServiceMetricsRegistry) UpdateTCBMetrics(status string, advisoryIDs []string, evaluationDataNumber int) {
+	TCBStatusMetric.Reset()
+	TCBStatusMetric.With(prometheus.Labels{TCBStatusLabel: status}).Set(1)
+
+	TCBAdvisoryMetric.Reset()
+	for _, id := range advisoryIDs {
+		TCBAdvisoryMetric.With(prometheus.Labels{AdvisoryIDLabel: id}).Set(1)
+	}
+	TCBEvaluationDataNumberMetric.Set(float64(evaluationDataNumber))
+}
+
+// UpdateCollateralPrefetchMetric exports whether a collateral was prefetched from a PCCS
+func (s *RegistrationServiceMetricsRegistry) UpdateCollateralPrefetchMetric(pccsURL, collateral string, success bool) {
+	CollateralPrefetchMetric.With(prometheus.Labels{PCCSLabel: pccsURL, CollateralLabel: collateral}).Set(boolToFloat(success))
+}
+
+// UpdatePCCSPlatformMetric exports whether the platform was added to a PCCS
+func (s *RegistrationServiceMetricsRegistry) UpdatePCCSPlatformMetric(pccsURL string, added bool) {
+	PCCSPlatformAddedMetric.With(prometheus.Labels{PCCSLabel: pccsURL}).Set(boolToFloat(added))
+}
+
+// UpdatePCCSUploadMetric exports whether the PCK certificates were uploaded to a PCCS
+func (s *RegistrationServiceMetricsRegistry) UpdatePCCSUploadMetric(pccsURL string, uploaded bool) {
+	PCCSUploadMetric.With(prometheus.Labels{PCCSLabel: pccsURL}).Set(boolToFloat(uploaded))
+}
+
+// UpdatePCCSConsistencyMetric exports whether a PCCS served the same PCK certificate as Intel
+func (s *RegistrationServiceMetricsRegistry) UpdatePCCSConsistencyMetric(pccsURL string, consistent bool) {
+	PCCSConsistencyMetric.With(prometheus.Labels{PCCSLabel: pccsURL}).Set(boolToFloat(consistent))
+}
+
+// RecordRequestRetry counts a retried request and the time waited before it
+func (s *RegistrationServiceMetricsRegistry) RecordRequestRetry(endpoint, reason string, wait time.Duration) {
+	RequestRetriesMetric.With(prometheus.Labels{EndpointLabel: endpoint, ReasonLabel: reason}).Inc()
+	RequestRetryWaitMetric.With(prometheus.Labels{EndpointLabel: endpoint}).Add(wait.Seconds())
+}
+
+// UpdatePCCSBreakerMetric exports the circuit breaker state of a PCCS, 0 closed, 1 open and 2 half-open
+func (s *RegistrationServiceMetricsRegistry) UpdatePCCSBreakerMetric(pccsURL string, state int) {
+	PCCSBreakerStateMetric.With(prometheus.Labels{PCCSLabel: pccsURL}).Set(float64(state))
+}
+
+func megabytesToBytes(size uint32) float64 {
+	return float64(size) * 1024 * 1024
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func (s *RegistrationServiceMetricsRegistry) UpdateServiceStatusCodeMetric(metricValue StatusCodeMetric) error {
 	// Validate required labels
 	statusDetails := metricValue.Status.GetDetails()
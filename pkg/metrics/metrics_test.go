@@ -3,7 +3,9 @@ package metrics
 import (
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -71,6 +73,33 @@ func TestGetDetails(t *testing.T) {
 			},
 			wantedIntValue: 5,
 		},
+		{
+			msg:        "PackageAddedRebootNeeded returns the expected details",
+			statusCode: PackageAddedRebootNeeded,
+			wantedDetails: StatusCodeDetails{
+				RequiresHTTPStatusCode: false,
+				RequiresIntelErrCode:   false,
+			},
+			wantedIntValue: 6,
+		},
+		{
+			msg:        "TcbRecoveryPending returns the expected details",
+			statusCode: TcbRecoveryPending,
+			wantedDetails: StatusCodeDetails{
+				RequiresHTTPStatusCode: false,
+				RequiresIntelErrCode:   false,
+			},
+			wantedIntValue: 7,
+		},
+		{
+			msg:        "InvalidPlatformManifest returns the expected details",
+			statusCode: InvalidPlatformManifest,
+			wantedDetails: StatusCodeDetails{
+				RequiresHTTPStatusCode: false,
+				RequiresIntelErrCode:   false,
+			},
+			wantedIntValue: 8,
+		},
 		{
 			msg:        "PlatformDirectlyRegistered returns the expected details",
 			statusCode: PlatformDirectlyRegistered,
@@ -107,6 +136,60 @@ func TestGetDetails(t *testing.T) {
 			},
 			wantedIntValue: 12,
 		},
+		{
+			msg:        "InvalidAddPackageRequest returns the expected details",
+			statusCode: InvalidAddPackageRequest,
+			wantedDetails: StatusCodeDetails{
+				RequiresHTTPStatusCode: true,
+				RequiresIntelErrCode:   true,
+			},
+			wantedIntValue: 13,
+		},
+		{
+			msg:        "IntelAddPackageRequestFailed returns the expected details",
+			statusCode: IntelAddPackageRequestFailed,
+			wantedDetails: StatusCodeDetails{
+				RequiresHTTPStatusCode: true,
+				RequiresIntelErrCode:   false,
+			},
+			wantedIntValue: 14,
+		},
+		{
+			msg:        "CheckCancelled returns the expected details",
+			statusCode: CheckCancelled,
+			wantedDetails: StatusCodeDetails{
+				RequiresHTTPStatusCode: false,
+				RequiresIntelErrCode:   false,
+			},
+			wantedIntValue: 15,
+		},
+		{
+			msg:        "CheckTimedOut returns the expected details",
+			statusCode: CheckTimedOut,
+			wantedDetails: StatusCodeDetails{
+				RequiresHTTPStatusCode: false,
+				RequiresIntelErrCode:   false,
+			},
+			wantedIntValue: 16,
+		},
+		{
+			msg:        "InvalidPCKCertificate returns the expected details",
+			statusCode: InvalidPCKCertificate,
+			wantedDetails: StatusCodeDetails{
+				RequiresHTTPStatusCode: false,
+				RequiresIntelErrCode:   false,
+			},
+			wantedIntValue: 20,
+		},
+		{
+			msg:        "PCKCertificateRevoked returns the expected details",
+			statusCode: PCKCertificateRevoked,
+			wantedDetails: StatusCodeDetails{
+				RequiresHTTPStatusCode: false,
+				RequiresIntelErrCode:   false,
+			},
+			wantedIntValue: 21,
+		},
 		{
 			msg:        "UnknownError returns the expected details",
 			statusCode: UnknownError,
@@ -161,6 +244,21 @@ func TestGetStatusCodeString(t *testing.T) {
 			statusCode:   PlatformRebootNeeded,
 			wantedString: "PlatformRebootNeeded: platform registered successfully and a reboot is required",
 		},
+		{
+			msg:          "PackageAddedRebootNeeded returns the expected details",
+			statusCode:   PackageAddedRebootNeeded,
+			wantedString: "PackageAddedRebootNeeded: package added successfully and a reboot is required",
+		},
+		{
+			msg:          "TcbRecoveryPending returns the expected details",
+			statusCode:   TcbRecoveryPending,
+			wantedString: "TcbRecoveryPending: TCB recovery platform manifest registered and a reboot is required",
+		},
+		{
+			msg:          "InvalidPlatformManifest returns the expected details",
+			statusCode:   InvalidPlatformManifest,
+			wantedString: "InvalidPlatformManifest: the pending UEFI request is not a valid platform manifest",
+		},
 		{
 			msg:          "PlatformDirectlyRegistered returns the expected details",
 			statusCode:   PlatformDirectlyRegistered,
@@ -181,6 +279,36 @@ func TestGetStatusCodeString(t *testing.T) {
 			statusCode:   IntelRegServiceRequestFailed,
 			wantedString: "IntelRegServiceRequestFailed: intel RS could not process the request",
 		},
+		{
+			msg:          "InvalidAddPackageRequest returns the expected details",
+			statusCode:   InvalidAddPackageRequest,
+			wantedString: "InvalidAddPackageRequest: invalid add package request",
+		},
+		{
+			msg:          "IntelAddPackageRequestFailed returns the expected details",
+			statusCode:   IntelAddPackageRequestFailed,
+			wantedString: "IntelAddPackageRequestFailed: intel RS could not process the add package request",
+		},
+		{
+			msg:          "CheckCancelled returns the expected details",
+			statusCode:   CheckCancelled,
+			wantedString: "CheckCancelled: the registration check was cancelled before completion, e.g. on shutdown",
+		},
+		{
+			msg:          "CheckTimedOut returns the expected details",
+			statusCode:   CheckTimedOut,
+			wantedString: "CheckTimedOut: the registration check did not complete within the check timeout",
+		},
+		{
+			msg:          "InvalidPCKCertificate returns the expected details",
+			statusCode:   InvalidPCKCertificate,
+			wantedString: "InvalidPCKCertificate: the retrieved PCK certificate failed verification",
+		},
+		{
+			msg:          "PCKCertificateRevoked returns the expected details",
+			statusCode:   PCKCertificateRevoked,
+			wantedString: "PCKCertificateRevoked: the retrieved PCK certificate is revoked",
+		},
 		{
 			msg:          "UnknownError returns the expected details",
 			statusCode:   UnknownError,
@@ -331,3 +459,89 @@ func thisLogEntryEqualTo(t testing.TB, this, other observer.LoggedEntry, msg str
 	assert.Equal(t, this.Message, other.Message, msg)
 
 }
+
+func TestUpdateMultiPackageMetrics(t *testing.T) {
+	registry := NewRegistrationServiceMetricsRegistry(zap.NewNop())
+
+	registry.UpdateMultiPackageMetrics(true, false, []bool{false, false, true, false})
+	assert.Equal(t, float64(1), testutil.ToFloat64(RegistrationCompleteMetric))
+	assert.Equal(t, float64(0), testutil.ToFloat64(PackageInfoCompleteMetric))
+	assert.Equal(t, float64(4), testutil.ToFloat64(PackageCountMetric))
+	assert.Equal(t, 4, testutil.CollectAndCount(PackageKeyConsumedMetric))
+	assert.Equal(t, float64(1), testutil.ToFloat64(PackageKeyConsumedMetric.WithLabelValues("2")))
+
+	// packages that disappear are removed from the per-package metric
+	registry.UpdateMultiPackageMetrics(true, true, []bool{true, true})
+	assert.Equal(t, float64(1), testutil.ToFloat64(PackageInfoCompleteMetric))
+	assert.Equal(t, float64(2), testutil.ToFloat64(PackageCountMetric))
+	assert.Equal(t, 2, testutil.CollectAndCount(PackageKeyConsumedMetric))
+}
+
+func TestUpdateSgxEnablementMetrics(t *testing.T) {
+	registry := NewRegistrationServiceMetricsRegistry(zap.NewNop())
+
+	registry.UpdateSgxEnablementMetrics(SgxEnablementMetric{
+		State:            SgxBiosStateSoftwareControlled,
+		EpcSize:          56,
+		MaxEpcSize:       120,
+		RequestedEpcSize: 120,
+		PrmrrSize:        64,
+	})
+	assert.Equal(t, float64(1), testutil.ToFloat64(SgxBiosStateMetric.WithLabelValues(SgxBiosStateSoftwareControlled)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(SgxBiosStateMetric.WithLabelValues(SgxBiosStateEnabled)))
+	assert.Equal(t, float64(56*1024*1024), testutil.ToFloat64(SgxEpcSizeMetric.WithLabelValues(SizeKindConfigured)))
+	assert.Equal(t, float64(120*1024*1024), testutil.ToFloat64(SgxEpcSizeMetric.WithLabelValues(SizeKindRequested)))
+	assert.Equal(t, float64(64*1024*1024), testutil.ToFloat64(SgxPrmrrSizeMetric.WithLabelValues(SizeKindConfigured)))
+
+	registry.UpdateSgxEnablementMetrics(SgxEnablementMetric{State: SgxBiosStateEfivarsUnavailable})
+	assert.Equal(t, float64(0), testutil.ToFloat64(SgxBiosStateMetric.WithLabelValues(SgxBiosStateSoftwareControlled)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(SgxBiosStateMetric.WithLabelValues(SgxBiosStateEfivarsUnavailable)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(SgxEpcSizeMetric.WithLabelValues(SizeKindConfigured)))
+}
+
+func TestUpdateRegistrationConfigurationMetrics(t *testing.T) {
+	registry := NewRegistrationServiceMetricsRegistry(zap.NewNop())
+
+	registry.UpdateRegistrationConfigurationMetrics(RegistrationModeIndirect, true)
+	assert.Equal(t, float64(1), testutil.ToFloat64(RegistrationModeMetric.WithLabelValues(RegistrationModeIndirect)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(RegistrationModeMetric.WithLabelValues(RegistrationModeDirect)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(RegistrationURLMismatchMetric))
+
+	registry.UpdateRegistrationConfigurationMetrics(RegistrationModeDirect, false)
+	assert.Equal(t, float64(0), testutil.ToFloat64(RegistrationModeMetric.WithLabelValues(RegistrationModeIndirect)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(RegistrationModeMetric.WithLabelValues(RegistrationModeDirect)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(RegistrationURLMismatchMetric))
+}
+
+func TestUpdatePCKCertificateMetrics(t *testing.T) {
+	registry := NewRegistrationServiceMetricsRegistry(zap.NewNop())
+	notAfter := time.Date(2032, 5, 21, 10, 0, 0, 0, time.UTC)
+
+	registry.UpdatePCKCertificateMetrics("00906ed50000", "processor", notAfter)
+	registry.UpdatePCKCertificateMetrics("00606a000000", "platform", notAfter)
+	assert.Equal(t, 1, testutil.CollectAndCount(PCKCertificateInfoMetric))
+	assert.Equal(t, float64(1), testutil.ToFloat64(PCKCertificateInfoMetric.WithLabelValues("00606a000000", "platform")))
+	assert.Equal(t, float64(notAfter.Unix()), testutil.ToFloat64(PCKCertificateExpiryMetric))
+}
+
+func TestUpdateTCBMetrics(t *testing.T) {
+	registry := NewRegistrationServiceMetricsRegistry(zap.NewNop())
+
+	registry.UpdateTCBMetrics("OutOfDate", []string{"INTEL-SA-00615", "INTEL-SA-00828"}, 16)
+	registry.UpdateTCBMetrics("SWHardeningNeeded", []string{"INTEL-SA-00615"}, 17)
+	assert.Equal(t, 1, testutil.CollectAndCount(TCBStatusMetric))
+	assert.Equal(t, float64(1), testutil.ToFloat64(TCBStatusMetric.WithLabelValues("SWHardeningNeeded")))
+	assert.Equal(t, 1, testutil.CollectAndCount(TCBAdvisoryMetric))
+	assert.Equal(t, float64(1), testutil.ToFloat64(TCBAdvisoryMetric.WithLabelValues("INTEL-SA-00615")))
+	assert.Equal(t, float64(17), testutil.ToFloat64(TCBEvaluationDataNumberMetric))
+}
+
+func TestUpdateCollateralPrefetchMetric(t *testing.T) {
+	registry := NewRegistrationServiceMetricsRegistry(zap.NewNop())
+
+	registry.UpdateCollateralPrefetchMetric("https://pccs1.example.com", "tcb_info", true)
+	registry.UpdateCollateralPrefetchMetric("https://pccs2.example.com", "tcb_info", true)
+	registry.UpdateCollateralPrefetchMetric("https://pccs2.example.com", "tcb_info", false)
+	assert.Equal(t, float64(1), testutil.ToFloat64(CollateralPrefetchMetric.WithLabelValues("https://pccs1.example.com", "tcb_info")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(CollateralPrefetchMetric.WithLabelValues("https://pccs2.example.com", "tcb_info")))
+}
@@ -0,0 +1,25 @@
+package registration
+
+import (
+	"context"
+
+	pckcert "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/pck_cert"
+	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
+	"go.uber.org/zap"
+)
+
+// prefetchCollateral warms the PCCS caches with the attestation collateral of the platform.
+// Failures are only reported, as the collateral is fetched from Intel again on quote verification.
+func (rc *DefaultRegistrationChecker) prefetchCollateral(ctx context.Context, intelService *intelservices.IntelService, cert *pckcert.Certificate) {
+	failures := 0
+	results := intelService.PrefetchCollateral(ctx, cert.FMSPC, cert.CAType)
+	for _, result := range results {
+		if result.Err != nil {
+			failures++
+		}
+		rc.metricsRegistry.UpdateCollateralPrefetchMetric(result.PCCSURL, result.Collateral, result.Err == nil)
+	}
+	rc.log.Debug("attestation collateral prefetched",
+		zap.Int("requests", len(results)),
+		zap.Int("failures", failures))
+}
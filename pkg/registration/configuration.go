@@ -0,0 +1,188 @@
+package registration
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	mpmanagement "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_management"
+	config "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/config"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/constants"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// RegistrationConfigurationDetails describes the SgxRegistrationConfiguration UEFI variable
+type RegistrationConfigurationDetails struct {
+	// Mode is direct, or indirect when the registration server does not save the platform keys
+	Mode string `json:"mode"`
+	URL  string `json:"url,omitempty"`
+	// URLMismatch reports whether URL differs from the configured registration server
+	URLMismatch bool `json:"urlMismatch"`
+	// URLFollowed reports whether registration requests are sent to URL
+	URLFollowed bool `json:"urlFollowed"`
+	// ProxyType is the proxy type of the ProxyConf, if the variable holds one
+	ProxyType string `json:"proxyType,omitempty"`
+}
+
+// resolveRegistrationConfig reads the SgxRegistrationConfiguration UEFI variable, reports the registration
+// mode and compares its registration server with the configured one. It returns the configuration to use,
+// in which the registration endpoints point to the UEFI registration server when following it is enabled.
+func (rc *DefaultRegistrationChecker) resolveRegistrationConfig() *config.RegistrationServiceConfig {
+	cfg := rc.regServiceConfig
+	details := RegistrationConfigurationDetails{Mode: metrics.RegistrationModeUnknown}
+
+	uefiConfig, err := rc.manifestSource.GetRegistrationConfiguration()
+	switch {
+	case errors.Is(err, mpmanagement.ErrNoPendingData):
+		rc.log.Debug("SgxRegistrationConfiguration UEFI variable not found")
+	case err != nil:
+		rc.log.Warn("unable to get the registration configuration", zap.Error(err))
+	default:
+		details.Mode = metrics.RegistrationModeDirect
+		if uefiConfig.RSNotSaveKeys() {
+			details.Mode = metrics.RegistrationModeIndirect
+		}
+		details.URL = uefiConfig.URL
+
+		if uefiConfig.URL != "" {
+			resolved, mismatch, err := followRegistrationServer(cfg, uefiConfig.URL)
+			switch {
+			case errors.Is(err, errInsecureRegistrationServer):
+				rc.log.Warn("Registration server of the SgxRegistrationConfiguration UEFI variable does not use HTTPS and is not followed",
+					zap.String("uefiURL", uefiConfig.URL),
+					zap.String("registrationURL", cfg.IntelRegistrationURL))
+				details.URLMismatch = true
+			case err != nil:
+				rc.log.Warn("invalid registration server URL in the SgxRegistrationConfiguration UEFI variable",
+					zap.String("url", uefiConfig.URL), zap.Error(err))
+			case mismatch && cfg.FollowUEFIRegistrationURL && !cfg.IsProduction() && config.IsIntelProductionURL(uefiConfig.URL):
+				rc.log.Warn("Not following the production registration server of the SgxRegistrationConfiguration UEFI variable outside of production",
+					zap.String("environment", cfg.Environment),
+					zap.String("uefiURL", uefiConfig.URL),
+					zap.String("registrationURL", cfg.IntelRegistrationURL))
+				details.URLMismatch = true
+			case mismatch && cfg.FollowUEFIRegistrationURL:
+				rc.log.Info("Following the registration server of the SgxRegistrationConfiguration UEFI variable",
+					zap.String("uefiURL", uefiConfig.URL),
+					zap.String("registrationURL", resolved.IntelRegistrationURL))
+				details.URLMismatch, details.URLFollowed = true, true
+				cfg = resolved
+			case mismatch:
+				rc.log.Warn("Registration server of the SgxRegistrationConfiguration UEFI variable differs from the configured one",
+					zap.String("uefiURL", uefiConfig.URL),
+					zap.String("registrationURL", cfg.IntelRegistrationURL))
+				details.URLMismatch = true
+			}
+		}
+
+		if uefiConfig.Proxy != nil {
+			details.ProxyType = uefiConfig.Proxy.Type.String()
+			cfg = rc.applyUEFIProxy(cfg, *uefiConfig.Proxy)
+		}
+
+		rc.log.Info("Registration configuration",
+			zap.String("mode", details.Mode),
+			zap.String("url", uefiConfig.URL),
+			zap.Uint16("flags", uefiConfig.Flags))
+	}
+
+	rc.metricsRegistry.UpdateRegistrationConfigurationMetrics(details.Mode, details.URLMismatch)
+
+	rc.detailsMu.Lock()
+	rc.details.RegistrationConfiguration = &details
+	rc.detailsMu.Unlock()
+
+	return cfg
+}
+
+// applyUEFIProxy applies the ProxyConf of the SgxRegistrationConfiguration UEFI variable when the proxy
+// mode defers to it. The proxy configuration takes precedence in this order: the explicit proxy mode,
+// the DIRECT_ACCESS or MANUAL_PROXY ProxyConf, then the proxy environment variables.
+func (rc *DefaultRegistrationChecker) applyUEFIProxy(cfg *config.RegistrationServiceConfig, proxy mpmanagement.ProxyConf) *config.RegistrationServiceConfig {
+	if cfg.ProxyMode != constants.ProxyModeUEFI {
+		return cfg
+	}
+
+	resolved := *cfg
+	switch proxy.Type {
+	case mpmanagement.ProxyTypeDirect:
+		resolved.ProxyMode = constants.ProxyModeDirect
+	case mpmanagement.ProxyTypeManual:
+		if _, err := config.ParseProxyURL(proxy.URL); err != nil {
+			rc.log.Warn("invalid manual proxy in the SgxRegistrationConfiguration UEFI variable, using the proxy environment variables",
+				zap.Error(err))
+			return cfg
+		}
+		resolved.ProxyMode = constants.ProxyModeManual
+		resolved.ProxyURL = proxy.URL
+	default:
+		return cfg
+	}
+	return &resolved
+}
+
+// errInsecureRegistrationServer is returned for a UEFI registration server not using HTTPS, to which
+// the platform manifest would be sent in cleartext
+var errInsecureRegistrationServer = errors.New("registration server does not use HTTPS")
+
+// followRegistrationServer compares the registration server (scheme and host) of uefiURL with the
+// configured one. On a mismatch, it returns a copy of cfg whose registration endpoints point to it,
+// unless the server does not use HTTPS.
+func followRegistrationServer(cfg *config.RegistrationServiceConfig, uefiURL string) (*config.RegistrationServiceConfig, bool, error) {
+	server, err := parseServerURL(uefiURL)
+	if err != nil {
+		return nil, false, err
+	}
+	configured, err := parseServerURL(cfg.IntelRegistrationURL)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid configured registration URL: %w", err)
+	}
+	if server.Scheme == configured.Scheme && server.Host == configured.Host {
+		return cfg, false, nil
+	}
+	if server.Scheme != "https" {
+		return nil, true, errInsecureRegistrationServer
+	}
+
+	resolved := *cfg
+	if resolved.IntelRegistrationURL, err = withServer(cfg.IntelRegistrationURL, server); err != nil {
+		return nil, true, err
+	}
+	if resolved.IntelAddPackageURL, err = withServer(cfg.IntelAddPackageURL, server); err != nil {
+		return nil, true, err
+	}
+	return &resolved, true, nil
+}
+
+// parseServerURL parses an absolute URL and normalizes its host, dropping the default port
+func parseServerURL(rawURL string) (*url.URL, error) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("url '%s' has no host", rawURL)
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	if (parsed.Scheme == "https" && parsed.Port() == "443") || (parsed.Scheme == "http" && parsed.Port() == "80") {
+		parsed.Host = parsed.Hostname()
+	}
+	return parsed, nil
+}
+
+// withServer replaces the scheme and host of endpoint with those of server
+func withServer(endpoint string, server *url.URL) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	parsed.Scheme = server.Scheme
+	parsed.Host = server.Host
+	return parsed.String(), nil
+}
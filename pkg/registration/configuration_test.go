@@ -0,0 +1,183 @@
+package registration
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	fakeplatform "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/fake_platform"
+	mpmanagement "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_management"
+	platformmanifest "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/platform_manifest"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/config"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/constants"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestResolveRegistrationConfig(t *testing.T) {
+	configuredPosts, uefiPosts, proxiedPosts := 0, 0, 0
+	configuredServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		configuredPosts++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer configuredServer.Close()
+	// the registration server of the configuration variable is only followed over HTTPS
+	uefiServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		uefiPosts++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer uefiServer.Close()
+	caCertPath := t.TempDir()
+	uefiServerCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: uefiServer.Certificate().Raw})
+	assert.NoError(t, os.WriteFile(filepath.Join(caCertPath, "uefi-server.crt"), uefiServerCert, 0o600))
+	insecureServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		uefiPosts++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer insecureServer.Close()
+	// the proxy answers the requests itself
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		proxiedPosts++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer proxyServer.Close()
+	uefiProxy := &mpmanagement.ProxyConf{Type: mpmanagement.ProxyTypeManual, URL: proxyServer.URL}
+
+	cases := []struct {
+		msg                   string
+		uefiConfig            *mpmanagement.RegistrationConfiguration
+		follow                bool
+		environment           string
+		proxyMode             string
+		wantedMode            string
+		wantedMismatch        bool
+		wantedFollowed        bool
+		wantedConfiguredPosts int
+		wantedUefiPosts       int
+		wantedProxiedPosts    int
+		wantedProxyType       string
+	}{
+		{
+			msg:                   "missing configuration variable",
+			wantedMode:            metrics.RegistrationModeUnknown,
+			wantedConfiguredPosts: 1,
+		},
+		{
+			msg:                   "same registration server",
+			uefiConfig:            &mpmanagement.RegistrationConfiguration{URL: configuredServer.URL},
+			wantedMode:            metrics.RegistrationModeDirect,
+			wantedConfiguredPosts: 1,
+		},
+		{
+			msg:                   "mismatch is only reported by default",
+			uefiConfig:            &mpmanagement.RegistrationConfiguration{URL: uefiServer.URL},
+			wantedMode:            metrics.RegistrationModeDirect,
+			wantedMismatch:        true,
+			wantedConfiguredPosts: 1,
+		},
+		{
+			msg:             "mismatch is followed when enabled",
+			uefiConfig:      &mpmanagement.RegistrationConfiguration{URL: uefiServer.URL},
+			follow:          true,
+			wantedMode:      metrics.RegistrationModeDirect,
+			wantedMismatch:  true,
+			wantedFollowed:  true,
+			wantedUefiPosts: 1,
+		},
+		{
+			msg:                   "registration server not using HTTPS is not followed",
+			uefiConfig:            &mpmanagement.RegistrationConfiguration{URL: insecureServer.URL},
+			follow:                true,
+			wantedMode:            metrics.RegistrationModeDirect,
+			wantedMismatch:        true,
+			wantedConfiguredPosts: 1,
+		},
+		{
+			msg:                   "production registration server is not followed outside of production",
+			uefiConfig:            &mpmanagement.RegistrationConfiguration{URL: "https://api.trustedservices.intel.com"},
+			follow:                true,
+			environment:           constants.EnvironmentSandbox,
+			wantedMode:            metrics.RegistrationModeDirect,
+			wantedMismatch:        true,
+			wantedConfiguredPosts: 1,
+		},
+		{
+			msg: "registration server not saving the keys means indirect registration",
+			uefiConfig: &mpmanagement.RegistrationConfiguration{
+				URL:   configuredServer.URL,
+				Flags: 0x0001,
+			},
+			wantedMode:            metrics.RegistrationModeIndirect,
+			wantedConfiguredPosts: 1,
+		},
+		{
+			msg:                "manual proxy of the configuration variable is used",
+			uefiConfig:         &mpmanagement.RegistrationConfiguration{URL: configuredServer.URL, Proxy: uefiProxy},
+			wantedMode:         metrics.RegistrationModeDirect,
+			wantedProxiedPosts: 1,
+			wantedProxyType:    "manual",
+		},
+		{
+			msg: "direct access of the configuration variable bypasses the proxy",
+			uefiConfig: &mpmanagement.RegistrationConfiguration{
+				URL:   configuredServer.URL,
+				Proxy: &mpmanagement.ProxyConf{Type: mpmanagement.ProxyTypeDirect, URL: proxyServer.URL},
+			},
+			wantedMode:            metrics.RegistrationModeDirect,
+			wantedConfiguredPosts: 1,
+			wantedProxyType:       "direct",
+		},
+		{
+			msg:                   "explicit proxy mode takes precedence over the configuration variable",
+			uefiConfig:            &mpmanagement.RegistrationConfiguration{URL: configuredServer.URL, Proxy: uefiProxy},
+			proxyMode:             constants.ProxyModeDirect,
+			wantedMode:            metrics.RegistrationModeDirect,
+			wantedConfiguredPosts: 1,
+			wantedProxyType:       "manual",
+		},
+	}
+
+	for _, c := range cases {
+		configuredPosts, uefiPosts, proxiedPosts = 0, 0, 0
+
+		manifest, err := platformmanifest.New(platformmanifest.PlatformManifestGUID,
+			platformmanifest.NewStructure(platformmanifest.PlatformInfoGUID, make([]byte, 32))).Marshal()
+		assert.NoError(t, err)
+		manifestSource := fakeplatform.NewManifestSource(manifest)
+		manifestSource.RegistrationConfiguration = c.uefiConfig
+
+		cfg := &config.RegistrationServiceConfig{
+			IntelRegistrationURL:      configuredServer.URL + "/sgx/registration/v1/platform",
+			IntelAddPackageURL:        configuredServer.URL + "/sgx/registration/v1/package",
+			Environment:               c.environment,
+			FollowUEFIRegistrationURL: c.follow,
+			ProxyMode:                 constants.ProxyModeUEFI,
+			PCCSCACertPath:            caCertPath,
+			RequestTimeout:            5 * time.Second,
+		}
+		if c.proxyMode != "" {
+			cfg.ProxyMode = c.proxyMode
+		}
+		logger := zap.NewNop()
+		checker := NewRegistrationChecker(logger, cfg, metrics.NewRegistrationServiceMetricsRegistry(logger),
+			manifestSource, fakeplatform.NewInfoProvider(newTestPlatformInfo()))
+
+		metric, err := checker.Check(t.Context())
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, metrics.PlatformRebootNeeded, metric.Status, c.msg)
+		assert.Equal(t, c.wantedConfiguredPosts, configuredPosts, c.msg)
+		assert.Equal(t, c.wantedUefiPosts, uefiPosts, c.msg)
+		assert.Equal(t, c.wantedProxiedPosts, proxiedPosts, c.msg)
+
+		details := checker.StatusDetails().RegistrationConfiguration
+		assert.Equal(t, c.wantedMode, details.Mode, c.msg)
+		assert.Equal(t, c.wantedMismatch, details.URLMismatch, c.msg)
+		assert.Equal(t, c.wantedFollowed, details.URLFollowed, c.msg)
+		assert.Equal(t, c.wantedProxyType, details.ProxyType, c.msg)
+	}
+}
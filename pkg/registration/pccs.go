@@ -0,0 +1,48 @@
+package registration
+
+import "time"
+
+// PCCSOutcome describes the outcome of the last request sending the platform data to a PCCS
+type PCCSOutcome struct {
+	PCCSURL   string `json:"pccs"`
+	Succeeded bool   `json:"succeeded"`
+	// SucceededAt is when the PCCS last accepted the request
+	SucceededAt *time.Time `json:"succeededAt,omitempty"`
+	Error       string     `json:"error,omitempty"`
+
+	// hash identifies the request the PCCS accepted
+	hash string
+}
+
+// pccsTracker keeps the outcome of a request to each PCCS, so that a PCCS is only sent the request
+// again when it failed or once the request changed, e.g. after a TCB recovery
+type pccsTracker map[string]PCCSOutcome
+
+// pending reports whether the request with the given hash must be sent to the PCCS
+func (t pccsTracker) pending(pccsURL, hash string) bool {
+	outcome := t[pccsURL]
+	return !outcome.Succeeded || outcome.hash != hash
+}
+
+// record saves the outcome of the request with the given hash
+func (t pccsTracker) record(pccsURL, hash string, err error) {
+	outcome := PCCSOutcome{PCCSURL: pccsURL, Succeeded: err == nil}
+	if err != nil {
+		outcome.Error = err.Error()
+	} else {
+		now := time.Now()
+		outcome.SucceededAt, outcome.hash = &now, hash
+	}
+	t[pccsURL] = outcome
+}
+
+// outcomes returns the outcomes of the PCCS in configuration order
+func (t pccsTracker) outcomes(pccsURLs []string) []PCCSOutcome {
+	var outcomes []PCCSOutcome
+	for _, pccsURL := range pccsURLs {
+		if outcome, ok := t[pccsURL]; ok {
+			outcomes = append(outcomes, outcome)
+		}
+	}
+	return outcomes
+}
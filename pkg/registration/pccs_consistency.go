@@ -0,0 +1,67 @@
+package registration
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
+	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
+	"go.uber.org/zap"
+)
+
+// PCCSConsistencyDetails compares the PCK certificate served by a PCCS with the one of Intel
+type PCCSConsistencyDetails struct {
+	PCCSURL    string    `json:"pccs"`
+	Consistent bool      `json:"consistent"`
+	CheckedAt  time.Time `json:"checkedAt"`
+	// TCBm and Serial identify the certificate served by the PCCS, empty when it served none
+	TCBm        string `json:"tcbm,omitempty"`
+	Serial      string `json:"serial,omitempty"`
+	IntelTCBm   string `json:"intelTcbm"`
+	IntelSerial string `json:"intelSerial"`
+	// Refreshed reports whether the PCCS was asked to refresh its PCK certificates cache
+	Refreshed bool   `json:"refreshed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// checkPCCSConsistency compares the PCK certificate served by each PCCS with the one of Intel, at most once
+// per consistency interval. Divergences are only reported, as the PCK certificate was retrieved successfully.
+func (rc *DefaultRegistrationChecker) checkPCCSConsistency(ctx context.Context, intelService *intelservices.IntelService, platformInfo *sgxplatforminfo.SgxPlatformInfo) {
+	now := time.Now()
+	if now.Sub(rc.lastConsistencyCheck) < rc.regServiceConfig.PCCSConsistencyInterval {
+		return
+	}
+
+	results, err := intelService.CheckPCCSConsistency(ctx, platformInfo, rc.regServiceConfig.PCCSConsistencyRefresh)
+	if err != nil {
+		// compared again on the next check
+		rc.log.Warn("unable to check the consistency of the PCCS with Intel", zap.Error(err))
+		return
+	}
+	rc.lastConsistencyCheck = now
+
+	var details []PCCSConsistencyDetails
+	for _, result := range results {
+		consistent := result.Err == nil
+		d := PCCSConsistencyDetails{
+			PCCSURL:     result.PCCSURL,
+			Consistent:  consistent,
+			CheckedAt:   now,
+			TCBm:        result.PCCS.TCBm,
+			Serial:      result.PCCS.Serial,
+			IntelTCBm:   result.Intel.TCBm,
+			IntelSerial: result.Intel.Serial,
+			Refreshed:   result.Refreshed,
+		}
+		if !consistent && !errors.Is(result.Err, intelservices.ErrPCKCertificateDiverges) {
+			d.Error = result.Err.Error()
+		}
+		details = append(details, d)
+		rc.metricsRegistry.UpdatePCCSConsistencyMetric(result.PCCSURL, consistent)
+	}
+
+	rc.detailsMu.Lock()
+	rc.details.PCCSConsistency = details
+	rc.detailsMu.Unlock()
+}
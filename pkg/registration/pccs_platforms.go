@@ -0,0 +1,32 @@
+package registration
+
+import (
+	"context"
+	"encoding/json"
+
+	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
+	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
+)
+
+// addPlatformToPCCS adds the registered platform to each PCCS, so that a PCCS in REQ mode fetches and
+// caches its PCK certificates. Failures are only reported, the platform being registered with Intel.
+func (rc *DefaultRegistrationChecker) addPlatformToPCCS(ctx context.Context, intelService *intelservices.IntelService, platformInfo *sgxplatforminfo.SgxPlatformInfo) {
+	platform := intelservices.NewPCCSPlatform(platformInfo, rc.multiPackageManifest())
+	encoded, err := json.Marshal(platform)
+	if err != nil {
+		return
+	}
+	hash := requestHash(encoded)
+
+	results := intelService.AddPlatformToPCCS(ctx, platform, func(pccsURL string) bool {
+		return rc.pccsPlatforms.pending(pccsURL, hash)
+	})
+	for _, result := range results {
+		rc.pccsPlatforms.record(result.PCCSURL, hash, result.Err)
+		rc.metricsRegistry.UpdatePCCSPlatformMetric(result.PCCSURL, result.Err == nil)
+	}
+
+	rc.detailsMu.Lock()
+	rc.details.PCCSPlatforms = rc.pccsPlatforms.outcomes(rc.regServiceConfig.PCCSURLs)
+	rc.detailsMu.Unlock()
+}
@@ -0,0 +1,40 @@
+package registration
+
+import (
+	"context"
+	"encoding/json"
+
+	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
+	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
+	"go.uber.org/zap"
+)
+
+// uploadPCKCerts uploads the PCK certificates and the collateral retrieved from Intel to each PCCS, so that
+// a PCCS in OFFLINE mode, which cannot reach Intel, serves them. Failures are only reported.
+func (rc *DefaultRegistrationChecker) uploadPCKCerts(ctx context.Context, intelService *intelservices.IntelService,
+	platformInfo *sgxplatforminfo.SgxPlatformInfo, pckCerts *intelservices.PCKCerts) {
+	platform := intelservices.NewPCCSPlatform(platformInfo, rc.multiPackageManifest())
+	collateral, err := intelService.RetrievePlatformCollateral(ctx, platform, pckCerts)
+	if err != nil {
+		rc.log.Warn("unable to retrieve the platform collateral, skipping the PCK certificates upload",
+			zap.Error(err))
+		return
+	}
+	encoded, err := json.Marshal(collateral)
+	if err != nil {
+		return
+	}
+	hash := requestHash(encoded)
+
+	results := intelService.UploadPlatformCollateral(ctx, collateral, func(pccsURL string) bool {
+		return rc.pccsUploads.pending(pccsURL, hash)
+	})
+	for _, result := range results {
+		rc.pccsUploads.record(result.PCCSURL, hash, result.Err)
+		rc.metricsRegistry.UpdatePCCSUploadMetric(result.PCCSURL, result.Err == nil)
+	}
+
+	rc.detailsMu.Lock()
+	rc.details.PCCSUploads = rc.pccsUploads.outcomes(rc.regServiceConfig.PCCSURLs)
+	rc.detailsMu.Unlock()
+}
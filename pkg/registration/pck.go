@@ -0,0 +1,113 @@
+package registration
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"sync"
+	"time"
+
+	pckcert "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/pck_cert"
+	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// PCKCertificateDetails describes the PCK certificate retrieved during the last check
+type PCKCertificateDetails struct {
+	FMSPC    string    `json:"fmspc"`
+	TCBm     string    `json:"tcbm"`
+	CAType   string    `json:"caType"`
+	Issuer   string    `json:"issuer"`
+	Serial   string    `json:"serial"`
+	NotAfter time.Time `json:"notAfter"`
+	// Revoked is unset when the PCK CRL could not be retrieved
+	Revoked       *bool      `json:"revoked,omitempty"`
+	CRLNextUpdate *time.Time `json:"crlNextUpdate,omitempty"`
+}
+
+// crlCache keeps the PCK CRLs of the processor and platform CAs until their next update
+type crlCache struct {
+	mu   sync.Mutex
+	crls map[string]*x509.RevocationList
+}
+
+// get returns the cached CRL of the CA type if it is still current and signed by issuer
+func (c *crlCache) get(caType string, issuer *x509.Certificate, now time.Time) *x509.RevocationList {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	crl, ok := c.crls[caType]
+	if !ok || !now.Before(crl.NextUpdate) || crl.CheckSignatureFrom(issuer) != nil {
+		return nil
+	}
+	return crl
+}
+
+// put caches a CRL, unless it has no next update
+func (c *crlCache) put(caType string, crl *x509.RevocationList) {
+	if crl.NextUpdate.IsZero() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.crls == nil {
+		c.crls = map[string]*x509.RevocationList{}
+	}
+	c.crls[caType] = crl
+}
+
+// reportPCKCertificate exports the attributes of the retrieved PCK certificate
+func (rc *DefaultRegistrationChecker) reportPCKCertificate(cert *pckcert.Certificate) {
+	rc.metricsRegistry.UpdatePCKCertificateMetrics(cert.FMSPC, cert.CAType, cert.NotAfter)
+
+	rc.detailsMu.Lock()
+	rc.details.PCKCertificate = &PCKCertificateDetails{
+		FMSPC:    cert.FMSPC,
+		TCBm:     cert.TCBm,
+		CAType:   cert.CAType,
+		Issuer:   cert.Leaf.Issuer.CommonName,
+		Serial:   cert.Leaf.SerialNumber.Text(16),
+		NotAfter: cert.NotAfter,
+	}
+	rc.detailsMu.Unlock()
+}
+
+// checkRevocation checks the retrieved PCK certificate against the CRL of its issuer, which is only
+// retrieved again after its next update. The check is skipped when no endpoint returns the CRL.
+func (rc *DefaultRegistrationChecker) checkRevocation(ctx context.Context, intelService *intelservices.IntelService, cert *pckcert.Certificate,
+	metric metrics.StatusCodeMetric) (metrics.StatusCodeMetric, error) {
+	issuer := cert.Issuer()
+	if issuer == nil {
+		rc.log.Warn("PCK certificate issuer not found in the issuer chain, skipping the revocation check")
+		return metric, nil
+	}
+
+	crl := rc.crls.get(cert.CAType, issuer, time.Now())
+	if crl == nil {
+		var err error
+		crl, err = intelService.RetrievePCKCRL(ctx, cert.CAType, issuer)
+		if err != nil {
+			rc.log.Warn("unable to retrieve the PCK CRL, skipping the revocation check",
+				zap.String("ca", cert.CAType), zap.Error(err))
+			return metric, nil
+		}
+		rc.crls.put(cert.CAType, crl)
+	}
+
+	revoked := cert.IsRevoked(crl)
+	rc.metricsRegistry.UpdatePCKCertificateRevokedMetric(revoked)
+
+	rc.detailsMu.Lock()
+	if rc.details.PCKCertificate != nil {
+		nextUpdate := crl.NextUpdate
+		rc.details.PCKCertificate.Revoked = &revoked
+		rc.details.PCKCertificate.CRLNextUpdate = &nextUpdate
+	}
+	rc.detailsMu.Unlock()
+
+	if revoked {
+		return metrics.StatusCodeMetric{Status: metrics.PCKCertificateRevoked},
+			fmt.Errorf("PCK certificate %s is revoked by the %s CA CRL", cert.Leaf.SerialNumber.Text(16), cert.CAType)
+	}
+	return metric, nil
+}
@@ -0,0 +1,64 @@
+package registration
+
+import (
+	"context"
+
+	platformmanifest "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/platform_manifest"
+	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
+	tcbinfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/tcb_info"
+	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
+	"go.uber.org/zap"
+)
+
+// PCKCertificatesDetails describes the PCK certificates of all the TCB levels of the platform
+type PCKCertificatesDetails struct {
+	// Method is how the certificates were requested, by encrypted PPID or platform manifest
+	Method string `json:"method"`
+	// TCBms lists the TCB levels Intel issued a PCK certificate for
+	TCBms []string `json:"tcbms"`
+	// SelectedTCBm is the TCB level of the certificate to use at the raw TCB of the platform
+	SelectedTCBm string `json:"selectedTcbm"`
+}
+
+// retrievePCKCerts retrieves the PCK certificates of all the TCB levels of the platform, e.g. to plan
+// TCB recoveries. Multi-package platforms send the platform manifest registered last, as recorded in
+// the registration state. The certificates are informative only, so failures are only logged and nil is returned.
+func (rc *DefaultRegistrationChecker) retrievePCKCerts(ctx context.Context, intelService *intelservices.IntelService,
+	platformInfo *sgxplatforminfo.SgxPlatformInfo, tcbInfo *tcbinfo.TCBInfo) *intelservices.PCKCerts {
+	manifest := rc.multiPackageManifest()
+	pckCerts, err := intelService.RetrievePCKCerts(ctx, platformInfo, manifest, tcbInfo)
+	if err != nil {
+		rc.log.Warn("unable to retrieve the PCK certificates of all TCB levels", zap.Error(err))
+		return nil
+	}
+
+	details := &PCKCertificatesDetails{
+		Method:       pckCerts.Method,
+		SelectedTCBm: pckCerts.Selected.TCBm,
+	}
+	for _, cert := range pckCerts.Certificates {
+		details.TCBms = append(details.TCBms, cert.TCBm)
+	}
+	rc.metricsRegistry.UpdatePCKCertificatesMetric(len(pckCerts.Certificates))
+
+	rc.detailsMu.Lock()
+	rc.details.PCKCertificates = details
+	rc.detailsMu.Unlock()
+	return pckCerts
+}
+
+// multiPackageManifest returns the platform manifest registered last, as recorded in the registration
+// state, or nil for single-package platforms, which are identified by their encrypted PPID instead
+func (rc *DefaultRegistrationChecker) multiPackageManifest() []byte {
+	manifest, err := rc.submissions.latestPlatformManifest()
+	if err != nil {
+		rc.log.Warn("unable to read the registered platform manifest", zap.Error(err))
+	}
+	if manifest == nil {
+		return nil
+	}
+	if parsed, err := platformmanifest.Parse(manifest); err != nil || parsed.PackageCount() < 2 {
+		return nil
+	}
+	return manifest
+}
@@ -2,10 +2,14 @@ package registration
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	mpmanagement "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_management"
+	platformmanifest "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/platform_manifest"
 	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
 	config "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/config"
 	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
@@ -15,64 +19,425 @@ import (
 
 // RegistrationChecker is an interface to facilitate tests
 type RegistrationChecker interface {
-	Check() (metrics.StatusCodeMetric, error)
+	Check(ctx context.Context) (metrics.StatusCodeMetric, error)
 }
 
-func NewRegistrationChecker(logger *zap.Logger, cfg *config.RegistrationServiceConfig, metricsRegistry *metrics.RegistrationServiceMetricsRegistry) *DefaultRegistrationChecker {
+// PlatformManifestSource abstracts the SGX registration UEFI variables (backed by mp_management)
+type PlatformManifestSource interface {
+	IsMachineRegistered() (bool, error)
+	GetPlatformManifest() (mpmanagement.PlatformManifest, error)
+	GetAddPackageRequest() (mpmanagement.AddPackageRequest, error)
+	GetRequestType() (mpmanagement.RequestType, error)
+	GetPendingRequest() ([]byte, error)
+	GetMultiPackageInfo() (*mpmanagement.MultiPackageInfo, error)
+	GetSgxEnablement() (*mpmanagement.SgxEnablement, error)
+	GetRegistrationConfiguration() (*mpmanagement.RegistrationConfiguration, error)
+	SetServerResponse(response []byte) error
+	CompleteMachineRegistrationStatus() error
+}
+
+// PlatformInfoProvider abstracts the retrieval of the SGX platform information (backed by sgx_platform_info)
+type PlatformInfoProvider interface {
+	GetSgxPlatformInfo(ctx context.Context) (*sgxplatforminfo.SgxPlatformInfo, error)
+}
+
+func NewRegistrationChecker(logger *zap.Logger, cfg *config.RegistrationServiceConfig, metricsRegistry *metrics.RegistrationServiceMetricsRegistry,
+	manifestSource PlatformManifestSource, platformInfoProvider PlatformInfoProvider) *DefaultRegistrationChecker {
 	return &DefaultRegistrationChecker{
-		log:              logger,
-		regServiceConfig: cfg,
-		metricsRegistry:  metricsRegistry,
+		log:                  logger,
+		regServiceConfig:     cfg,
+		metricsRegistry:      metricsRegistry,
+		manifestSource:       manifestSource,
+		platformInfoProvider: platformInfoProvider,
+		submissions:          newSubmissionStore(cfg.StateFile),
+		pccsPlatforms:        make(pccsTracker),
+		pccsUploads:          make(pccsTracker),
+		pccsHealth:           intelservices.NewEndpointHealth(cfg.PCCSBreakerFailures, cfg.PCCSBreakerCoolDown, metricsRegistry),
 	}
 }
 
 type DefaultRegistrationChecker struct {
-	log              *zap.Logger
-	regServiceConfig *config.RegistrationServiceConfig
-	metricsRegistry  *metrics.RegistrationServiceMetricsRegistry
+	log                  *zap.Logger
+	regServiceConfig     *config.RegistrationServiceConfig
+	metricsRegistry      *metrics.RegistrationServiceMetricsRegistry
+	manifestSource       PlatformManifestSource
+	platformInfoProvider PlatformInfoProvider
+	submissions          *submissionStore
+	crls                 crlCache
+	pccsPlatforms        pccsTracker                   // outcome of adding the platform to each PCCS
+	pccsUploads          pccsTracker                   // outcome of uploading the PCK certificates to each PCCS
+	lastConsistencyCheck time.Time                     // last comparison of the PCCS PCK certificates with Intel
+	pccsHealth           *intelservices.EndpointHealth // circuit breakers of the PCCS across checks
+
+	detailsMu sync.Mutex
+	details   StatusDetails
+}
+
+// StatusDetails returns the platform diagnostics gathered during the last check
+func (rc *DefaultRegistrationChecker) StatusDetails() StatusDetails {
+	rc.detailsMu.Lock()
+	defer rc.detailsMu.Unlock()
+	return rc.details
+}
+
+// Check determines the registration status of the platform and registers it when a request is pending.
+// The check is bounded by the check timeout; a check interrupted by it or by the cancellation of ctx,
+// e.g. on shutdown, reports CheckTimedOut or CheckCancelled.
+func (rc *DefaultRegistrationChecker) Check(ctx context.Context) (metrics.StatusCodeMetric, error) {
+	if rc.regServiceConfig.CheckTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rc.regServiceConfig.CheckTimeout)
+		defer cancel()
+	}
+
+	metric, err := rc.check(ctx)
+	// a check that completed before the cancellation keeps its status
+	if err != nil && ctx.Err() != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return metrics.StatusCodeMetric{Status: metrics.CheckTimedOut},
+				fmt.Errorf("registration check timed out after %s: %w", rc.regServiceConfig.CheckTimeout, err)
+		}
+		return metrics.StatusCodeMetric{Status: metrics.CheckCancelled}, fmt.Errorf("registration check cancelled: %w", err)
+	}
+	return metric, err
 }
 
-func (rc *DefaultRegistrationChecker) Check() (metrics.StatusCodeMetric, error) {
-	mp := mpmanagement.NewMPManagement()
-	defer mp.Close()
+func (rc *DefaultRegistrationChecker) check(ctx context.Context) (metrics.StatusCodeMetric, error) {
+	mp := rc.manifestSource
 
-	intelService, err := intelservices.NewIntelService(rc.log, rc.regServiceConfig)
+	intelService, err := intelservices.NewIntelService(rc.log, rc.resolveRegistrationConfig(), rc.metricsRegistry, rc.pccsHealth)
 	if err != nil {
 		return metrics.StatusCodeMetric{Status: metrics.UnknownError},
 			fmt.Errorf("failed to create intel service: %w", err)
 	}
 
+	// the UEFI variables are read by blocking calls, which are not started once the check is cancelled
+	if err := ctx.Err(); err != nil {
+		return metrics.CreateUnknownErrorStatusCodeMetric(), err
+	}
+	sgxState := rc.reportSgxEnablement()
+
 	isMachineRegistered, err := mp.IsMachineRegistered()
+	if err != nil {
+		// the SGX state tells a BIOS with SGX disabled apart from missing efivars
+		return metrics.StatusCodeMetric{Status: metrics.SgxUefiUnavailable},
+			fmt.Errorf("SGX UEFI variables unavailable (SGX BIOS state: %s): %w", sgxState, err)
+	}
+
+	// The pending request is checked on every run, as the BIOS may publish a new PlatformManifest
+	// (TCB recovery after a microcode/BIOS update) or an AddRequest without clearing the registration flag
+	requestType, err := mp.GetRequestType()
 	if err != nil {
 		return metrics.StatusCodeMetric{Status: metrics.SgxUefiUnavailable}, err
 	}
 
-	if !isMachineRegistered {
-		plaformManifest, platManErr := mp.GetPlatformManifest()
-		if platManErr != nil {
-			return metrics.StatusCodeMetric{Status: metrics.SgxUefiUnavailable}, platManErr
+	rc.reportMultiPackageInfo()
+
+	rc.log.Debug("UEFI registration state",
+		zap.Bool("registered", isMachineRegistered),
+		zap.String("pendingRequest", requestType.String()))
+
+	switch requestType {
+	case mpmanagement.RequestRegistration:
+		if isMachineRegistered {
+			return rc.recoverTcb(ctx, intelService)
 		}
-		// Pass metrics registry to RegisterPlatform
-		metric, regErr := intelService.RegisterPlatform(plaformManifest, rc.metricsRegistry)
-
-		// registration was successful
-		if metric.Status == metrics.PlatformRebootNeeded {
-			completeErr := mp.CompleteMachineRegistrationStatus()
-			if completeErr != nil {
-				return metrics.StatusCodeMetric{Status: metrics.UefiPersistFailed}, completeErr
-			}
+		return rc.registerPlatform(ctx, intelService)
+	case mpmanagement.RequestAddPackage:
+		var addPackageRequest mpmanagement.AddPackageRequest
+		if isMachineRegistered {
+			addPackageRequest, err = mp.GetPendingRequest()
+		} else {
+			addPackageRequest, err = mp.GetAddPackageRequest()
+		}
+		if err != nil {
+			return metrics.StatusCodeMetric{Status: metrics.SgxUefiUnavailable}, err
 		}
-		return metric, regErr
+		return rc.addPackage(ctx, intelService, addPackageRequest)
 	}
 
-	platformInfo, err := sgxplatforminfo.GetSgxPlatformInfo()
+	if !isMachineRegistered {
+		return metrics.StatusCodeMetric{Status: metrics.SgxUefiUnavailable},
+			fmt.Errorf("platform is not registered and no request is pending: %w", mpmanagement.ErrNoPendingData)
+	}
+
+	platformInfo, err := rc.platformInfoProvider.GetSgxPlatformInfo(ctx)
 	if err != nil {
 		return metrics.StatusCodeMetric{Status: metrics.RetryNeeded}, err
 	}
 
 	// Pass metrics registry to RetrievePCK
-	metric, err := intelService.RetrievePCK(platformInfo, rc.metricsRegistry)
-	return metric, err
+	metric, cert, err := intelService.RetrievePCK(ctx, platformInfo, rc.metricsRegistry)
+	if cert == nil {
+		return metric, err
+	}
+	rc.reportPCKCertificate(cert)
+	if metric, err = rc.checkRevocation(ctx, intelService, cert, metric); err != nil {
+		return metric, err
+	}
+	tcbInfo := rc.evaluateTCB(ctx, intelService, cert)
+	if rc.regServiceConfig.IntelAPIKey != nil {
+		pckCerts := rc.retrievePCKCerts(ctx, intelService, platformInfo, tcbInfo)
+		if pckCerts != nil && rc.regServiceConfig.PCCSUploadPCKCerts {
+			rc.uploadPCKCerts(ctx, intelService, platformInfo, pckCerts)
+		}
+	}
+	if rc.regServiceConfig.PCCSConsistencyInterval > 0 && len(rc.regServiceConfig.PCCSURLs) > 0 {
+		rc.checkPCCSConsistency(ctx, intelService, platformInfo)
+	}
+	if rc.regServiceConfig.PCCSAddPlatform {
+		rc.addPlatformToPCCS(ctx, intelService, platformInfo)
+	}
+	if rc.regServiceConfig.PrefetchCollateral {
+		rc.prefetchCollateral(ctx, intelService, cert)
+	}
+	return metric, nil
+}
+
+// reportSgxEnablement logs and exports the SGX BIOS state and EPC configuration, and returns the state
+func (rc *DefaultRegistrationChecker) reportSgxEnablement() string {
+	enablement, err := rc.manifestSource.GetSgxEnablement()
+
+	metricValue := metrics.SgxEnablementMetric{State: metrics.SgxBiosStateUnknown}
+	switch {
+	case errors.Is(err, mpmanagement.ErrEfivarsUnavailable):
+		rc.log.Warn("efivarfs is not available", zap.Error(err))
+		metricValue.State = metrics.SgxBiosStateEfivarsUnavailable
+	case err != nil:
+		rc.log.Warn("unable to get the SGX enablement", zap.Error(err))
+	default:
+		metricValue = metrics.SgxEnablementMetric{
+			State:              enablement.State.String(),
+			EpcSize:            enablement.EpcSize,
+			MaxEpcSize:         enablement.MaxEpcSize,
+			RequestedEpcSize:   enablement.RequestedEpcSize,
+			PrmrrSize:          enablement.PrmrrSize,
+			RequestedPrmrrSize: enablement.RequestedPrmrrSize,
+		}
+		rc.log.Info("SGX enablement",
+			zap.String("state", metricValue.State),
+			zap.Uint32("epcSizeMB", enablement.EpcSize),
+			zap.Uint32("maxEpcSizeMB", enablement.MaxEpcSize),
+			zap.Uint32("requestedEpcSizeMB", enablement.RequestedEpcSize),
+			zap.Uint32("prmrrSizeMB", enablement.PrmrrSize),
+			zap.Uint32("requestedPrmrrSizeMB", enablement.RequestedPrmrrSize))
+	}
+	rc.metricsRegistry.UpdateSgxEnablementMetrics(metricValue)
+
+	rc.detailsMu.Lock()
+	rc.details.SgxState = metricValue.State
+	rc.details.SgxEnablement = enablement
+	rc.detailsMu.Unlock()
+
+	return metricValue.State
+}
+
+// reportMultiPackageInfo logs and exports the multi-package state.
+// Failures are only logged, as the package info is informational.
+func (rc *DefaultRegistrationChecker) reportMultiPackageInfo() {
+	info, err := rc.manifestSource.GetMultiPackageInfo()
+	if err != nil {
+		rc.log.Warn("unable to get the multi-package information", zap.Error(err))
+		if info == nil {
+			return
+		}
+	}
+
+	keysConsumed := make([]bool, 0, info.PackageCount())
+	for _, p := range info.Packages {
+		keysConsumed = append(keysConsumed, p.Consumed)
+		rc.log.Debug("CPU package key state",
+			zap.Int("package", p.Index),
+			zap.Int("keyBlobSize", p.KeyBlobSize),
+			zap.Bool("consumed", p.Consumed))
+	}
+	rc.log.Info("Multi-package information",
+		zap.Bool("registrationComplete", info.RegistrationComplete),
+		zap.Bool("packageInfoComplete", info.PackageInfoComplete),
+		zap.Bool("packageInfoAvailable", info.PackageInfoAvailable),
+		zap.Int("packages", info.PackageCount()))
+
+	rc.metricsRegistry.UpdateMultiPackageMetrics(info.RegistrationComplete, info.PackageInfoComplete, keysConsumed)
+}
+
+// registerPlatform registers the pending PlatformManifest with Intel and flags the registration as complete.
+// A manifest already accepted by Intel is not sent again; only the UEFI write is retried.
+func (rc *DefaultRegistrationChecker) registerPlatform(ctx context.Context, intelService *intelservices.IntelService) (metrics.StatusCodeMetric, error) {
+	plaformManifest, err := rc.manifestSource.GetPlatformManifest()
+	if err != nil {
+		return metrics.StatusCodeMetric{Status: metrics.SgxUefiUnavailable}, err
+	}
+	if err := rc.validateRequest(plaformManifest, platformmanifest.PlatformManifestGUID); err != nil {
+		return metrics.StatusCodeMetric{Status: metrics.InvalidPlatformManifest}, err
+	}
+
+	hash := requestHash(plaformManifest)
+	submitted, err := rc.previousSubmission(hash)
+	if err != nil {
+		return metrics.CreateUnknownErrorStatusCodeMetric(), err
+	}
+	if submitted == nil {
+		// Pass metrics registry to RegisterPlatform
+		metric, regErr := intelService.RegisterPlatform(ctx, plaformManifest, rc.metricsRegistry)
+		if metric.Status != metrics.PlatformRebootNeeded {
+			return metric, regErr
+		}
+		rc.recordSubmission(submission{SHA256: hash, Kind: submissionPlatformManifest, SubmittedAt: time.Now(), Request: plaformManifest})
+	}
+
+	// registration was successful
+	if err := rc.persistRegistration(hash, nil); err != nil {
+		return metrics.StatusCodeMetric{Status: metrics.UefiPersistFailed}, err
+	}
+	return metrics.StatusCodeMetric{Status: metrics.PlatformRebootNeeded}, nil
+}
+
+// recoverTcb registers a PlatformManifest that is pending although the registration flag is set.
+// The BIOS creates such a manifest for TCB recovery; it is consumed on the next reboot, until which
+// it is not sent again.
+func (rc *DefaultRegistrationChecker) recoverTcb(ctx context.Context, intelService *intelservices.IntelService) (metrics.StatusCodeMetric, error) {
+	rc.log.Warn("PlatformManifest pending although the platform is flagged as registered, handling it as TCB recovery")
+
+	plaformManifest, err := rc.manifestSource.GetPendingRequest()
+	if err != nil {
+		return metrics.StatusCodeMetric{Status: metrics.SgxUefiUnavailable}, err
+	}
+	if err := rc.validateRequest(plaformManifest, platformmanifest.PlatformManifestGUID); err != nil {
+		return metrics.StatusCodeMetric{Status: metrics.InvalidPlatformManifest}, err
+	}
+
+	hash := requestHash(plaformManifest)
+	submitted, err := rc.previousSubmission(hash)
+	if err != nil {
+		return metrics.CreateUnknownErrorStatusCodeMetric(), err
+	}
+	if submitted != nil {
+		return metrics.StatusCodeMetric{Status: metrics.TcbRecoveryPending}, nil
+	}
+
+	metric, regErr := intelService.RegisterPlatform(ctx, plaformManifest, rc.metricsRegistry)
+	if metric.Status == metrics.PlatformRebootNeeded {
+		// nothing is written to UEFI for TCB recovery
+		rc.recordSubmission(submission{SHA256: hash, Kind: submissionTcbRecovery, SubmittedAt: time.Now(), Persisted: true,
+			Request: plaformManifest})
+		return metrics.StatusCodeMetric{Status: metrics.TcbRecoveryPending}, nil
+	}
+	return metric, regErr
+}
+
+// addPackage registers an added CPU package with Intel and hands the response over to the BIOS.
+// The response of a request already accepted by Intel is taken from the state instead of sending it again.
+func (rc *DefaultRegistrationChecker) addPackage(ctx context.Context, intelService *intelservices.IntelService, addPackageRequest mpmanagement.AddPackageRequest) (metrics.StatusCodeMetric, error) {
+	rc.log.Info("Pending AddPackage request found", zap.Int("size", len(addPackageRequest)))
+	if err := rc.validateRequest(addPackageRequest, platformmanifest.AddRequestGUID); err != nil {
+		return metrics.StatusCodeMetric{Status: metrics.InvalidPlatformManifest}, err
+	}
+
+	hash := requestHash(addPackageRequest)
+	submitted, err := rc.previousSubmission(hash)
+	if err != nil {
+		return metrics.CreateUnknownErrorStatusCodeMetric(), err
+	}
+
+	var response []byte
+	if submitted != nil {
+		if len(submitted.ServerResponse) == 0 {
+			return metrics.CreateUnknownErrorStatusCodeMetric(),
+				fmt.Errorf("AddPackage request %s was already submitted but its response is not in the registration state", hash)
+		}
+		response = submitted.ServerResponse
+	} else {
+		var metric metrics.StatusCodeMetric
+		metric, response, err = intelService.AddPackage(ctx, addPackageRequest, rc.metricsRegistry)
+		if metric.Status != metrics.PackageAddedRebootNeeded {
+			return metric, err
+		}
+		rc.recordSubmission(submission{SHA256: hash, Kind: submissionAddPackage, SubmittedAt: time.Now(), ServerResponse: response})
+	}
+
+	if err := rc.persistRegistration(hash, response); err != nil {
+		return metrics.StatusCodeMetric{Status: metrics.UefiPersistFailed}, err
+	}
+	return metrics.StatusCodeMetric{Status: metrics.PackageAddedRebootNeeded}, nil
+}
+
+// previousSubmission returns the submission of an already accepted request, or nil if it must be sent.
+// Forcing the resubmission ignores the recorded submissions.
+func (rc *DefaultRegistrationChecker) previousSubmission(hash string) (*submission, error) {
+	submitted, err := rc.submissions.lookup(hash)
+	if err != nil {
+		return nil, err
+	}
+	if submitted == nil {
+		return nil, nil
+	}
+	if rc.regServiceConfig.ForceResubmit {
+		rc.log.Warn("Pending UEFI request was already submitted, sending it again as forced",
+			zap.String("sha256", hash),
+			zap.Time("submittedAt", submitted.SubmittedAt))
+		return nil, nil
+	}
+	rc.log.Info("Pending UEFI request was already submitted, not sending it again",
+		zap.String("sha256", hash),
+		zap.String("kind", submitted.Kind),
+		zap.Time("submittedAt", submitted.SubmittedAt))
+	return submitted, nil
+}
+
+// recordSubmission saves an accepted request. A failure is only logged, the UEFI write still being attempted.
+func (rc *DefaultRegistrationChecker) recordSubmission(sub submission) {
+	if err := rc.submissions.record(sub); err != nil {
+		rc.log.Error("unable to record the submitted UEFI request", zap.String("sha256", sub.SHA256), zap.Error(err))
+	}
+}
+
+// persistRegistration writes the outcome of an accepted request to UEFI and reads the registration
+// status back to confirm that the write stuck
+func (rc *DefaultRegistrationChecker) persistRegistration(hash string, serverResponse []byte) error {
+	if serverResponse != nil {
+		if err := rc.manifestSource.SetServerResponse(serverResponse); err != nil {
+			return err
+		}
+	}
+	if err := rc.manifestSource.CompleteMachineRegistrationStatus(); err != nil {
+		return err
+	}
+
+	registered, err := rc.manifestSource.IsMachineRegistered()
+	if err != nil {
+		return fmt.Errorf("failed to read back the registration status: %w", err)
+	}
+	if !registered {
+		return errors.New("registration status read back after the UEFI write is not complete")
+	}
+
+	if err := rc.submissions.markPersisted(hash); err != nil {
+		rc.log.Warn("unable to record the UEFI write of the submitted request", zap.String("sha256", hash), zap.Error(err))
+	}
+	return nil
+}
+
+// validateRequest decodes a pending UEFI request and checks its sizes and versions before it is sent to Intel
+func (rc *DefaultRegistrationChecker) validateRequest(request []byte, expectedGUID platformmanifest.GUID) error {
+	manifest, err := platformmanifest.Parse(request)
+	if err != nil {
+		return fmt.Errorf("failed to parse pending UEFI request: %w", err)
+	}
+	if manifest.Header.GUID != expectedGUID {
+		return fmt.Errorf("%w: pending UEFI request has GUID %s, expected %s",
+			platformmanifest.ErrInvalidManifest, manifest.Header.GUID, expectedGUID)
+	}
+
+	fields := []zap.Field{
+		zap.Bool("addRequest", manifest.IsAddRequest()),
+		zap.Int("packages", manifest.PackageCount()),
+		zap.Int("pairingReceipts", manifest.PairingReceiptCount()),
+	}
+	if platformInfo, err := manifest.PlatformInfo(); err == nil {
+		fields = append(fields, zap.String("platformInstanceId", hex.EncodeToString(platformInfo.PlatformInstanceID[:])))
+	}
+	rc.log.Info("Pending UEFI request validated", fields...)
+	return nil
 }
 
 type RegistrationService struct {
@@ -80,6 +445,7 @@ type RegistrationService struct {
 	serverMetrics       *metrics.RegistrationServiceMetricsRegistry
 	log                 *zap.Logger
 	registrationChecker RegistrationChecker
+	status              statusHolder
 }
 
 func (r *RegistrationService) Run(ctx context.Context) error {
@@ -88,9 +454,10 @@ func (r *RegistrationService) Run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	r.status.set(Status{StatusCode: metrics.Pending, Description: metrics.Pending.String()})
 
 	// first check
-	r.CheckRegistrationStatus()
+	r.CheckRegistrationStatus(ctx)
 
 	ticker := time.NewTicker(r.intervalDuration)
 	defer ticker.Stop()
@@ -98,31 +465,51 @@ func (r *RegistrationService) Run(ctx context.Context) error {
 	for {
 		select {
 		case <-ticker.C:
-			r.CheckRegistrationStatus()
+			r.CheckRegistrationStatus(ctx)
 		case <-ctx.Done():
 			return nil
 		}
 	}
 }
 
-func (r *RegistrationService) CheckRegistrationStatus() {
-	statusCodeMetric, err := r.registrationChecker.Check()
+func (r *RegistrationService) CheckRegistrationStatus(ctx context.Context) {
+	statusCodeMetric, err := r.registrationChecker.Check(ctx)
 	if err != nil {
 		r.log.Error("unable to get the registration status", zap.Error(err))
 	}
 	r.log.Debug("Registration check completed", zap.String("status", statusCodeMetric.Status.String()))
+	r.updateStatus(statusCodeMetric, err)
 	err = r.serverMetrics.UpdateServiceStatusCodeMetric(statusCodeMetric)
 	if err != nil {
 		r.log.Error("unable to update registration service status code metric", zap.Error(err))
 	}
 }
 
-func NewRegistrationService(logger *zap.Logger, cfg *config.RegistrationServiceConfig, intervalDuration time.Duration) *RegistrationService {
+// updateStatus records the outcome of a check for the status endpoint
+func (r *RegistrationService) updateStatus(statusCodeMetric metrics.StatusCodeMetric, checkErr error) {
+	status := Status{
+		StatusCode:     statusCodeMetric.Status,
+		Description:    statusCodeMetric.Status.String(),
+		HttpStatusCode: statusCodeMetric.HttpStatusCode,
+		IntelError:     statusCodeMetric.IntelError,
+		LastCheck:      time.Now(),
+	}
+	if checkErr != nil {
+		status.Error = checkErr.Error()
+	}
+	if provider, ok := r.registrationChecker.(statusDetailsProvider); ok {
+		status.StatusDetails = provider.StatusDetails()
+	}
+	r.status.set(status)
+}
+
+func NewRegistrationService(logger *zap.Logger, cfg *config.RegistrationServiceConfig, intervalDuration time.Duration,
+	manifestSource PlatformManifestSource, platformInfoProvider PlatformInfoProvider) *RegistrationService {
 	metricsRegistry := metrics.NewRegistrationServiceMetricsRegistry(logger)
 
 	registrationService := &RegistrationService{
 		serverMetrics:       metricsRegistry,
-		registrationChecker: NewRegistrationChecker(logger, cfg, metricsRegistry),
+		registrationChecker: NewRegistrationChecker(logger, cfg, metricsRegistry, manifestSource, platformInfoProvider),
 		log:                 logger,
 		intervalDuration:    intervalDuration,
 	}
@@ -2,16 +2,42 @@ package registration
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	enclaveidentity "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/enclave_identity"
+	fakepcs "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/fake_pcs"
+	fakeplatform "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/fake_platform"
+	mpmanagement "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_management"
+	pckcert "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/pck_cert"
+	platformmanifest "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/platform_manifest"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/secret"
+	sgxplatforminfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/sgx_platform_info"
+	tcbinfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/tcb_info"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/config"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/constants"
+	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
 	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -20,7 +46,7 @@ type TestRegistrationChecker struct {
 	counter     int
 }
 
-func (rc *TestRegistrationChecker) Check() (metrics.StatusCodeMetric, error) {
+func (rc *TestRegistrationChecker) Check(ctx context.Context) (metrics.StatusCodeMetric, error) {
 	if rc.counter == len(rc.metricSteps) {
 		rc.counter = 0
 	}
@@ -128,6 +154,1993 @@ func TestRegistrationServiceRun(t *testing.T) {
 
 }
 
+func newTestPlatformInfo() *sgxplatforminfo.SgxPlatformInfo {
+	info := &sgxplatforminfo.SgxPlatformInfo{
+		EncryptedPPID: "aabbcc",
+		QeId:          "00112233445566778899aabbccddeeff",
+		CpuSvn:        "0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f",
+	}
+	info.PCEInfo.PCEID = "0000"
+	info.PCEInfo.PCEisvsvn = "000d"
+	return info
+}
+
+// newTestCA creates a fake PCK CA and writes its root CA, which overrides the Intel SGX Root CA
+func newTestCA(t *testing.T) (*fakepcs.CA, string) {
+	t.Helper()
+	ca, err := fakepcs.NewCA()
+	if err != nil {
+		t.Fatalf("failed to create test CA: %v", err)
+	}
+	rootCAPath := filepath.Join(t.TempDir(), "root-ca.pem")
+	if err := os.WriteFile(rootCAPath, ca.RootPEM, 0o600); err != nil {
+		t.Fatalf("failed to write test root CA: %v", err)
+	}
+	return ca, rootCAPath
+}
+
+// newTestSecret returns a secret file holding value and its path, to rotate it by writing the path again
+func newTestSecret(t *testing.T, value string) (*secret.File, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte(value), 0o600); err != nil {
+		t.Fatalf("failed to write test secret: %v", err)
+	}
+	f, err := secret.Load(path)
+	if err != nil {
+		t.Fatalf("failed to load test secret: %v", err)
+	}
+	return f, path
+}
+
+func newTestPCK() fakepcs.PCK {
+	return fakepcs.PCK{
+		FMSPC:  "00906ed50000",
+		CPUSVN: "0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f",
+		PCESVN: "0d00",
+		PCEID:  "0000",
+	}
+}
+
+func TestDefaultRegistrationCheckerCheck(t *testing.T) {
+	manifest := newTestRequest(t, platformmanifest.PlatformManifestGUID)
+	addPackageRequest := newTestRequest(t, platformmanifest.AddRequestGUID)
+	membershipCertificates := []byte("membership-certificates")
+	ca, rootCAPath := newTestCA(t)
+
+	cases := []struct {
+		msg                   string
+		registered            bool
+		pendingTcbRecovery    bool
+		pendingAddPackage     bool
+		nothingPending        bool
+		malformedRequest      bool
+		isRegisteredErr       error
+		completeErr           error
+		setResponseErr        error
+		registrationStatus    int
+		addPackageStatus      int
+		pckStatus             int
+		pckOtherPlatform      bool
+		wantedStatus          metrics.StatusCode
+		wantedRegistered      bool
+		wantedServerResponse  []byte
+		wantedPosts           int
+		wantedAddPackagePosts int
+		wantedGets            int
+	}{
+		{
+			msg:                "unregistered platform is registered and flagged as complete",
+			registrationStatus: http.StatusCreated,
+			wantedStatus:       metrics.PlatformRebootNeeded,
+			wantedRegistered:   true,
+			wantedPosts:        1,
+		},
+		{
+			msg:                "rejected registration leaves the platform unregistered",
+			registrationStatus: http.StatusBadRequest,
+			wantedStatus:       metrics.InvalidRegistrationRequest,
+			wantedPosts:        1,
+		},
+		{
+			msg:                "failed UEFI write is reported",
+			registrationStatus: http.StatusCreated,
+			completeErr:        errors.New("write failed"),
+			wantedStatus:       metrics.UefiPersistFailed,
+			wantedPosts:        1,
+		},
+		{
+			msg:              "registered platform retrieves its PCK certificate",
+			registered:       true,
+			pckStatus:        http.StatusOK,
+			wantedStatus:     metrics.PlatformDirectlyRegistered,
+			wantedRegistered: true,
+			wantedGets:       1,
+		},
+		{
+			msg:              "PCK certificate of another platform is rejected",
+			registered:       true,
+			pckStatus:        http.StatusOK,
+			pckOtherPlatform: true,
+			wantedStatus:     metrics.InvalidPCKCertificate,
+			wantedRegistered: true,
+			wantedGets:       1,
+		},
+		{
+			msg:              "unknown platform needs an SGX reset",
+			registered:       true,
+			pckStatus:        http.StatusNotFound,
+			wantedStatus:     metrics.SgxResetNeeded,
+			wantedRegistered: true,
+			wantedGets:       1,
+		},
+		{
+			msg:                "pending TCB recovery manifest is registered although the platform is flagged as registered",
+			registered:         true,
+			pendingTcbRecovery: true,
+			registrationStatus: http.StatusCreated,
+			wantedStatus:       metrics.TcbRecoveryPending,
+			wantedRegistered:   true,
+			wantedPosts:        1,
+		},
+		{
+			msg:                "rejected TCB recovery manifest is reported",
+			registered:         true,
+			pendingTcbRecovery: true,
+			registrationStatus: http.StatusBadRequest,
+			wantedStatus:       metrics.InvalidRegistrationRequest,
+			wantedRegistered:   true,
+			wantedPosts:        1,
+		},
+		{
+			msg:            "unregistered platform without pending request is reported",
+			nothingPending: true,
+			wantedStatus:   metrics.SgxUefiUnavailable,
+		},
+		{
+			msg:              "malformed platform manifest is not sent",
+			malformedRequest: true,
+			wantedStatus:     metrics.InvalidPlatformManifest,
+		},
+		{
+			msg:               "malformed AddPackage request is not sent",
+			pendingAddPackage: true,
+			malformedRequest:  true,
+			wantedStatus:      metrics.InvalidPlatformManifest,
+		},
+		{
+			msg:             "unavailable UEFI variables are reported",
+			isRegisteredErr: errors.New("efivars not mounted"),
+			wantedStatus:    metrics.SgxUefiUnavailable,
+		},
+		{
+			msg:                   "pending AddPackage request is sent and the response written back",
+			pendingAddPackage:     true,
+			addPackageStatus:      http.StatusOK,
+			wantedStatus:          metrics.PackageAddedRebootNeeded,
+			wantedRegistered:      true,
+			wantedServerResponse:  membershipCertificates,
+			wantedAddPackagePosts: 1,
+		},
+		{
+			msg:                   "rejected AddPackage request is reported",
+			pendingAddPackage:     true,
+			addPackageStatus:      http.StatusBadRequest,
+			wantedStatus:          metrics.InvalidAddPackageRequest,
+			wantedAddPackagePosts: 1,
+		},
+		{
+			msg:                   "failed AddPackage request is reported",
+			pendingAddPackage:     true,
+			addPackageStatus:      http.StatusServiceUnavailable,
+			wantedStatus:          metrics.IntelAddPackageRequestFailed,
+			wantedAddPackagePosts: 1,
+		},
+		{
+			msg:                   "failed server response write is reported",
+			pendingAddPackage:     true,
+			addPackageStatus:      http.StatusOK,
+			setResponseErr:        errors.New("write failed"),
+			wantedStatus:          metrics.UefiPersistFailed,
+			wantedAddPackagePosts: 1,
+		},
+	}
+
+	for _, c := range cases {
+		posts, addPackagePosts, gets := 0, 0, 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/sgx/registration/v1/platform":
+				posts++
+				body, _ := io.ReadAll(r.Body)
+				assert.Equal(t, manifest, body, c.msg)
+				w.WriteHeader(c.registrationStatus)
+			case r.URL.Path == "/sgx/registration/v1/package":
+				addPackagePosts++
+				body, _ := io.ReadAll(r.Body)
+				assert.Equal(t, addPackageRequest, body, c.msg)
+				w.WriteHeader(c.addPackageStatus)
+				if c.addPackageStatus == http.StatusOK {
+					_, _ = w.Write(membershipCertificates)
+				}
+			case r.Method == http.MethodGet:
+				gets++
+				assert.Equal(t, "aabbcc", r.URL.Query().Get("encrypted_ppid"), c.msg)
+				if c.pckStatus == http.StatusOK {
+					pck := newTestPCK()
+					if c.pckOtherPlatform {
+						pck.PCEID = "0001"
+					}
+					ca.WritePCKResponse(w, pck)
+					return
+				}
+				w.WriteHeader(c.pckStatus)
+			}
+		}))
+
+		cfg := &config.RegistrationServiceConfig{
+			IntelRegistrationURL: server.URL + "/sgx/registration/v1/platform",
+			IntelAddPackageURL:   server.URL + "/sgx/registration/v1/package",
+			IntelPCKRetrievalURL: server.URL + "/sgx/certification/v4/pckcert",
+			RequestTimeout:       5 * time.Second,
+			SGXRootCAPath:        rootCAPath,
+		}
+		manifestSource := fakeplatform.NewManifestSource(manifest)
+		if (c.registered && !c.pendingTcbRecovery) || c.nothingPending {
+			manifestSource.Manifest = nil
+		}
+		if c.pendingAddPackage {
+			manifestSource.Manifest = nil
+			manifestSource.AddPackageRequest = addPackageRequest
+		}
+		if c.malformedRequest {
+			manifestSource.Manifest = manifestSource.Manifest[:len(manifestSource.Manifest)/2]
+			manifestSource.AddPackageRequest = manifestSource.AddPackageRequest[:len(manifestSource.AddPackageRequest)/2]
+		}
+		manifestSource.Registered = c.registered
+		manifestSource.IsMachineRegisteredErr = c.isRegisteredErr
+		manifestSource.CompleteErr = c.completeErr
+		manifestSource.SetServerResponseErr = c.setResponseErr
+		infoProvider := fakeplatform.NewInfoProvider(newTestPlatformInfo())
+
+		logger := zap.NewNop()
+		checker := NewRegistrationChecker(logger, cfg, metrics.NewRegistrationServiceMetricsRegistry(logger), manifestSource, infoProvider)
+		metric, _ := checker.Check(t.Context())
+		server.Close()
+
+		assert.Equal(t, c.wantedStatus, metric.Status, c.msg)
+		assert.Equal(t, c.wantedRegistered, manifestSource.Registered, c.msg)
+		assert.Equal(t, c.wantedServerResponse, manifestSource.ServerResponse, c.msg)
+		assert.Equal(t, c.wantedPosts, posts, c.msg)
+		assert.Equal(t, c.wantedAddPackagePosts, addPackagePosts, c.msg)
+		assert.Equal(t, c.wantedGets, gets, c.msg)
+	}
+}
+
+func TestRegistrationServicePCKCertificate(t *testing.T) {
+	ca, rootCAPath := newTestCA(t)
+	notAfter := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second).UTC()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		pck := newTestPCK()
+		pck.NotAfter = notAfter
+		ca.WritePCKResponse(w, pck)
+	}))
+	defer server.Close()
+
+	logger := zap.NewNop()
+	manifestSource := fakeplatform.NewManifestSource(nil)
+	manifestSource.Registered = true
+	cfg := &config.RegistrationServiceConfig{
+		IntelPCKRetrievalURL: server.URL + "/sgx/certification/v4/pckcert",
+		RequestTimeout:       5 * time.Second,
+		SGXRootCAPath:        rootCAPath,
+	}
+	registrationService := NewRegistrationService(logger, cfg, time.Minute, manifestSource,
+		fakeplatform.NewInfoProvider(newTestPlatformInfo()))
+	registrationService.CheckRegistrationStatus(t.Context())
+
+	status := registrationService.Status()
+	assert.Equal(t, metrics.PlatformDirectlyRegistered, status.StatusCode)
+	assert.Equal(t, "00906ed50000", status.PCKCertificate.FMSPC)
+	assert.Equal(t, "0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0d00", status.PCKCertificate.TCBm)
+	assert.Equal(t, "processor", status.PCKCertificate.CAType)
+	assert.Equal(t, "Intel SGX PCK Processor CA", status.PCKCertificate.Issuer)
+	assert.Equal(t, notAfter, status.PCKCertificate.NotAfter)
+	assert.Equal(t, float64(notAfter.Unix()), testutil.ToFloat64(metrics.PCKCertificateExpiryMetric))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.PCKCertificateInfoMetric.WithLabelValues("00906ed50000", "processor")))
+}
+
+func TestCheckCancellation(t *testing.T) {
+	var intelRequests, pccsRequests atomic.Int32
+	// both servers hold the requests until the client gives up, which is only noticed once the body is read
+	intel := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		intelRequests.Add(1)
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer intel.Close()
+	pccs := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		pccsRequests.Add(1)
+		<-r.Context().Done()
+	}))
+	defer pccs.Close()
+
+	cases := []struct {
+		msg                     string
+		registered              bool
+		pccs                    bool
+		checkTimeout            time.Duration
+		cancelAfter             time.Duration
+		wantedStatus            metrics.StatusCode
+		wantedIntelRequests     int32
+		wantedPCCSRequests      int32
+		wantedPlatformInfoCalls int
+	}{
+		{
+			msg:                 "registration waiting for Intel is cancelled",
+			cancelAfter:         50 * time.Millisecond,
+			wantedStatus:        metrics.CheckCancelled,
+			wantedIntelRequests: 1,
+		},
+		{
+			msg:                 "registration waiting for Intel times out",
+			checkTimeout:        50 * time.Millisecond,
+			wantedStatus:        metrics.CheckTimedOut,
+			wantedIntelRequests: 1,
+		},
+		{
+			msg:                     "Intel is not tried once the PCK retrieval from the PCCS is cancelled",
+			registered:              true,
+			pccs:                    true,
+			cancelAfter:             50 * time.Millisecond,
+			wantedStatus:            metrics.CheckCancelled,
+			wantedPCCSRequests:      1,
+			wantedPlatformInfoCalls: 1,
+		},
+		{
+			msg:          "check cancelled before it starts reads nothing",
+			registered:   true,
+			wantedStatus: metrics.CheckCancelled,
+		},
+	}
+
+	for _, c := range cases {
+		intelRequests.Store(0)
+		pccsRequests.Store(0)
+		cfg := &config.RegistrationServiceConfig{
+			IntelRegistrationURL: intel.URL + "/sgx/registration/v1/platform",
+			IntelPCKRetrievalURL: intel.URL + "/sgx/certification/v4/pckcert",
+			RequestTimeout:       5 * time.Second,
+			CheckTimeout:         c.checkTimeout,
+		}
+		if c.pccs {
+			cfg.PCCSURLs = []string{pccs.URL}
+		}
+		manifestSource := fakeplatform.NewManifestSource(newTestRequest(t, platformmanifest.PlatformManifestGUID))
+		if c.registered {
+			manifestSource.Manifest = nil
+			manifestSource.Registered = true
+		}
+		infoProvider := fakeplatform.NewInfoProvider(newTestPlatformInfo())
+		logger := zap.NewNop()
+		checker := NewRegistrationChecker(logger, cfg, metrics.NewRegistrationServiceMetricsRegistry(logger), manifestSource, infoProvider)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		if c.cancelAfter > 0 {
+			time.AfterFunc(c.cancelAfter, cancel)
+		} else if c.checkTimeout == 0 {
+			cancel()
+		}
+		start := time.Now()
+		metric, err := checker.Check(ctx)
+		cancel()
+
+		assert.Error(t, err, c.msg)
+		assert.Equal(t, c.wantedStatus, metric.Status, c.msg)
+		assert.Less(t, time.Since(start), cfg.RequestTimeout, c.msg)
+		assert.False(t, manifestSource.Registered && !c.registered, c.msg)
+		assert.Equal(t, c.wantedIntelRequests, intelRequests.Load(), c.msg)
+		assert.Equal(t, c.wantedPCCSRequests, pccsRequests.Load(), c.msg)
+		assert.Equal(t, c.wantedPlatformInfoCalls, infoProvider.Calls, c.msg)
+	}
+}
+
+func TestRequestRetry(t *testing.T) {
+	ca, rootCAPath := newTestCA(t)
+	cases := []struct {
+		msg            string
+		registered     bool
+		responses      []int
+		retryAfter     string
+		noDeadline     bool
+		wantedStatus   metrics.StatusCode
+		wantedRequests int
+		wantedRetries  map[string]float64
+	}{
+		{
+			msg:            "rate limited registration is retried after Retry-After",
+			responses:      []int{http.StatusTooManyRequests, http.StatusCreated},
+			retryAfter:     "0",
+			wantedStatus:   metrics.PlatformRebootNeeded,
+			wantedRequests: 2,
+			wantedRetries:  map[string]float64{"429": 1},
+		},
+		{
+			msg:            "unavailable registration is retried with backoff",
+			responses:      []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusCreated},
+			wantedStatus:   metrics.PlatformRebootNeeded,
+			wantedRequests: 3,
+			wantedRetries:  map[string]float64{"503": 2},
+		},
+		{
+			msg:            "server error of a registration is not retried",
+			responses:      []int{http.StatusInternalServerError, http.StatusCreated},
+			wantedStatus:   metrics.IntelRegServiceRequestFailed,
+			wantedRequests: 1,
+		},
+		{
+			msg:            "server error of a PCK retrieval is retried",
+			registered:     true,
+			responses:      []int{http.StatusBadGateway, http.StatusOK},
+			wantedStatus:   metrics.PlatformDirectlyRegistered,
+			wantedRequests: 2,
+			wantedRetries:  map[string]float64{"502": 1},
+		},
+		{
+			msg:            "retries stop after the maximum attempts",
+			responses:      []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusCreated},
+			wantedStatus:   metrics.IntelRegServiceRequestFailed,
+			wantedRequests: 3,
+			wantedRetries:  map[string]float64{"503": 2},
+		},
+		{
+			msg:            "Retry-After beyond the check deadline is not waited for",
+			responses:      []int{http.StatusServiceUnavailable, http.StatusCreated},
+			retryAfter:     "60",
+			wantedStatus:   metrics.IntelRegServiceRequestFailed,
+			wantedRequests: 1,
+		},
+		{
+			msg:            "Retry-After beyond the maximum backoff is not waited for without check deadline",
+			responses:      []int{http.StatusServiceUnavailable, http.StatusCreated},
+			retryAfter:     "86400",
+			noDeadline:     true,
+			wantedStatus:   metrics.IntelRegServiceRequestFailed,
+			wantedRequests: 1,
+		},
+		{
+			msg:            "far-future Retry-After date is not waited for without check deadline",
+			responses:      []int{http.StatusServiceUnavailable, http.StatusCreated},
+			retryAfter:     time.Now().Add(24 * time.Hour).UTC().Format(http.TimeFormat),
+			noDeadline:     true,
+			wantedStatus:   metrics.IntelRegServiceRequestFailed,
+			wantedRequests: 1,
+		},
+	}
+
+	for _, c := range cases {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			status := c.responses[min(requests, len(c.responses)-1)]
+			requests++
+			switch {
+			case status == http.StatusOK:
+				ca.WritePCKResponse(w, newTestPCK())
+			default:
+				if c.retryAfter != "" {
+					w.Header().Set("Retry-After", c.retryAfter)
+				}
+				w.WriteHeader(status)
+			}
+		}))
+		host := strings.TrimPrefix(server.URL, "http://")
+
+		cfg := &config.RegistrationServiceConfig{
+			IntelRegistrationURL: server.URL + "/sgx/registration/v1/platform",
+			IntelPCKRetrievalURL: server.URL + "/sgx/certification/v4/pckcert",
+			RequestTimeout:       5 * time.Second,
+			CheckTimeout:         5 * time.Second,
+			RetryMaxAttempts:     3,
+			RetryInitialBackoff:  time.Millisecond,
+			RetryMaxBackoff:      5 * time.Millisecond,
+			SGXRootCAPath:        rootCAPath,
+		}
+		if c.noDeadline {
+			cfg.CheckTimeout = 0
+		}
+		manifestSource := fakeplatform.NewManifestSource(newTestRequest(t, platformmanifest.PlatformManifestGUID))
+		if c.registered {
+			manifestSource.Manifest = nil
+			manifestSource.Registered = true
+		}
+		logger := zap.NewNop()
+		checker := NewRegistrationChecker(logger, cfg, metrics.NewRegistrationServiceMetricsRegistry(logger), manifestSource,
+			fakeplatform.NewInfoProvider(newTestPlatformInfo()))
+
+		start := time.Now()
+		metric, _ := checker.Check(t.Context())
+		server.Close()
+
+		assert.Equal(t, c.wantedStatus, metric.Status, c.msg)
+		assert.Equal(t, c.wantedRequests, requests, c.msg)
+		assert.Less(t, time.Since(start), 5*time.Second, c.msg)
+		for _, reason := range []string{"429", "502", "503"} {
+			assert.Equal(t, c.wantedRetries[reason], testutil.ToFloat64(metrics.RequestRetriesMetric.WithLabelValues(host, reason)), c.msg)
+		}
+	}
+}
+
+func TestPCCSCircuitBreaker(t *testing.T) {
+	ca, rootCAPath := newTestCA(t)
+	foreignCA, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	cases := []struct {
+		msg             string
+		failures        int  // requests the flaky PCCS fails before it recovers
+		invalidPCK      bool // the flaky PCCS answers with a PCK certificate failing verification
+		breakerFailures int
+		checks          int
+		wantedRequests  int
+		wantedState     intelservices.BreakerState
+	}{
+		{
+			msg:            "failing PCCS is tried on every check without breaker",
+			failures:       100,
+			checks:         3,
+			wantedRequests: 7,
+			wantedState:    intelservices.BreakerClosed,
+		},
+		{
+			msg:             "failing PCCS is skipped once its breaker opened",
+			failures:        100,
+			breakerFailures: 2,
+			checks:          3,
+			wantedRequests:  2,
+			wantedState:     intelservices.BreakerOpen,
+		},
+		{
+			msg:             "PCCS answering with invalid PCK certificates is skipped once its breaker opened",
+			invalidPCK:      true,
+			breakerFailures: 2,
+			checks:          3,
+			wantedRequests:  4, // the PCK certificate and CRL of the first two checks
+			wantedState:     intelservices.BreakerOpen,
+		},
+	}
+
+	for _, c := range cases {
+		requests := 0
+		flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			switch {
+			case requests <= c.failures:
+				w.WriteHeader(http.StatusServiceUnavailable)
+			case strings.HasSuffix(r.URL.Path, "/pckcrl"):
+				ca.WriteCRLResponse(w, time.Now().Add(time.Hour))
+			case strings.HasSuffix(r.URL.Path, "/pckcert") && c.invalidPCK:
+				foreignCA.WritePCKResponse(w, newTestPCK())
+			case strings.HasSuffix(r.URL.Path, "/pckcert"):
+				ca.WritePCKResponse(w, newTestPCK())
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/pckcrl"):
+				ca.WriteCRLResponse(w, time.Now().Add(time.Hour))
+			case strings.HasSuffix(r.URL.Path, "/pckcert"):
+				ca.WritePCKResponse(w, newTestPCK())
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		manifestSource := fakeplatform.NewManifestSource(nil)
+		manifestSource.Registered = true
+		cfg := &config.RegistrationServiceConfig{
+			PCCSURLs:            []string{flaky.URL, healthy.URL},
+			PCCSBreakerFailures: c.breakerFailures,
+			// the cool-down never elapses during the test, see TestEndpointHealth for the half-open breaker
+			PCCSBreakerCoolDown: time.Hour,
+			RequestTimeout:      5 * time.Second,
+			SGXRootCAPath:       rootCAPath,
+		}
+		logger := zap.NewNop()
+		checker := NewRegistrationChecker(logger, cfg, metrics.NewRegistrationServiceMetricsRegistry(logger),
+			manifestSource, fakeplatform.NewInfoProvider(newTestPlatformInfo()))
+		for range c.checks {
+			metric, err := checker.Check(t.Context())
+			assert.NoError(t, err, c.msg)
+			assert.Equal(t, metrics.PlatformDirectlyRegistered, metric.Status, c.msg)
+		}
+		flaky.Close()
+		healthy.Close()
+
+		assert.Equal(t, c.wantedRequests, requests, c.msg)
+		assert.Equal(t, float64(c.wantedState), testutil.ToFloat64(metrics.PCCSBreakerStateMetric.WithLabelValues(flaky.URL)), c.msg)
+		assert.Equal(t, float64(intelservices.BreakerClosed), testutil.ToFloat64(metrics.PCCSBreakerStateMetric.WithLabelValues(healthy.URL)), c.msg)
+	}
+}
+
+func TestPCCSOrder(t *testing.T) {
+	ca, rootCAPath := newTestCA(t)
+	cases := []struct {
+		msg          string
+		order        string
+		weights      []int
+		uncached     int // PCCS not serving the PCK certificate of the platform
+		checks       int
+		wantedHits   []int
+		wantedSpread bool // every PCCS is tried first at least once
+	}{
+		{
+			msg:        "PCCS are tried in the configured order",
+			uncached:   -1,
+			checks:     3,
+			wantedHits: []int{3, 0, 0},
+		},
+		{
+			msg:        "PCCS are tried in the configured order after a miss",
+			uncached:   0,
+			checks:     3,
+			wantedHits: []int{3, 3, 0},
+		},
+		{
+			msg:        "PCCS that answered last is tried first",
+			order:      constants.PCCSOrderSticky,
+			uncached:   0,
+			checks:     3,
+			wantedHits: []int{1, 3, 0},
+		},
+		{
+			msg:        "PCCS of a prevailing weight is tried first",
+			order:      constants.PCCSOrderWeighted,
+			weights:    []int{1, 1_000_000_000, 1},
+			uncached:   -1,
+			checks:     10,
+			wantedHits: []int{0, 10, 0},
+		},
+		{
+			msg:          "PCCS are tried in random order",
+			order:        constants.PCCSOrderRandom,
+			uncached:     -1,
+			checks:       50,
+			wantedSpread: true,
+		},
+	}
+
+	for _, c := range cases {
+		hits := make([]int, 3)
+		var pccsURLs []string
+		for i := range hits {
+			pccs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/pckcrl"):
+					ca.WriteCRLResponse(w, time.Now().Add(time.Hour))
+				case strings.HasSuffix(r.URL.Path, "/pckcert"):
+					hits[i]++
+					if i == c.uncached {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					ca.WritePCKResponse(w, newTestPCK())
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer pccs.Close()
+			pccsURLs = append(pccsURLs, pccs.URL)
+		}
+
+		manifestSource := fakeplatform.NewManifestSource(nil)
+		manifestSource.Registered = true
+		cfg := &config.RegistrationServiceConfig{
+			PCCSURLs:       pccsURLs,
+			PCCSOrder:      c.order,
+			PCCSWeights:    c.weights,
+			RequestTimeout: 5 * time.Second,
+			SGXRootCAPath:  rootCAPath,
+		}
+		logger := zap.NewNop()
+		checker := NewRegistrationChecker(logger, cfg, metrics.NewRegistrationServiceMetricsRegistry(logger),
+			manifestSource, fakeplatform.NewInfoProvider(newTestPlatformInfo()))
+		for range c.checks {
+			metric, err := checker.Check(t.Context())
+			assert.NoError(t, err, c.msg)
+			assert.Equal(t, metrics.PlatformDirectlyRegistered, metric.Status, c.msg)
+		}
+
+		if c.wantedSpread {
+			for i, hit := range hits {
+				assert.Positive(t, hit, "%s: PCCS %d", c.msg, i)
+			}
+		} else {
+			assert.Equal(t, c.wantedHits, hits, c.msg)
+		}
+	}
+}
+
+func TestPCKHedging(t *testing.T) {
+	ca, rootCAPath := newTestCA(t)
+	const (
+		slow    = "slow"    // answers once the request is cancelled
+		missing = "missing" // does not serve the PCK certificate of the platform
+		valid   = "valid"
+	)
+	cases := []struct {
+		msg             string
+		pccs            []string
+		hedgeDelay      time.Duration
+		wantedHits      []int32
+		wantedCancelled int32
+		wantedIntelHits int32
+	}{
+		{
+			msg:             "slow PCCS is overtaken by the next one after the hedge delay",
+			pccs:            []string{slow, valid},
+			hedgeDelay:      10 * time.Millisecond,
+			wantedHits:      []int32{1, 1},
+			wantedCancelled: 1,
+		},
+		{
+			msg:             "all PCCS are requested at once without hedge delay",
+			pccs:            []string{slow, slow, valid},
+			wantedHits:      []int32{1, 1, 1},
+			wantedCancelled: 2,
+		},
+		{
+			msg:        "failed PCCS starts the next one without waiting for the hedge delay",
+			pccs:       []string{missing, valid},
+			hedgeDelay: time.Hour,
+			wantedHits: []int32{1, 1},
+		},
+		{
+			msg:        "next PCCS is not requested once a certificate was returned",
+			pccs:       []string{valid, valid},
+			hedgeDelay: time.Hour,
+			wantedHits: []int32{1, 0},
+		},
+		{
+			msg:             "Intel is only requested once no PCCS returned a certificate",
+			pccs:            []string{missing, missing},
+			wantedHits:      []int32{1, 1},
+			wantedIntelHits: 1,
+		},
+	}
+
+	for _, c := range cases {
+		hits := make([]atomic.Int32, len(c.pccs))
+		var cancelled, intelHits atomic.Int32
+		var pccsURLs []string
+		for i, behavior := range c.pccs {
+			pccs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/pckcrl"):
+					ca.WriteCRLResponse(w, time.Now().Add(time.Hour))
+				case strings.HasSuffix(r.URL.Path, "/pckcert"):
+					hits[i].Add(1)
+					switch behavior {
+					case slow:
+						select {
+						case <-r.Context().Done():
+							cancelled.Add(1)
+						case <-time.After(5 * time.Second):
+						}
+						w.WriteHeader(http.StatusServiceUnavailable)
+					case missing:
+						w.WriteHeader(http.StatusNotFound)
+					default:
+						ca.WritePCKResponse(w, newTestPCK())
+					}
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			pccsURLs = append(pccsURLs, pccs.URL)
+			defer pccs.Close()
+		}
+		intel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/pckcert") {
+				intelHits.Add(1)
+				ca.WritePCKResponse(w, newTestPCK())
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}))
+
+		manifestSource := fakeplatform.NewManifestSource(nil)
+		manifestSource.Registered = true
+		cfg := &config.RegistrationServiceConfig{
+			PCCSURLs:             pccsURLs,
+			PCCSHedgePCK:         true,
+			PCCSHedgeDelay:       c.hedgeDelay,
+			IntelPCKRetrievalURL: intel.URL + "/sgx/certification/v4/pckcert",
+			RequestTimeout:       10 * time.Second,
+			SGXRootCAPath:        rootCAPath,
+		}
+		logger := zap.NewNop()
+		checker := NewRegistrationChecker(logger, cfg, metrics.NewRegistrationServiceMetricsRegistry(logger),
+			manifestSource, fakeplatform.NewInfoProvider(newTestPlatformInfo()))
+
+		start := time.Now()
+		metric, err := checker.Check(t.Context())
+		elapsed := time.Since(start)
+		intel.Close()
+		// the cancelled requests are answered once the client closed their connection
+		assert.Eventually(t, func() bool { return cancelled.Load() == c.wantedCancelled }, time.Second, 10*time.Millisecond, c.msg)
+
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, metrics.PlatformDirectlyRegistered, metric.Status, c.msg)
+		assert.Less(t, elapsed, 2*time.Second, c.msg)
+		for i := range hits {
+			assert.Equal(t, c.wantedHits[i], hits[i].Load(), "%s: PCCS %d", c.msg, i)
+		}
+		assert.Equal(t, c.wantedIntelHits, intelHits.Load(), c.msg)
+	}
+}
+
+func TestPCKVerificationFallback(t *testing.T) {
+	ca, rootCAPath := newTestCA(t)
+	untrustedCA, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+
+	cases := []struct {
+		msg          string
+		pccsCA       *fakepcs.CA
+		intelCA      *fakepcs.CA
+		wantedStatus metrics.StatusCode
+	}{
+		{
+			msg:          "certificate of an untrusted PCCS falls back to Intel",
+			pccsCA:       untrustedCA,
+			intelCA:      ca,
+			wantedStatus: metrics.PlatformDirectlyRegistered,
+		},
+		{
+			msg:          "untrusted certificates on all endpoints are reported",
+			pccsCA:       untrustedCA,
+			intelCA:      untrustedCA,
+			wantedStatus: metrics.InvalidPCKCertificate,
+		},
+	}
+
+	for _, c := range cases {
+		pccsGets, intelGets := 0, 0
+		pccs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/pckcrl"):
+				c.pccsCA.WriteCRLResponse(w, time.Now().Add(time.Hour))
+			case strings.HasSuffix(r.URL.Path, "/pckcert"):
+				pccsGets++
+				c.pccsCA.WritePCKResponse(w, newTestPCK())
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		intel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/pckcrl"):
+				c.intelCA.WriteCRLResponse(w, time.Now().Add(time.Hour))
+			case strings.HasSuffix(r.URL.Path, "/pckcert"):
+				intelGets++
+				c.intelCA.WritePCKResponse(w, newTestPCK())
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		manifestSource := fakeplatform.NewManifestSource(nil)
+		manifestSource.Registered = true
+		cfg := &config.RegistrationServiceConfig{
+			PCCSURLs:             []string{pccs.URL},
+			IntelPCKRetrievalURL: intel.URL + "/sgx/certification/v4/pckcert",
+			IntelPCKCRLURL:       intel.URL + "/sgx/certification/v4/pckcrl",
+			RequestTimeout:       5 * time.Second,
+			SGXRootCAPath:        rootCAPath,
+		}
+		logger := zap.NewNop()
+		checker := NewRegistrationChecker(logger, cfg, metrics.NewRegistrationServiceMetricsRegistry(logger),
+			manifestSource, fakeplatform.NewInfoProvider(newTestPlatformInfo()))
+		metric, err := checker.Check(t.Context())
+		pccs.Close()
+		intel.Close()
+
+		assert.Equal(t, c.wantedStatus, metric.Status, c.msg)
+		if c.wantedStatus == metrics.InvalidPCKCertificate {
+			assert.Error(t, err, c.msg)
+		}
+		assert.Equal(t, 1, pccsGets, c.msg)
+		assert.Equal(t, 1, intelGets, c.msg)
+	}
+}
+
+func TestPCKRevocation(t *testing.T) {
+	cases := []struct {
+		msg                 string
+		revoked             bool
+		crlStatus           int
+		nextUpdate          time.Duration
+		wantedStatus        metrics.StatusCode
+		wantedRevoked       *bool
+		wantedCRLGets       int
+		wantedRevokedMetric float64
+	}{
+		{
+			msg:                 "valid certificate is reported once the CRL is cached",
+			crlStatus:           http.StatusOK,
+			nextUpdate:          time.Hour,
+			wantedStatus:        metrics.PlatformDirectlyRegistered,
+			wantedRevoked:       new(false),
+			wantedCRLGets:       1,
+			wantedRevokedMetric: 0,
+		},
+		{
+			msg:                 "revoked certificate is reported",
+			revoked:             true,
+			crlStatus:           http.StatusOK,
+			nextUpdate:          time.Hour,
+			wantedStatus:        metrics.PCKCertificateRevoked,
+			wantedRevoked:       new(true),
+			wantedCRLGets:       1,
+			wantedRevokedMetric: 1,
+		},
+		{
+			msg:                 "outdated CRL is retrieved again",
+			crlStatus:           http.StatusOK,
+			nextUpdate:          -time.Second,
+			wantedStatus:        metrics.PlatformDirectlyRegistered,
+			wantedRevoked:       new(false),
+			wantedCRLGets:       2,
+			wantedRevokedMetric: 0,
+		},
+		{
+			msg:                 "unavailable CRL skips the revocation check",
+			crlStatus:           http.StatusServiceUnavailable,
+			wantedStatus:        metrics.PlatformDirectlyRegistered,
+			wantedCRLGets:       2,
+			wantedRevokedMetric: 0,
+		},
+	}
+
+	for _, c := range cases {
+		ca, rootCAPath := newTestCA(t)
+		// the PCS returns the same certificate on every request
+		pckResponse := httptest.NewRecorder()
+		ca.WritePCKResponse(pckResponse, newTestPCK())
+		if c.revoked {
+			block, _ := pem.Decode(pckResponse.Body.Bytes())
+			leaf, err := x509.ParseCertificate(block.Bytes)
+			assert.NoError(t, err, c.msg)
+			ca.Revoke(leaf.SerialNumber)
+		}
+
+		crlGets := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/sgx/certification/v4/pckcrl" {
+				crlGets++
+				assert.Equal(t, "processor", r.URL.Query().Get("ca"), c.msg)
+				if c.crlStatus != http.StatusOK {
+					w.WriteHeader(c.crlStatus)
+					return
+				}
+				ca.WriteCRLResponse(w, time.Now().Add(c.nextUpdate))
+				return
+			}
+			maps.Copy(w.Header(), pckResponse.Header())
+			w.WriteHeader(pckResponse.Code)
+			_, _ = w.Write(pckResponse.Body.Bytes())
+		}))
+
+		manifestSource := fakeplatform.NewManifestSource(nil)
+		manifestSource.Registered = true
+		cfg := &config.RegistrationServiceConfig{
+			IntelPCKRetrievalURL: server.URL + "/sgx/certification/v4/pckcert",
+			IntelPCKCRLURL:       server.URL + "/sgx/certification/v4/pckcrl",
+			RequestTimeout:       5 * time.Second,
+			SGXRootCAPath:        rootCAPath,
+		}
+		logger := zap.NewNop()
+		registrationService := NewRegistrationService(logger, cfg, time.Minute, manifestSource,
+			fakeplatform.NewInfoProvider(newTestPlatformInfo()))
+		registrationService.CheckRegistrationStatus(t.Context())
+		registrationService.CheckRegistrationStatus(t.Context())
+		server.Close()
+
+		status := registrationService.Status()
+		assert.Equal(t, c.wantedStatus, status.StatusCode, c.msg)
+		assert.Equal(t, c.wantedRevoked, status.PCKCertificate.Revoked, c.msg)
+		assert.Equal(t, c.wantedCRLGets, crlGets, c.msg)
+		if c.wantedRevoked != nil {
+			assert.Equal(t, c.wantedRevokedMetric, testutil.ToFloat64(metrics.PCKCertificateRevokedMetric), c.msg)
+		}
+	}
+}
+
+func TestTCBEvaluation(t *testing.T) {
+	ca, rootCAPath := newTestCA(t)
+	untrustedCA, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+
+	// the test PCK certificate has the CPUSVN 0f0f...0f and the PCESVN 13
+	levels := []tcbinfo.TCBLevel{
+		fakepcs.TCBLevel("10101010101010101010101010101010", 13, tcbinfo.StatusUpToDate),
+		fakepcs.TCBLevel("0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f", 13, tcbinfo.StatusSWHardeningNeeded, "INTEL-SA-00615"),
+		fakepcs.TCBLevel("0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f", 11, tcbinfo.StatusOutOfDate, "INTEL-SA-00615", "INTEL-SA-00828"),
+	}
+
+	cases := []struct {
+		msg             string
+		pccsCA          *fakepcs.CA
+		pccsFMSPC       string
+		intelLevels     []tcbinfo.TCBLevel
+		wantedTCB       bool
+		wantedStatus    string
+		wantedAdvisory  []string
+		wantedIntelGets int
+	}{
+		{
+			msg:            "TCB Info of the PCCS is evaluated",
+			pccsCA:         ca,
+			pccsFMSPC:      "00906ED50000",
+			intelLevels:    levels,
+			wantedTCB:      true,
+			wantedStatus:   tcbinfo.StatusSWHardeningNeeded,
+			wantedAdvisory: []string{"INTEL-SA-00615"},
+		},
+		{
+			msg:             "TCB Info of an untrusted PCCS falls back to Intel",
+			pccsCA:          untrustedCA,
+			pccsFMSPC:       "00906ED50000",
+			intelLevels:     levels,
+			wantedTCB:       true,
+			wantedStatus:    tcbinfo.StatusSWHardeningNeeded,
+			wantedAdvisory:  []string{"INTEL-SA-00615"},
+			wantedIntelGets: 1,
+		},
+		{
+			msg:             "TCB Info of another FMSPC falls back to Intel",
+			pccsCA:          ca,
+			pccsFMSPC:       "00606A000000",
+			intelLevels:     levels[:1],
+			wantedTCB:       true,
+			wantedStatus:    tcbinfo.StatusUnrecognized,
+			wantedIntelGets: 1,
+		},
+		{
+			msg:             "unavailable TCB Info is skipped",
+			pccsCA:          untrustedCA,
+			pccsFMSPC:       "00906ED50000",
+			wantedIntelGets: 1,
+		},
+	}
+
+	for _, c := range cases {
+		intelGets := 0
+		pccs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/pckcert"):
+				ca.WritePCKResponse(w, newTestPCK())
+			case strings.HasSuffix(r.URL.Path, "/tcb"):
+				assert.Equal(t, "00906ed50000", r.URL.Query().Get("fmspc"), c.msg)
+				c.pccsCA.WriteTCBInfoResponse(w, fakepcs.TCBInfo(c.pccsFMSPC, "0000", levels...))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		intel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			intelGets++
+			if c.intelLevels == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			ca.WriteTCBInfoResponse(w, fakepcs.TCBInfo("00906ED50000", "0000", c.intelLevels...))
+		}))
+
+		manifestSource := fakeplatform.NewManifestSource(nil)
+		manifestSource.Registered = true
+		cfg := &config.RegistrationServiceConfig{
+			PCCSURLs:        []string{pccs.URL},
+			IntelTCBInfoURL: intel.URL + "/sgx/certification/v4/tcb",
+			RequestTimeout:  5 * time.Second,
+			SGXRootCAPath:   rootCAPath,
+		}
+		logger := zap.NewNop()
+		registrationService := NewRegistrationService(logger, cfg, time.Minute, manifestSource,
+			fakeplatform.NewInfoProvider(newTestPlatformInfo()))
+		registrationService.CheckRegistrationStatus(t.Context())
+		pccs.Close()
+		intel.Close()
+
+		status := registrationService.Status()
+		assert.Equal(t, metrics.PlatformDirectlyRegistered, status.StatusCode, c.msg)
+		assert.Equal(t, c.wantedIntelGets, intelGets, c.msg)
+		if !c.wantedTCB {
+			assert.Nil(t, status.TCB, c.msg)
+			continue
+		}
+		assert.Equal(t, c.wantedStatus, status.TCB.Status, c.msg)
+		assert.Equal(t, c.wantedAdvisory, status.TCB.AdvisoryIDs, c.msg)
+		assert.Equal(t, 17, status.TCB.TCBEvaluationDataNumber, c.msg)
+		assert.Equal(t, float64(1), testutil.ToFloat64(metrics.TCBStatusMetric.WithLabelValues(c.wantedStatus)), c.msg)
+		assert.Equal(t, len(c.wantedAdvisory), testutil.CollectAndCount(metrics.TCBAdvisoryMetric), c.msg)
+	}
+}
+
+func TestCollateralPrefetch(t *testing.T) {
+	ca, rootCAPath := newTestCA(t)
+
+	cases := []struct {
+		msg            string
+		prefetch       bool
+		wantedRequests []string
+		wantedSuccess  map[string]float64
+	}{
+		{
+			msg:      "collateral is requested from each PCCS",
+			prefetch: true,
+			wantedRequests: []string{
+				"/sgx/certification/v4/tcb?fmspc=00906ed50000",
+				"/sgx/certification/v4/qe/identity",
+				"/sgx/certification/v4/qve/identity",
+				"/sgx/certification/v4/pckcrl?ca=processor",
+				"/sgx/certification/v4/rootcacrl",
+			},
+			wantedSuccess: map[string]float64{
+				intelservices.CollateralTCBInfo:     1,
+				intelservices.CollateralQEIdentity:  1,
+				intelservices.CollateralQvEIdentity: 0,
+				intelservices.CollateralPCKCRL:      1,
+				intelservices.CollateralRootCACRL:   1,
+			},
+		},
+		{
+			msg: "collateral is not requested when disabled",
+		},
+	}
+
+	for _, c := range cases {
+		var pccsURLs []string
+		requests := map[string][]string{}
+		for range 2 {
+			var pccs *httptest.Server
+			pccs = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests[pccs.URL] = append(requests[pccs.URL], r.URL.RequestURI())
+				switch r.URL.Path {
+				case "/sgx/certification/v4/pckcert":
+					ca.WritePCKResponse(w, newTestPCK())
+				case "/sgx/certification/v4/qve/identity":
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+			pccsURLs = append(pccsURLs, pccs.URL)
+			defer pccs.Close()
+		}
+
+		manifestSource := fakeplatform.NewManifestSource(nil)
+		manifestSource.Registered = true
+		cfg := &config.RegistrationServiceConfig{
+			PCCSURLs:           pccsURLs,
+			PrefetchCollateral: c.prefetch,
+			RequestTimeout:     5 * time.Second,
+			SGXRootCAPath:      rootCAPath,
+		}
+		logger := zap.NewNop()
+		checker := NewRegistrationChecker(logger, cfg, metrics.NewRegistrationServiceMetricsRegistry(logger),
+			manifestSource, fakeplatform.NewInfoProvider(newTestPlatformInfo()))
+		metric, err := checker.Check(t.Context())
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, metrics.PlatformDirectlyRegistered, metric.Status, c.msg)
+
+		for _, pccsURL := range pccsURLs {
+			if !c.prefetch {
+				assert.NotContains(t, requests[pccsURL], "/sgx/certification/v4/qe/identity", c.msg)
+				continue
+			}
+			assert.Subset(t, requests[pccsURL], c.wantedRequests, c.msg)
+			for collateral, success := range c.wantedSuccess {
+				assert.Equal(t, success, testutil.ToFloat64(metrics.CollateralPrefetchMetric.WithLabelValues(pccsURL, collateral)),
+					"%s: %s of %s", c.msg, collateral, pccsURL)
+			}
+		}
+	}
+}
+
+func TestPCKCertsRetrieval(t *testing.T) {
+	ca, rootCAPath := newTestCA(t)
+	multiPackageManifest, err := platformmanifest.New(platformmanifest.PlatformManifestGUID,
+		platformmanifest.NewStructure(platformmanifest.PlatformInfoGUID, make([]byte, 32)),
+		platformmanifest.NewStructure(platformmanifest.KeyBlobGUID, make([]byte, 64)),
+		platformmanifest.NewStructure(platformmanifest.KeyBlobGUID, make([]byte, 64)),
+	).Marshal()
+	assert.NoError(t, err)
+
+	lower := newTestPCK()
+	lower.CPUSVN = "07070707070707070707070707070707"
+	higher := newTestPCK()
+	higher.CPUSVN = "10101010101010101010101010101010"
+
+	cases := []struct {
+		msg                string
+		apiKey             string
+		manifest           []byte
+		wantedRequests     int
+		wantedMethod       string
+		wantedManifest     bool
+		wantedSelectedTCBm string
+	}{
+		{
+			msg:            "certificates are not requested without API key",
+			manifest:       newTestRequest(t, platformmanifest.PlatformManifestGUID),
+			wantedRequests: 0,
+		},
+		{
+			msg:                "single-package platform requests the certificates by encrypted PPID",
+			apiKey:             "subscription-key",
+			manifest:           newTestRequest(t, platformmanifest.PlatformManifestGUID),
+			wantedRequests:     1,
+			wantedMethod:       intelservices.PCKCertsByEncryptedPPID,
+			wantedSelectedTCBm: "0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0d00",
+		},
+		{
+			msg:                "multi-package platform sends its registered platform manifest",
+			apiKey:             "subscription-key",
+			manifest:           multiPackageManifest,
+			wantedRequests:     1,
+			wantedMethod:       intelservices.PCKCertsByPlatformManifest,
+			wantedManifest:     true,
+			wantedSelectedTCBm: "0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0d00",
+		},
+	}
+
+	for _, c := range cases {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/sgx/registration/v1/platform":
+				w.WriteHeader(http.StatusCreated)
+			case "/sgx/certification/v4/pckcert":
+				ca.WritePCKResponse(w, newTestPCK())
+			case "/sgx/certification/v4/pckcerts":
+				requests++
+				assert.Equal(t, c.apiKey, r.Header.Get(intelservices.SubscriptionKeyHeader), c.msg)
+				if c.wantedManifest {
+					var body struct {
+						PlatformManifest string `json:"platformManifest"`
+						PCEID            string `json:"pceid"`
+					}
+					assert.Equal(t, http.MethodPost, r.Method, c.msg)
+					assert.NoError(t, json.NewDecoder(r.Body).Decode(&body), c.msg)
+					assert.Equal(t, hex.EncodeToString(c.manifest), body.PlatformManifest, c.msg)
+					assert.Equal(t, "0000", body.PCEID, c.msg)
+				} else {
+					assert.Equal(t, http.MethodGet, r.Method, c.msg)
+					assert.Equal(t, "aabbcc", r.URL.Query().Get("encrypted_ppid"), c.msg)
+				}
+				ca.WritePCKCertsResponse(w, higher, newTestPCK(), lower)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		cfg := &config.RegistrationServiceConfig{
+			IntelRegistrationURL: server.URL + "/sgx/registration/v1/platform",
+			IntelPCKRetrievalURL: server.URL + "/sgx/certification/v4/pckcert",
+			IntelPCKCertsURL:     server.URL + "/sgx/certification/v4/pckcerts",
+			RequestTimeout:       5 * time.Second,
+			SGXRootCAPath:        rootCAPath,
+		}
+		if c.apiKey != "" {
+			cfg.IntelAPIKey, _ = newTestSecret(t, c.apiKey)
+		}
+		manifestSource := fakeplatform.NewManifestSource(c.manifest)
+		logger := zap.NewNop()
+		checker := NewRegistrationChecker(logger, cfg, metrics.NewRegistrationServiceMetricsRegistry(logger),
+			manifestSource, fakeplatform.NewInfoProvider(newTestPlatformInfo()))
+
+		// the platform manifest is registered, then consumed by the BIOS on reboot
+		metric, err := checker.Check(t.Context())
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, metrics.PlatformRebootNeeded, metric.Status, c.msg)
+		manifestSource.Manifest = nil
+
+		metric, err = checker.Check(t.Context())
+		server.Close()
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, metrics.PlatformDirectlyRegistered, metric.Status, c.msg)
+		assert.Equal(t, c.wantedRequests, requests, c.msg)
+
+		details := checker.StatusDetails().PCKCertificates
+		if c.wantedRequests == 0 {
+			assert.Nil(t, details, c.msg)
+			continue
+		}
+		assert.Equal(t, c.wantedMethod, details.Method, c.msg)
+		assert.Equal(t, c.wantedSelectedTCBm, details.SelectedTCBm, c.msg)
+		assert.Len(t, details.TCBms, 3, c.msg)
+		assert.Equal(t, float64(3), testutil.ToFloat64(metrics.PCKCertificatesCountMetric), c.msg)
+	}
+}
+
+func TestAddPlatformToPCCS(t *testing.T) {
+	ca, rootCAPath := newTestCA(t)
+	multiPackageManifest, err := platformmanifest.New(platformmanifest.PlatformManifestGUID,
+		platformmanifest.NewStructure(platformmanifest.PlatformInfoGUID, make([]byte, 32)),
+		platformmanifest.NewStructure(platformmanifest.KeyBlobGUID, make([]byte, 64)),
+		platformmanifest.NewStructure(platformmanifest.KeyBlobGUID, make([]byte, 64)),
+	).Marshal()
+	assert.NoError(t, err)
+
+	cases := []struct {
+		msg              string
+		manifest         []byte
+		rejectingStatus  int
+		wantedManifest   string
+		wantedPosts      []int
+		wantedAdded      []bool
+		wantedAddedGauge []float64
+	}{
+		{
+			msg:              "single-package platform is added once to each PCCS accepting it",
+			manifest:         newTestRequest(t, platformmanifest.PlatformManifestGUID),
+			rejectingStatus:  http.StatusUnauthorized,
+			wantedPosts:      []int{1, 2},
+			wantedAdded:      []bool{true, false},
+			wantedAddedGauge: []float64{1, 0},
+		},
+		{
+			msg:              "multi-package platform is added with its registered platform manifest",
+			manifest:         multiPackageManifest,
+			rejectingStatus:  http.StatusOK,
+			wantedManifest:   hex.EncodeToString(multiPackageManifest),
+			wantedPosts:      []int{1, 1},
+			wantedAdded:      []bool{true, true},
+			wantedAddedGauge: []float64{1, 1},
+		},
+	}
+
+	for _, c := range cases {
+		posts := make([]int, 2)
+		newPCCS := func(index, status int) *httptest.Server {
+			return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/sgx/certification/v4/platforms" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				posts[index]++
+				var body intelservices.PCCSPlatform
+				assert.Equal(t, http.MethodPost, r.Method, c.msg)
+				assert.Equal(t, "user-token", r.Header.Get(intelservices.UserTokenHeader), c.msg)
+				assert.Empty(t, r.Header.Get(intelservices.AdminTokenHeader), c.msg)
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&body), c.msg)
+				assert.Equal(t, intelservices.PCCSPlatform{
+					EncryptedPPID:    "aabbcc",
+					CPUSVN:           "0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f",
+					PCESVN:           "000d",
+					PCEID:            "0000",
+					QEID:             "00112233445566778899aabbccddeeff",
+					PlatformManifest: c.wantedManifest,
+				}, body, c.msg)
+				w.WriteHeader(status)
+			}))
+		}
+		accepting := newPCCS(0, http.StatusOK)
+		rejecting := newPCCS(1, c.rejectingStatus)
+		intel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/sgx/registration/v1/platform":
+				w.WriteHeader(http.StatusCreated)
+			case "/sgx/certification/v4/pckcert":
+				ca.WritePCKResponse(w, newTestPCK())
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		userToken, _ := newTestSecret(t, "user-token")
+		adminToken, _ := newTestSecret(t, "admin-token")
+		cfg := &config.RegistrationServiceConfig{
+			PCCSURLs:             []string{accepting.URL, rejecting.URL},
+			PCCSAddPlatform:      true,
+			PCCSUserToken:        userToken,
+			PCCSAdminToken:       adminToken,
+			IntelRegistrationURL: intel.URL + "/sgx/registration/v1/platform",
+			IntelPCKRetrievalURL: intel.URL + "/sgx/certification/v4/pckcert",
+			RequestTimeout:       5 * time.Second,
+			SGXRootCAPath:        rootCAPath,
+		}
+		manifestSource := fakeplatform.NewManifestSource(c.manifest)
+		logger := zap.NewNop()
+		checker := NewRegistrationChecker(logger, cfg, metrics.NewRegistrationServiceMetricsRegistry(logger),
+			manifestSource, fakeplatform.NewInfoProvider(newTestPlatformInfo()))
+
+		// the platform manifest is registered, then consumed by the BIOS on reboot
+		metric, err := checker.Check(t.Context())
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, metrics.PlatformRebootNeeded, metric.Status, c.msg)
+		manifestSource.Manifest = nil
+
+		for range 2 {
+			metric, err = checker.Check(t.Context())
+			assert.NoError(t, err, c.msg)
+			assert.Equal(t, metrics.PlatformDirectlyRegistered, metric.Status, c.msg)
+		}
+		accepting.Close()
+		rejecting.Close()
+		intel.Close()
+
+		assert.Equal(t, c.wantedPosts, posts, c.msg)
+		details := checker.StatusDetails().PCCSPlatforms
+		assert.Len(t, details, 2, c.msg)
+		for i, pccs := range []string{accepting.URL, rejecting.URL} {
+			assert.Equal(t, pccs, details[i].PCCSURL, c.msg)
+			assert.Equal(t, c.wantedAdded[i], details[i].Succeeded, c.msg)
+			assert.Equal(t, c.wantedAdded[i], details[i].Error == "", c.msg)
+			assert.Equal(t, c.wantedAddedGauge[i], testutil.ToFloat64(metrics.PCCSPlatformAddedMetric.WithLabelValues(pccs)), c.msg)
+		}
+	}
+}
+
+func TestUploadPCKCerts(t *testing.T) {
+	ca, rootCAPath := newTestCA(t)
+	foreignCA, err := fakepcs.NewCA()
+	assert.NoError(t, err)
+	foreignPCK := newTestPCK()
+	foreignPCK.CPUSVN = "0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e"
+	foreignPCK.Issuer = foreignCA
+
+	// Intel returns the same collateral on each check
+	tcbInfo := httptest.NewRecorder()
+	ca.WriteTCBInfoResponse(tcbInfo, fakepcs.TCBInfo("00906ED50000", "0000",
+		fakepcs.TCBLevel("0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f", 13, tcbinfo.StatusUpToDate)))
+	pckCRL := httptest.NewRecorder()
+	ca.WriteCRLResponse(pckCRL, time.Now().Add(24*time.Hour))
+	pckCRLBlock, _ := pem.Decode(pckCRL.Body.Bytes())
+	qeIdentity := httptest.NewRecorder()
+	ca.WriteEnclaveIdentityResponse(qeIdentity, fakepcs.EnclaveIdentity(enclaveidentity.IDQE))
+	qveIdentity := httptest.NewRecorder()
+	ca.WriteEnclaveIdentityResponse(qveIdentity, fakepcs.EnclaveIdentity(enclaveidentity.IDQVE))
+	rootCACRL := httptest.NewRecorder()
+	ca.WriteRootCACRLResponse(rootCACRL, time.Now().Add(24*time.Hour))
+	replay := func(w http.ResponseWriter, recorder *httptest.ResponseRecorder) {
+		maps.Copy(w.Header(), recorder.Header())
+		_, _ = w.Write(recorder.Body.Bytes())
+	}
+
+	cases := []struct {
+		msg               string
+		pcks              []fakepcs.PCK
+		unavailable       string // path of the Intel collateral answering 404
+		failingStatus     int
+		wantedPuts        []int
+		wantedUploaded    []bool
+		wantedUploadGauge []float64
+	}{
+		{
+			msg:               "certificates are uploaded once to each PCCS",
+			pcks:              []fakepcs.PCK{newTestPCK()},
+			failingStatus:     http.StatusOK,
+			wantedPuts:        []int{1, 1},
+			wantedUploaded:    []bool{true, true},
+			wantedUploadGauge: []float64{1, 1},
+		},
+		{
+			msg:               "failed upload is retried on the next check",
+			pcks:              []fakepcs.PCK{newTestPCK()},
+			failingStatus:     http.StatusInternalServerError,
+			wantedPuts:        []int{1, 2},
+			wantedUploaded:    []bool{true, false},
+			wantedUploadGauge: []float64{1, 0},
+		},
+		{
+			msg:               "certificates are not uploaded when a TCB level is signed by a foreign CA",
+			pcks:              []fakepcs.PCK{newTestPCK(), foreignPCK},
+			failingStatus:     http.StatusOK,
+			wantedPuts:        []int{0, 0},
+			wantedUploadGauge: []float64{0, 0},
+		},
+		{
+			msg:               "certificates are not uploaded without the QvE identity required by the PCCS",
+			pcks:              []fakepcs.PCK{newTestPCK()},
+			unavailable:       "/sgx/certification/v4/qve/identity",
+			failingStatus:     http.StatusOK,
+			wantedPuts:        []int{0, 0},
+			wantedUploadGauge: []float64{0, 0},
+		},
+		{
+			msg:               "certificates are not uploaded without the root CA CRL required by the PCCS",
+			pcks:              []fakepcs.PCK{newTestPCK()},
+			unavailable:       "/IntelSGXRootCA.der",
+			failingStatus:     http.StatusOK,
+			wantedPuts:        []int{0, 0},
+			wantedUploadGauge: []float64{0, 0},
+		},
+	}
+
+	for _, c := range cases {
+		// Intel returns the same certificates on each check
+		pckCerts := httptest.NewRecorder()
+		ca.WritePCKCertsResponse(pckCerts, c.pcks...)
+		puts := make([]int, 2)
+		newPCCS := func(index, status int) *httptest.Server {
+			return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// the OFFLINE PCCS cannot serve the PCK certificate before it is uploaded
+				if r.URL.Path != "/sgx/certification/v4/platformcollateral" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				puts[index]++
+				assert.Equal(t, http.MethodPut, r.Method, c.msg)
+				assert.Equal(t, "admin-token", r.Header.Get(intelservices.AdminTokenHeader), c.msg)
+				assert.Empty(t, r.Header.Get(intelservices.UserTokenHeader), c.msg)
+				assert.Empty(t, r.Header.Get(intelservices.SubscriptionKeyHeader), c.msg)
+				raw, err := io.ReadAll(r.Body)
+				assert.NoError(t, err, c.msg)
+				// the PCCS rejects the bodies missing a collateral of its schema
+				if err := fakepcs.CheckPlatformCollateral(raw); err != nil {
+					assert.NoError(t, err, c.msg)
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+
+				var body intelservices.PlatformCollateral
+				assert.NoError(t, json.Unmarshal(raw, &body), c.msg)
+				if assert.Len(t, body.Platforms, 1, c.msg) && assert.Len(t, body.Collaterals.PCKCerts, 1, c.msg) {
+					assert.Equal(t, "aabbcc", body.Platforms[0].EncryptedPPID, c.msg)
+					assert.Equal(t, "0000", body.Collaterals.PCKCerts[0].PCEID, c.msg)
+					assert.JSONEq(t, pckCerts.Body.String(), string(body.Collaterals.PCKCerts[0].Certs), c.msg)
+				}
+				if assert.Len(t, body.Collaterals.TCBInfos, 1, c.msg) {
+					assert.Equal(t, "00906ED50000", body.Collaterals.TCBInfos[0].FMSPC, c.msg)
+					assert.JSONEq(t, tcbInfo.Body.String(), string(body.Collaterals.TCBInfos[0].SGXTCBInfo), c.msg)
+				}
+				assert.Equal(t, hex.EncodeToString(pckCRLBlock.Bytes), body.Collaterals.PCKCACRL.ProcessorCRL, c.msg)
+				assert.Empty(t, body.Collaterals.PCKCACRL.PlatformCRL, c.msg)
+				assert.Equal(t, qeIdentity.Body.String(), body.Collaterals.QEIdentity, c.msg)
+				assert.Equal(t, qveIdentity.Body.String(), body.Collaterals.QvEIdentity, c.msg)
+				assert.Equal(t, hex.EncodeToString(rootCACRL.Body.Bytes()), body.Collaterals.RootCACRL, c.msg)
+				assert.Equal(t, map[string]string{"PROCESSOR": pckCerts.Header().Get(pckcert.IssuerChainHeader)},
+					body.Collaterals.Certificates.PCKCertificate, c.msg)
+				assert.Equal(t, tcbInfo.Header().Get(tcbinfo.IssuerChainHeader),
+					body.Collaterals.Certificates.TCBInfo, c.msg)
+				assert.Equal(t, qeIdentity.Header().Get(enclaveidentity.IssuerChainHeader),
+					body.Collaterals.Certificates.EnclaveIdentity, c.msg)
+				w.WriteHeader(status)
+			}))
+		}
+		accepting := newPCCS(0, http.StatusOK)
+		failing := newPCCS(1, c.failingStatus)
+		intel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == c.unavailable {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			switch r.URL.Path {
+			case "/sgx/certification/v4/pckcert":
+				ca.WritePCKResponse(w, newTestPCK())
+			case "/sgx/certification/v4/pckcerts":
+				replay(w, pckCerts)
+			case "/sgx/certification/v4/tcb":
+				replay(w, tcbInfo)
+			case "/sgx/certification/v4/pckcrl":
+				replay(w, pckCRL)
+			case "/sgx/certification/v4/qe/identity":
+				replay(w, qeIdentity)
+			case "/sgx/certification/v4/qve/identity":
+				replay(w, qveIdentity)
+			case "/IntelSGXRootCA.der":
+				replay(w, rootCACRL)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		apiKey, _ := newTestSecret(t, "subscription-key")
+		adminToken, _ := newTestSecret(t, "admin-token")
+		manifestSource := fakeplatform.NewManifestSource(nil)
+		manifestSource.Registered = true
+		cfg := &config.RegistrationServiceConfig{
+			PCCSURLs:             []string{accepting.URL, failing.URL},
+			PCCSUploadPCKCerts:   true,
+			PCCSAdminToken:       adminToken,
+			IntelPCKRetrievalURL: intel.URL + "/sgx/certification/v4/pckcert",
+			IntelPCKCRLURL:       intel.URL + "/sgx/certification/v4/pckcrl",
+			IntelTCBInfoURL:      intel.URL + "/sgx/certification/v4/tcb",
+			IntelQEIdentityURL:   intel.URL + "/sgx/certification/v4/qe/identity",
+			IntelQvEIdentityURL:  intel.URL + "/sgx/certification/v4/qve/identity",
+			IntelRootCACRLURL:    intel.URL + "/IntelSGXRootCA.der",
+			IntelPCKCertsURL:     intel.URL + "/sgx/certification/v4/pckcerts",
+			IntelAPIKey:          apiKey,
+			RequestTimeout:       5 * time.Second,
+			SGXRootCAPath:        rootCAPath,
+		}
+		logger := zap.NewNop()
+		checker := NewRegistrationChecker(logger, cfg, metrics.NewRegistrationServiceMetricsRegistry(logger),
+			manifestSource, fakeplatform.NewInfoProvider(newTestPlatformInfo()))
+
+		for range 2 {
+			metric, err := checker.Check(t.Context())
+			assert.NoError(t, err, c.msg)
+			assert.Equal(t, metrics.PlatformDirectlyRegistered, metric.Status, c.msg)
+		}
+		accepting.Close()
+		failing.Close()
+		intel.Close()
+
+		assert.Equal(t, c.wantedPuts, puts, c.msg)
+		uploads := checker.StatusDetails().PCCSUploads
+		assert.Len(t, uploads, len(c.wantedUploaded), c.msg)
+		for i, pccs := range []string{accepting.URL, failing.URL} {
+			if i < len(uploads) {
+				assert.Equal(t, pccs, uploads[i].PCCSURL, c.msg)
+				assert.Equal(t, c.wantedUploaded[i], uploads[i].Succeeded, c.msg)
+			}
+			assert.Equal(t, c.wantedUploadGauge[i], testutil.ToFloat64(metrics.PCCSUploadMetric.WithLabelValues(pccs)), c.msg)
+		}
+	}
+}
+
+func TestPCCSConsistency(t *testing.T) {
+	ca, rootCAPath := newTestCA(t)
+	// Intel returns the same certificate on each request
+	intelPCK := httptest.NewRecorder()
+	ca.WritePCKResponse(intelPCK, newTestPCK())
+	replay := func(w http.ResponseWriter, recorder *httptest.ResponseRecorder) {
+		maps.Copy(w.Header(), recorder.Header())
+		_, _ = w.Write(recorder.Body.Bytes())
+	}
+
+	cases := []struct {
+		msg             string
+		pccsHandler     func(w http.ResponseWriter)
+		refresh         bool
+		wantedIntelGets int
+		wantedRefreshes int
+		wantedDetails   PCCSConsistencyDetails
+		wantedGauge     float64
+	}{
+		{
+			msg:             "PCCS serving the certificate of Intel is consistent",
+			pccsHandler:     func(w http.ResponseWriter) { replay(w, intelPCK) },
+			refresh:         true,
+			wantedIntelGets: 1,
+			wantedDetails:   PCCSConsistencyDetails{Consistent: true},
+			wantedGauge:     1,
+		},
+		{
+			msg:             "PCCS serving another certificate diverges and is refreshed",
+			pccsHandler:     func(w http.ResponseWriter) { ca.WritePCKResponse(w, newTestPCK()) },
+			refresh:         true,
+			wantedIntelGets: 1,
+			wantedRefreshes: 1,
+			wantedDetails:   PCCSConsistencyDetails{Refreshed: true},
+			wantedGauge:     0,
+		},
+		{
+			msg:             "PCCS serving no certificate is reported without refresh",
+			pccsHandler:     func(w http.ResponseWriter) { w.WriteHeader(http.StatusNotFound) },
+			refresh:         true,
+			wantedIntelGets: 3,
+			wantedDetails:   PCCSConsistencyDetails{Error: "no PCK certificate returned (HTTP status code: 404, Error-Code: )"},
+			wantedGauge:     0,
+		},
+	}
+
+	for _, c := range cases {
+		intelGets, refreshes := 0, 0
+		pccs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/sgx/certification/v4/pckcert":
+				c.pccsHandler(w)
+			case "/sgx/certification/v4/refresh":
+				refreshes++
+				assert.Equal(t, http.MethodPost, r.Method, c.msg)
+				assert.Equal(t, "admin-token", r.Header.Get(intelservices.AdminTokenHeader), c.msg)
+				assert.Equal(t, "certs", r.URL.Query().Get("type"), c.msg)
+				assert.Equal(t, "00906ed50000", r.URL.Query().Get("fmspc"), c.msg)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		intel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/sgx/certification/v4/pckcert" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			intelGets++
+			replay(w, intelPCK)
+		}))
+
+		adminToken, _ := newTestSecret(t, "admin-token")
+		manifestSource := fakeplatform.NewManifestSource(nil)
+		manifestSource.Registered = true
+		cfg := &config.RegistrationServiceConfig{
+			PCCSURLs:                []string{pccs.URL},
+			PCCSAdminToken:          adminToken,
+			PCCSConsistencyInterval: time.Hour,
+			PCCSConsistencyRefresh:  c.refresh,
+			IntelPCKRetrievalURL:    intel.URL + "/sgx/certification/v4/pckcert",
+			RequestTimeout:          5 * time.Second,
+			SGXRootCAPath:           rootCAPath,
+		}
+		logger := zap.NewNop()
+		checker := NewRegistrationChecker(logger, cfg, metrics.NewRegistrationServiceMetricsRegistry(logger),
+			manifestSource, fakeplatform.NewInfoProvider(newTestPlatformInfo()))
+
+		// the second check is within the consistency interval
+		for range 2 {
+			metric, err := checker.Check(t.Context())
+			assert.NoError(t, err, c.msg)
+			assert.Equal(t, metrics.PlatformDirectlyRegistered, metric.Status, c.msg)
+		}
+		pccs.Close()
+		intel.Close()
+
+		assert.Equal(t, c.wantedIntelGets, intelGets, c.msg)
+		assert.Equal(t, c.wantedRefreshes, refreshes, c.msg)
+		details := checker.StatusDetails().PCCSConsistency
+		if !assert.Len(t, details, 1, c.msg) {
+			continue
+		}
+		assert.Equal(t, pccs.URL, details[0].PCCSURL, c.msg)
+		assert.Equal(t, c.wantedDetails.Consistent, details[0].Consistent, c.msg)
+		assert.Equal(t, c.wantedDetails.Refreshed, details[0].Refreshed, c.msg)
+		assert.Equal(t, c.wantedDetails.Error, details[0].Error, c.msg)
+		assert.Equal(t, "0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0d00", details[0].IntelTCBm, c.msg)
+		if c.wantedDetails.Consistent {
+			assert.Equal(t, details[0].IntelSerial, details[0].Serial, c.msg)
+		}
+		assert.Equal(t, c.wantedGauge, testutil.ToFloat64(metrics.PCCSConsistencyMetric.WithLabelValues(pccs.URL)), c.msg)
+	}
+}
+
+func TestCredentialsEndpointClass(t *testing.T) {
+	ca, rootCAPath := newTestCA(t)
+	credentialHeaders := []string{intelservices.SubscriptionKeyHeader, intelservices.UserTokenHeader, intelservices.AdminTokenHeader}
+
+	cases := []struct {
+		msg              string
+		redirect         bool
+		wantedIntelKeys  []string
+		wantedMirrorKeys []string
+	}{
+		{
+			msg:             "API key is only sent to Intel and read again once rotated",
+			wantedIntelKeys: []string{"subscription-key", "rotated-subscription-key"},
+		},
+		{
+			msg:              "API key is not forwarded when Intel redirects to another host",
+			redirect:         true,
+			wantedIntelKeys:  []string{"subscription-key", "rotated-subscription-key"},
+			wantedMirrorKeys: []string{"", ""},
+		},
+	}
+
+	for _, c := range cases {
+		var intelKeys, mirrorKeys []string
+		pccs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, header := range credentialHeaders {
+				assert.Empty(t, r.Header.Get(header), c.msg)
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mirrorKeys = append(mirrorKeys, r.Header.Get(intelservices.SubscriptionKeyHeader))
+			ca.WritePCKResponse(w, newTestPCK())
+		}))
+		intel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasSuffix(r.URL.Path, "/pckcert") {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			intelKeys = append(intelKeys, r.Header.Get(intelservices.SubscriptionKeyHeader))
+			if c.redirect {
+				http.Redirect(w, r, mirror.URL+r.URL.RequestURI(), http.StatusFound)
+				return
+			}
+			ca.WritePCKResponse(w, newTestPCK())
+		}))
+
+		apiKey, apiKeyPath := newTestSecret(t, "subscription-key")
+		userToken, _ := newTestSecret(t, "user-token")
+		adminToken, _ := newTestSecret(t, "admin-token")
+		manifestSource := fakeplatform.NewManifestSource(nil)
+		manifestSource.Registered = true
+		cfg := &config.RegistrationServiceConfig{
+			PCCSURLs:             []string{pccs.URL},
+			PCCSUserToken:        userToken,
+			PCCSAdminToken:       adminToken,
+			IntelPCKRetrievalURL: intel.URL + "/sgx/certification/v4/pckcert",
+			IntelPCKCRLURL:       intel.URL + "/sgx/certification/v4/pckcrl",
+			IntelAPIKey:          apiKey,
+			RequestTimeout:       5 * time.Second,
+			SGXRootCAPath:        rootCAPath,
+		}
+		logger := zap.NewNop()
+		checker := NewRegistrationChecker(logger, cfg, metrics.NewRegistrationServiceMetricsRegistry(logger),
+			manifestSource, fakeplatform.NewInfoProvider(newTestPlatformInfo()))
+
+		metric, err := checker.Check(t.Context())
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, metrics.PlatformDirectlyRegistered, metric.Status, c.msg)
+
+		assert.NoError(t, os.WriteFile(apiKeyPath, []byte("rotated-subscription-key\n"), 0o600), c.msg)
+		metric, err = checker.Check(t.Context())
+		pccs.Close()
+		mirror.Close()
+		intel.Close()
+		assert.NoError(t, err, c.msg)
+		assert.Equal(t, metrics.PlatformDirectlyRegistered, metric.Status, c.msg)
+
+		assert.Equal(t, c.wantedIntelKeys, intelKeys, c.msg)
+		assert.Equal(t, c.wantedMirrorKeys, mirrorKeys, c.msg)
+	}
+}
+
+func TestTransactionalRegistration(t *testing.T) {
+	manifest := newTestRequest(t, platformmanifest.PlatformManifestGUID)
+	addPackageRequest := newTestRequest(t, platformmanifest.AddRequestGUID)
+	membershipCertificates := []byte("membership-certificates")
+
+	// each step runs a check with a new checker, as after a restart of the service
+	type step struct {
+		completeErr  error
+		completeLost bool
+		forced       bool
+		wantedStatus metrics.StatusCode
+	}
+	cases := []struct {
+		msg                   string
+		registered            bool
+		pendingAddPackage     bool
+		steps                 []step
+		wantedRegistered      bool
+		wantedServerResponse  []byte
+		wantedPosts           int
+		wantedAddPackagePosts int
+	}{
+		{
+			msg: "failed UEFI write is retried without sending the manifest again",
+			steps: []step{
+				{completeErr: errors.New("write failed"), wantedStatus: metrics.UefiPersistFailed},
+				{wantedStatus: metrics.PlatformRebootNeeded},
+			},
+			wantedRegistered: true,
+			wantedPosts:      1,
+		},
+		{
+			msg: "UEFI write that does not stick is detected by reading the status back",
+			steps: []step{
+				{completeLost: true, wantedStatus: metrics.UefiPersistFailed},
+				{wantedStatus: metrics.PlatformRebootNeeded},
+			},
+			wantedRegistered: true,
+			wantedPosts:      1,
+		},
+		{
+			msg: "forced resubmission sends the manifest again",
+			steps: []step{
+				{completeErr: errors.New("write failed"), wantedStatus: metrics.UefiPersistFailed},
+				{forced: true, wantedStatus: metrics.PlatformRebootNeeded},
+			},
+			wantedRegistered: true,
+			wantedPosts:      2,
+		},
+		{
+			msg:        "TCB recovery manifest is sent once until the reboot",
+			registered: true,
+			steps: []step{
+				{wantedStatus: metrics.TcbRecoveryPending},
+				{wantedStatus: metrics.TcbRecoveryPending},
+			},
+			wantedRegistered: true,
+			wantedPosts:      1,
+		},
+		{
+			msg:               "failed server response write is retried with the recorded response",
+			pendingAddPackage: true,
+			steps: []step{
+				{completeErr: errors.New("write failed"), wantedStatus: metrics.UefiPersistFailed},
+				{wantedStatus: metrics.PackageAddedRebootNeeded},
+			},
+			wantedRegistered:      true,
+			wantedServerResponse:  membershipCertificates,
+			wantedAddPackagePosts: 1,
+		},
+	}
+
+	for _, c := range cases {
+		posts, addPackagePosts := 0, 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/sgx/registration/v1/platform":
+				posts++
+				w.WriteHeader(http.StatusCreated)
+			case "/sgx/registration/v1/package":
+				addPackagePosts++
+				_, _ = w.Write(membershipCertificates)
+			}
+		}))
+
+		manifestSource := fakeplatform.NewManifestSource(manifest)
+		if c.pendingAddPackage {
+			manifestSource.Manifest = nil
+			manifestSource.AddPackageRequest = addPackageRequest
+		}
+		manifestSource.Registered = c.registered
+		stateFile := filepath.Join(t.TempDir(), "state.json")
+
+		for i, s := range c.steps {
+			cfg := &config.RegistrationServiceConfig{
+				IntelRegistrationURL: server.URL + "/sgx/registration/v1/platform",
+				IntelAddPackageURL:   server.URL + "/sgx/registration/v1/package",
+				RequestTimeout:       5 * time.Second,
+				StateFile:            stateFile,
+				ForceResubmit:        s.forced,
+			}
+			manifestSource.CompleteErr = s.completeErr
+			manifestSource.CompleteLost = s.completeLost
+
+			logger := zap.NewNop()
+			checker := NewRegistrationChecker(logger, cfg, metrics.NewRegistrationServiceMetricsRegistry(logger),
+				manifestSource, fakeplatform.NewInfoProvider(newTestPlatformInfo()))
+			metric, _ := checker.Check(t.Context())
+			assert.Equal(t, s.wantedStatus, metric.Status, "%s: step %d", c.msg, i)
+		}
+		server.Close()
+
+		assert.Equal(t, c.wantedRegistered, manifestSource.Registered, c.msg)
+		assert.Equal(t, c.wantedServerResponse, manifestSource.ServerResponse, c.msg)
+		assert.Equal(t, c.wantedPosts, posts, c.msg)
+		assert.Equal(t, c.wantedAddPackagePosts, addPackagePosts, c.msg)
+	}
+}
+
+func TestRegistrationServiceStatus(t *testing.T) {
+	logger := zap.NewNop()
+	manifestSource := fakeplatform.NewManifestSource(nil)
+	manifestSource.IsMachineRegisteredErr = errors.New("status variable not found")
+	manifestSource.SgxEnablement = &mpmanagement.SgxEnablement{
+		State:            mpmanagement.SgxBiosStateDisabled,
+		EpcBiosAvailable: true,
+		MaxEpcSize:       120,
+	}
+	cfg := &config.RegistrationServiceConfig{RequestTimeout: time.Second}
+
+	registrationService := NewRegistrationService(logger, cfg, time.Minute, manifestSource,
+		fakeplatform.NewInfoProvider(newTestPlatformInfo()))
+	registrationService.CheckRegistrationStatus(t.Context())
+
+	recorder := httptest.NewRecorder()
+	registrationService.StatusHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var status Status
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&status))
+	assert.Equal(t, metrics.SgxUefiUnavailable, status.StatusCode)
+	assert.Equal(t, metrics.SgxBiosStateDisabled, status.SgxState)
+	assert.Contains(t, status.Error, "SGX BIOS state: disabled")
+	assert.Equal(t, uint32(120), status.SgxEnablement.MaxEpcSize)
+	assert.False(t, status.LastCheck.IsZero())
+
+	manifestSource.GetSgxEnablementErr = fmt.Errorf("%w: no such directory", mpmanagement.ErrEfivarsUnavailable)
+	registrationService.CheckRegistrationStatus(t.Context())
+	assert.Equal(t, metrics.SgxBiosStateEfivarsUnavailable, registrationService.Status().SgxState)
+	assert.Nil(t, registrationService.Status().SgxEnablement)
+}
+
+func newTestRequest(t testing.TB, guid platformmanifest.GUID) []byte {
+	t.Helper()
+	request, err := platformmanifest.New(guid,
+		platformmanifest.NewStructure(platformmanifest.PlatformInfoGUID, make([]byte, 32)),
+		platformmanifest.NewStructure(platformmanifest.KeyBlobGUID, make([]byte, 64)),
+	).Marshal()
+	if err != nil {
+		t.Fatalf("failed to build test request: %v", err)
+	}
+	return request
+}
+
 func thisLogEntryEqualTo(t testing.TB, this, other observer.LoggedEntry, msg string) {
 	t.Helper()
 	assert.Equal(t, this.Level, other.Level, msg)
@@ -0,0 +1,193 @@
+package registration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Kinds of the requests submitted to Intel
+const (
+	submissionPlatformManifest = "platform_manifest"
+	submissionTcbRecovery      = "tcb_recovery"
+	submissionAddPackage       = "add_package"
+)
+
+// maxSubmissions bounds the number of submissions kept in the state file
+const maxSubmissions = 16
+
+// submission records a UEFI request that Intel accepted
+type submission struct {
+	SHA256      string    `json:"sha256"`
+	Kind        string    `json:"kind"`
+	SubmittedAt time.Time `json:"submittedAt"`
+	// ServerResponse is the AddPackage response to write to the SgxRegistrationServerResponse UEFI variable
+	ServerResponse []byte `json:"serverResponse,omitempty"`
+	// Request is the submitted platform manifest, which is no longer in UEFI once the platform is
+	// registered and is needed to request the PCK certificates of multi-package platforms
+	Request []byte `json:"request,omitempty"`
+	// Persisted reports whether the outcome was written to UEFI and read back
+	Persisted bool `json:"persisted"`
+}
+
+type submissionState struct {
+	Submissions []submission `json:"submissions"`
+}
+
+// submissionStore keeps the hashes of the requests accepted by Intel in a local state file, so that a
+// request is never sent twice when writing its outcome to UEFI fails. Without a path, the submissions
+// are only kept in memory.
+type submissionStore struct {
+	mu     sync.Mutex
+	path   string
+	memory submissionState
+}
+
+func newSubmissionStore(path string) *submissionStore {
+	return &submissionStore{path: path}
+}
+
+// requestHash returns the hex encoded SHA-256 hash of a UEFI request
+func requestHash(request []byte) string {
+	sum := sha256.Sum256(request)
+	return hex.EncodeToString(sum[:])
+}
+
+// lookup returns the submission of the request with the given hash, or nil if it was never submitted
+func (s *submissionStore) lookup(hash string) (*submission, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range state.Submissions {
+		if sub.SHA256 == hash {
+			return &sub, nil
+		}
+	}
+	return nil, nil
+}
+
+// record adds or replaces the submission of a request, dropping the oldest ones beyond maxSubmissions
+func (s *submissionStore) record(sub submission) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.load()
+	if err != nil {
+		return err
+	}
+	submissions := make([]submission, 0, len(state.Submissions)+1)
+	for _, existing := range state.Submissions {
+		if existing.SHA256 != sub.SHA256 {
+			submissions = append(submissions, existing)
+		}
+	}
+	submissions = append(submissions, sub)
+	if len(submissions) > maxSubmissions {
+		submissions = submissions[len(submissions)-maxSubmissions:]
+	}
+	return s.save(submissionState{Submissions: submissions})
+}
+
+// latestPlatformManifest returns the platform manifest submitted last, or nil if none was recorded
+func (s *submissionStore) latestPlatformManifest() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(state.Submissions) - 1; i >= 0; i-- {
+		sub := state.Submissions[i]
+		if (sub.Kind == submissionPlatformManifest || sub.Kind == submissionTcbRecovery) && len(sub.Request) > 0 {
+			return sub.Request, nil
+		}
+	}
+	return nil, nil
+}
+
+// markPersisted flags the submission of a request as written to UEFI
+func (s *submissionStore) markPersisted(hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i := range state.Submissions {
+		if state.Submissions[i].SHA256 == hash {
+			state.Submissions[i].Persisted = true
+			return s.save(state)
+		}
+	}
+	return nil
+}
+
+func (s *submissionStore) load() (submissionState, error) {
+	if s.path == "" {
+		return submissionState{Submissions: append([]submission(nil), s.memory.Submissions...)}, nil
+	}
+
+	var state submissionState
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to read registration state file %s: %w", s.path, err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to decode registration state file %s: %w", s.path, err)
+	}
+	return state, nil
+}
+
+// save replaces the state file atomically, so a crash never leaves a truncated file behind
+func (s *submissionStore) save(state submissionState) error {
+	if s.path == "" {
+		s.memory = state
+		return nil
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode registration state: %w", err)
+	}
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create registration state directory %s: %w", dir, err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create registration state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write registration state file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync registration state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close registration state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace registration state file %s: %w", s.path, err)
+	}
+	return nil
+}
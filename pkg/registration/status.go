@@ -0,0 +1,81 @@
+package registration
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	mpmanagement "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/mp_management"
+	"github.com/opensovereigncloud/cc-intel-platform-registration/pkg/metrics"
+)
+
+// Status is the outcome of the last registration check, served as JSON on the status endpoint
+type Status struct {
+	StatusCode     metrics.StatusCode `json:"statusCode"`
+	Description    string             `json:"description"`
+	HttpStatusCode string             `json:"httpStatusCode,omitempty"`
+	IntelError     string             `json:"intelError,omitempty"`
+	Error          string             `json:"error,omitempty"`
+	LastCheck      time.Time          `json:"lastCheck"`
+	StatusDetails
+}
+
+// StatusDetails holds the platform diagnostics gathered during the last check
+type StatusDetails struct {
+	// SgxState is the SGX BIOS state, or efivars_unavailable when the UEFI variables cannot be read
+	SgxState      string                      `json:"sgxState,omitempty"`
+	SgxEnablement *mpmanagement.SgxEnablement `json:"sgxEnablement,omitempty"`
+	// RegistrationConfiguration describes the SgxRegistrationConfiguration UEFI variable
+	RegistrationConfiguration *RegistrationConfigurationDetails `json:"registrationConfiguration,omitempty"`
+	// PCKCertificate describes the PCK certificate of a registered platform
+	PCKCertificate *PCKCertificateDetails `json:"pckCertificate,omitempty"`
+	// PCKCertificates describes the PCK certificates of all the TCB levels, retrieved with an Intel API key
+	PCKCertificates *PCKCertificatesDetails `json:"pckCertificates,omitempty"`
+	// TCB describes the TCB status of a registered platform
+	TCB *TCBDetails `json:"tcb,omitempty"`
+	// PCCSPlatforms describes the outcome of adding the platform to each PCCS
+	PCCSPlatforms []PCCSOutcome `json:"pccsPlatforms,omitempty"`
+	// PCCSUploads describes the outcome of uploading the PCK certificates to each PCCS
+	PCCSUploads []PCCSOutcome `json:"pccsUploads,omitempty"`
+	// PCCSConsistency compares the PCK certificate served by each PCCS with the one of Intel
+	PCCSConsistency []PCCSConsistencyDetails `json:"pccsConsistency,omitempty"`
+}
+
+// statusDetailsProvider is implemented by the checkers that gather platform diagnostics
+type statusDetailsProvider interface {
+	StatusDetails() StatusDetails
+}
+
+// statusHolder keeps the last status, shared between the check loop and the HTTP handler
+type statusHolder struct {
+	mu     sync.RWMutex
+	status Status
+}
+
+func (h *statusHolder) get() Status {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.status
+}
+
+func (h *statusHolder) set(status Status) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status = status
+}
+
+// Status returns the outcome of the last registration check
+func (r *RegistrationService) Status() Status {
+	return r.status.get()
+}
+
+// StatusHandler serves the outcome of the last registration check as JSON
+func (r *RegistrationService) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(r.Status()); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package registration
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	pckcert "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/pck_cert"
+	tcbinfo "github.com/opensovereigncloud/cc-intel-platform-registration/internal/pkg/tcb_info"
+	intelservices "github.com/opensovereigncloud/cc-intel-platform-registration/pkg/intel_services"
+	"go.uber.org/zap"
+)
+
+// TCBDetails describes the TCB status of the platform evaluated during the last check
+type TCBDetails struct {
+	Status      string   `json:"status"`
+	AdvisoryIDs []string `json:"advisoryIDs,omitempty"`
+	// TCBDate is the date of the matching TCB level, unset when no TCB level matches
+	TCBDate                 *time.Time `json:"tcbDate,omitempty"`
+	TCBEvaluationDataNumber int        `json:"tcbEvaluationDataNumber"`
+	NextUpdate              time.Time  `json:"nextUpdate"`
+}
+
+// evaluateTCB evaluates the TCB level of the PCK certificate against the TCB Info of its FMSPC, which
+// it returns. The TCB status is informative only, so an unavailable TCB Info is only logged.
+func (rc *DefaultRegistrationChecker) evaluateTCB(ctx context.Context, intelService *intelservices.IntelService, cert *pckcert.Certificate) *tcbinfo.TCBInfo {
+	// the SGX extensions hold the TCBm of the certificate, already checked against the response headers
+	ext, err := pckcert.ParseSGXExtensions(cert.Leaf)
+	if err != nil {
+		rc.log.Warn("unable to read the PCK certificate TCB, skipping the TCB evaluation", zap.Error(err))
+		return nil
+	}
+
+	tcbInfo, err := intelService.RetrieveTCBInfo(ctx, cert.FMSPC, hex.EncodeToString(ext.PCEID[:]))
+	if err != nil {
+		rc.log.Warn("unable to retrieve the TCB Info, skipping the TCB evaluation",
+			zap.String("fmspc", cert.FMSPC), zap.Error(err))
+		return nil
+	}
+
+	details := &TCBDetails{
+		Status:                  tcbinfo.StatusUnrecognized,
+		TCBEvaluationDataNumber: tcbInfo.TCBEvaluationDataNumber,
+		NextUpdate:              tcbInfo.NextUpdate,
+	}
+	if level, ok := tcbInfo.Evaluate(ext.CPUSVN, ext.PCESVN); ok {
+		details.Status = level.TCBStatus
+		details.AdvisoryIDs = level.AdvisoryIDs
+		details.TCBDate = &level.TCBDate
+	}
+
+	rc.log.Info("platform TCB evaluated",
+		zap.String("status", details.Status),
+		zap.Strings("advisoryIDs", details.AdvisoryIDs),
+		zap.Int("tcbEvaluationDataNumber", details.TCBEvaluationDataNumber))
+	rc.metricsRegistry.UpdateTCBMetrics(details.Status, details.AdvisoryIDs, details.TCBEvaluationDataNumber)
+
+	rc.detailsMu.Lock()
+	rc.details.TCB = details
+	rc.detailsMu.Unlock()
+	return tcbInfo
+}
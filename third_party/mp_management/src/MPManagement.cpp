@@ -173,6 +173,52 @@ MpResult MPManagement::getPlatformManifest(uint8_t *buffer, uint16_t &buffer_siz
     return getRequestData(buffer, buffer_size, MP_REQ_REGISTRATION);
 }
 
+MpResult MPManagement::getAddPackageRequest(uint8_t *buffer, uint16_t &buffer_size)
+{
+    return getRequestData(buffer, buffer_size, MP_REQ_ADD_PACKAGE);
+}
+
+MpResult MPManagement::getRequestType(MpRequestType &type)
+{
+    return m_mpuefi->getRequestType(type);
+}
+
+MpResult MPManagement::getPendingRequest(uint8_t *buffer, uint16_t &buffer_size)
+{
+    if (NULL == buffer)
+    {
+        return MP_INVALID_PARAMETER;
+    }
+    return m_mpuefi->getRequest(buffer, buffer_size);
+}
+
+MpResult MPManagement::setServerResponse(const uint8_t *buffer, uint16_t buffer_size)
+{
+    return m_mpuefi->setServerResponse(buffer, buffer_size);
+}
+
+MpResult MPManagement::getPackageInfo(uint8_t *buffer, uint16_t &buffer_size)
+{
+    if (NULL == buffer)
+    {
+        return MP_INVALID_PARAMETER;
+    }
+    return m_mpuefi->getPackageInfo(buffer, buffer_size);
+}
+
+MpResult MPManagement::getPackageInfoStatus(MpMachineRegistrationStatus &status)
+{
+    MpRegistrationStatus regStatus;
+    MpResult res = m_mpuefi->getRegistrationStatus(regStatus);
+    if (MP_SUCCESS != res)
+    {
+        return res;
+    }
+
+    status = regStatus.packageInfoStatus ? MP_MACHINE_REGISTERED : MP_MACHINE_NOT_REGISTERED;
+    return MP_SUCCESS;
+}
+
 MPManagement::~MPManagement()
 {
     if (NULL != m_mpuefi)
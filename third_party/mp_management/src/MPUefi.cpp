@@ -108,6 +108,16 @@ MpResult MPUefi::getRequestType(MpRequestType &type)
 }
 
 MpResult MPUefi::getRequest(uint8_t *request, uint16_t &requestSize)
+{
+    return getSgxUefiVar(UEFI_VAR_SERVER_REQUEST, request, requestSize);
+}
+
+MpResult MPUefi::getPackageInfo(uint8_t *packageInfo, uint16_t &packageInfoSize)
+{
+    return getSgxUefiVar(UEFI_VAR_PACKAGE_INFO, packageInfo, packageInfoSize);
+}
+
+MpResult MPUefi::getSgxUefiVar(const char *varName, uint8_t *data, uint16_t &dataSize)
 {
     MpResult res = MP_SUCCESS;
     size_t varDataSize = 0;
@@ -115,7 +125,7 @@ MpResult MPUefi::getRequest(uint8_t *request, uint16_t &requestSize)
 
     do
     {
-        requestUefi = (SgxUefiVar *)m_uefi->readUEFIVar(UEFI_VAR_SERVER_REQUEST, varDataSize);
+        requestUefi = (SgxUefiVar *)m_uefi->readUEFIVar(varName, varDataSize);
         if (requestUefi == 0)
         {
             res = MP_NO_PENDING_DATA;
@@ -136,18 +146,18 @@ MpResult MPUefi::getRequest(uint8_t *request, uint16_t &requestSize)
             break;
         }
 
-        if (request)
+        if (data)
         {
-            if (requestSize < requestUefi->size)
+            if (dataSize < requestUefi->size)
             {
                 res = MP_USER_INSUFFICIENT_MEM;
             }
             else
             {
-                memcpy(request, &(requestUefi->header), requestUefi->size);
+                memcpy(data, &(requestUefi->header), requestUefi->size);
             }
         }
-        requestSize = requestUefi->size;
+        dataSize = requestUefi->size;
     } while (0);
 
     if (requestUefi)
@@ -248,6 +258,54 @@ MpResult MPUefi::setRegistrationStatus(const MpRegistrationStatus &status)
     return res;
 }
 
+MpResult MPUefi::setServerResponse(const uint8_t *response, uint16_t responseSize)
+{
+    MpResult res = MP_SUCCESS;
+    uint8_t *responseUefi = NULL;
+    size_t responseUefiSize = 0;
+
+    do
+    {
+        if (NULL == response || 0 == responseSize || responseSize > MAX_RESPONSE_SIZE)
+        {
+            res = MP_INVALID_PARAMETER;
+            break;
+        }
+
+        // version + size + response data
+        responseUefiSize = sizeof(uint16_t) + sizeof(uint16_t) + responseSize;
+        responseUefi = new uint8_t[responseUefiSize];
+        if (!responseUefi)
+        {
+            res = MP_MEM_ERROR;
+            break;
+        }
+
+        ((SgxUefiVar *)responseUefi)->version = MP_BIOS_UEFI_VARIABLE_VERSION_1;
+        ((SgxUefiVar *)responseUefi)->size = responseSize;
+        memcpy(responseUefi + sizeof(uint16_t) + sizeof(uint16_t), response, responseSize);
+
+        // write server response to uefi, the variable is created if needed
+        int numOfBytes = m_uefi->writeUEFIVar(UEFI_VAR_SERVER_RESPONSE, responseUefi, responseUefiSize, true);
+        if (numOfBytes != (int)responseUefiSize)
+        {
+            if (numOfBytes == -1)
+            {
+                res = MP_INSUFFICIENT_PRIVILEGES;
+                break;
+            }
+            res = MP_UEFI_INTERNAL_ERROR;
+            break;
+        }
+    } while (0);
+
+    if (responseUefi)
+    {
+        delete[] responseUefi;
+    }
+    return res;
+}
+
 MPUefi::~MPUefi()
 {
     if (NULL != m_uefi)
@@ -60,6 +60,60 @@ MpResult mp_management_get_platform_manifest(uint8_t *buffer, uint16_t *size)
     return g_mpManagement->getPlatformManifest(buffer, *size);
 }
 
+MpResult mp_management_get_add_package_request(uint8_t *buffer, uint16_t *size)
+{
+    if (!buffer || !size)
+    {
+        return MP_INVALID_PARAMETER;
+    }
+    return g_mpManagement->getAddPackageRequest(buffer, *size);
+}
+
+MpResult mp_management_get_request_type(MpRequestType *type)
+{
+    if (!type)
+    {
+        return MP_INVALID_PARAMETER;
+    }
+    return g_mpManagement->getRequestType(*type);
+}
+
+MpResult mp_management_get_pending_request(uint8_t *buffer, uint16_t *size)
+{
+    if (!buffer || !size)
+    {
+        return MP_INVALID_PARAMETER;
+    }
+    return g_mpManagement->getPendingRequest(buffer, *size);
+}
+
+MpResult mp_management_set_server_response(const uint8_t *buffer, uint16_t size)
+{
+    if (!buffer)
+    {
+        return MP_INVALID_PARAMETER;
+    }
+    return g_mpManagement->setServerResponse(buffer, size);
+}
+
+MpResult mp_management_get_package_info(uint8_t *buffer, uint16_t *size)
+{
+    if (!buffer || !size)
+    {
+        return MP_INVALID_PARAMETER;
+    }
+    return g_mpManagement->getPackageInfo(buffer, *size);
+}
+
+MpResult mp_management_get_package_info_status(MpMachineRegistrationStatus *status)
+{
+    if (!status)
+    {
+        return MP_INVALID_PARAMETER;
+    }
+    return g_mpManagement->getPackageInfoStatus(*status);
+}
+
 MpResult mp_management_get_registration_status(MpMachineRegistrationStatus *status)
 {
     if (!status)
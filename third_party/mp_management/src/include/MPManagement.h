@@ -56,6 +56,28 @@ public:
     // if not, returns an appropriate error (MP_NO_PENDING_DATA). populates buffer_size with the required size in case of insufficient size.
     virtual MpResult getPlatformManifest(uint8_t *buffer, uint16_t &buffer_size);
 
+    // Retrieves AddPackage request.
+    // if an AddRequest UEFI is ready for reading, copies the AddRequest to input buffer.
+    // if not, returns an appropriate error (MP_NO_PENDING_DATA). populates buffer_size with the required size in case of insufficient size.
+    virtual MpResult getAddPackageRequest(uint8_t *buffer, uint16_t &buffer_size);
+
+    // Retrieves the type of the request pending in the UEFI, regardless of the registration status.
+    virtual MpResult getRequestType(MpRequestType &type);
+
+    // Retrieves the request pending in the UEFI, regardless of the registration status.
+    // populates buffer_size with the required size in case of insufficient size.
+    virtual MpResult getPendingRequest(uint8_t *buffer, uint16_t &buffer_size);
+
+    // Writes the registration server response (e.g. AddPackage membership certificates) to the UEFI for the BIOS.
+    virtual MpResult setServerResponse(const uint8_t *buffer, uint16_t buffer_size);
+
+    // Retrieves the package info (key blobs of the processor packages) published by the BIOS.
+    // populates buffer_size with the required size in case of insufficient size.
+    virtual MpResult getPackageInfo(uint8_t *buffer, uint16_t &buffer_size);
+
+    // Retrieves whether the package info has been consumed (package info status bit).
+    virtual MpResult getPackageInfoStatus(MpMachineRegistrationStatus &status);
+
     // Retrieves registration error code.
     // If registration is completed successfully, error_code will be set to 0.
     // If registration process failed, error_code will be set to the relevant last reported error code.
@@ -89,6 +89,25 @@ public:
      */
     MpResult getRequest(uint8_t *request, uint16_t &requestSize);
 
+    /**
+     * Retrieves the content of the package info.
+     * The BIOS publishes the key blobs of the processor packages in the package info, to be saved by the
+     * registration agent when the SGX Registration Server does not save them.
+     *
+     * @param packageInfo       - output parameter, holds the package info buffer to be populated.
+     * @param packageInfoSize   - input parameter, size of package info buffer in bytes.
+     *                          - output paramerter, holds the actual size written to package info buffer.
+     *                            if response equals MP_USER_INSUFFICIENT_MEM or if package info buffer is NULL, holds the package info size.
+     *
+     * @return status code, one of:
+     *      - MP_SUCCESS
+     *      - MP_INVALID_PARAMETER
+     *      - MP_NO_PENDING_DATA
+     *      - MP_USER_INSUFFICIENT_MEM
+     *      - MP_UEFI_INTERNAL_ERROR
+     */
+    MpResult getPackageInfo(uint8_t *packageInfo, uint16_t &packageInfoSize);
+
     /**
      * Retrieves the current registration status.
      *
@@ -113,6 +132,20 @@ public:
      */
     MpResult setRegistrationStatus(const MpRegistrationStatus &status);
 
+    /**
+     * Sets the SGX Registration Server response to be consumed by the BIOS.
+     *
+     * @param response      - input parameter, holds the response buffer.
+     * @param responseSize  - input parameter, size of the response buffer in bytes.
+     *
+     * @return status code, one of:
+     *      - MP_SUCCESS
+     *      - MP_INVALID_PARAMETER
+     *      - MP_INSUFFICIENT_PRIVILEGES
+     *      - MP_UEFI_INTERNAL_ERROR
+     */
+    MpResult setServerResponse(const uint8_t *response, uint16_t responseSize);
+
     /**
      * MPUefi class destructor
      */
@@ -121,6 +154,8 @@ public:
 private:
     FSUefi *m_uefi;
 
+    MpResult getSgxUefiVar(const char *varName, uint8_t *data, uint16_t &dataSize);
+
     MPUefi &operator=(const MPUefi &) { return *this; }
     MPUefi(const MPUefi &src) { (void)src; }
 };
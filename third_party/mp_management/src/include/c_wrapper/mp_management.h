@@ -45,6 +45,12 @@ extern "C"
 
     void mp_management_init();
     MpResult mp_management_get_platform_manifest(uint8_t *buffer, uint16_t *size);
+    MpResult mp_management_get_add_package_request(uint8_t *buffer, uint16_t *size);
+    MpResult mp_management_get_request_type(MpRequestType *type);
+    MpResult mp_management_get_pending_request(uint8_t *buffer, uint16_t *size);
+    MpResult mp_management_set_server_response(const uint8_t *buffer, uint16_t size);
+    MpResult mp_management_get_package_info(uint8_t *buffer, uint16_t *size);
+    MpResult mp_management_get_package_info_status(MpMachineRegistrationStatus *status);
     MpResult mp_management_get_registration_status(MpMachineRegistrationStatus *status);
     MpResult mp_management_set_registration_status_as_complete();
     void mp_management_terminate();